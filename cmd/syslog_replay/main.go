@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/capture"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/syslog_custom"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// replay captured syslog messages to a running event server:
+//
+//	go run ./cmd/syslog_replay -server 127.0.0.1 -speed 10 -src-ip 192.168.13.2 capture/syslog-*.jsonl
+var (
+	server    = flag.String("server", "127.0.0.1", "event server address")
+	port      = flag.Int("port", 0, "event server port, default is the captured port")
+	transport = flag.String("transport", "udp", "udp or tcp (octet-counting framing)")
+	unit      = flag.String("unit", "", "replay only messages of this unit (Beward, Qtech, ...)")
+	speed     = flag.Float64("speed", 1, "replay speed: 1 real time, 10 ten times faster, 0 without delays")
+	srcIP     = flag.String("src-ip", "", "replace device address in all messages")
+	rewrite   = flag.String("rewrite", "", "replace device addresses: old=new,old2=new2")
+)
+
+// header before HOSTNAME for messages without it
+var (
+	bsdHeaderRegex     = regexp.MustCompile(`^<\d{1,3}>[A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2}(?:\.\d+)?`)
+	rfc5424HeaderRegex = regexp.MustCompile(`^(<\d{1,3}>\d{1,2} \S+ )- `)
+)
+
+func main() {
+	flag.Parse()
+
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+
+	if flag.NArg() == 0 {
+		logger.Error("No capture files, usage: syslog_replay [flags] file.jsonl ...")
+		os.Exit(1)
+	}
+	if *transport != syslog_custom.TransportUDP && *transport != syslog_custom.TransportTCP {
+		logger.Error("Unsupported transport", "transport", *transport)
+		os.Exit(1)
+	}
+
+	rewrites, err := parseRewrites(*rewrite)
+	if err != nil {
+		logger.Error("Invalid rewrite rules", "error", err)
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		sigChan := make(chan os.Signal, 1)
+		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+		<-sigChan
+		logger.Info("Received shutdown signal")
+		cancel()
+	}()
+
+	r := &replayer{
+		logger:   logger,
+		rewrites: rewrites,
+		conns:    make(map[string]net.Conn),
+	}
+	defer r.close()
+
+	for _, file := range flag.Args() {
+		if err := r.replayFile(ctx, file); err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			logger.Error("Error replaying file", "file", file, "error", err)
+			os.Exit(1)
+		}
+	}
+
+	logger.Info("Replay finished", "sent", r.sent, "skipped", r.skipped)
+}
+
+type replayer struct {
+	logger   *slog.Logger
+	rewrites map[string]string
+	conns    map[string]net.Conn // by destination address
+
+	first   time.Time // first record capture time
+	started time.Time // replay start time
+	sent    int
+	skipped int
+}
+
+func (r *replayer) replayFile(ctx context.Context, name string) error {
+	file, err := os.Open(name)
+	if err != nil {
+		return fmt.Errorf("failed to open capture file: %w", err)
+	}
+	defer file.Close()
+
+	r.logger.Info("Replaying capture file", "file", name)
+
+	reader := capture.NewReader(file)
+	for {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read capture record: %w", err)
+		}
+
+		if *unit != "" && !strings.EqualFold(record.Unit, *unit) {
+			r.skipped++
+			continue
+		}
+
+		if err := r.wait(ctx, record.Time); err != nil {
+			return err
+		}
+
+		if err := r.send(record); err != nil {
+			r.logger.Warn("Error sending message", "error", err)
+			continue
+		}
+		r.sent++
+	}
+}
+
+// wait keep original intervals between messages divided by speed
+func (r *replayer) wait(ctx context.Context, recordTime time.Time) error {
+	if r.first.IsZero() {
+		r.first = recordTime
+		r.started = time.Now()
+		return nil
+	}
+	if *speed <= 0 {
+		return ctx.Err()
+	}
+
+	offset := time.Duration(float64(recordTime.Sub(r.first)) / *speed)
+	delay := time.Until(r.started.Add(offset))
+	if delay <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (r *replayer) send(record *capture.Record) error {
+	dstPort := record.Port
+	if *port != 0 {
+		dstPort = *port
+	}
+	addr := net.JoinHostPort(*server, fmt.Sprint(dstPort))
+
+	conn, err := r.conn(addr)
+	if err != nil {
+		return err
+	}
+
+	message := r.rewriteHost(record)
+	if *transport == syslog_custom.TransportTCP {
+		message = fmt.Sprintf("%d %s", len(message), message)
+	}
+
+	if _, err := conn.Write([]byte(message)); err != nil {
+		conn.Close()
+		delete(r.conns, addr)
+		return fmt.Errorf("failed to send message to %s: %w", addr, err)
+	}
+	return nil
+}
+
+func (r *replayer) conn(addr string) (net.Conn, error) {
+	if conn, ok := r.conns[addr]; ok {
+		return conn, nil
+	}
+
+	network := "udp"
+	if *transport == syslog_custom.TransportTCP {
+		network = "tcp"
+	}
+	conn, err := net.Dial(network, addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+
+	r.conns[addr] = conn
+	return conn, nil
+}
+
+// rewriteHost put the new device address into the HOSTNAME field,
+// the server uses HOSTNAME instead of the packet source address when it is an IP.
+func (r *replayer) rewriteHost(record *capture.Record) string {
+	message, err := syslog_custom.ParseMessage(record.Message, record.Unit, record.Time)
+	if err != nil {
+		return record.Message
+	}
+
+	device := record.SrcIP
+	if ip := net.ParseIP(message.HostName); ip != nil && !ip.IsLoopback() {
+		device = message.HostName
+	}
+
+	newIP := *srcIP
+	if ip, ok := r.rewrites[device]; ok {
+		newIP = ip
+	}
+	if newIP == "" || newIP == device {
+		return record.Message
+	}
+
+	raw := strings.TrimSpace(record.Message)
+	if message.HostName != "" {
+		return strings.Replace(raw, " "+message.HostName+" ", " "+newIP+" ", 1)
+	}
+
+	switch message.Format {
+	case syslog_custom.FormatRFC5424:
+		if loc := rfc5424HeaderRegex.FindStringSubmatchIndex(raw); loc != nil {
+			return raw[:loc[3]] + newIP + raw[loc[1]-1:]
+		}
+	case syslog_custom.FormatBSD:
+		if loc := bsdHeaderRegex.FindStringIndex(raw); loc != nil {
+			return raw[:loc[1]] + " " + newIP + raw[loc[1]:]
+		}
+	}
+
+	r.logger.Warn("Can't rewrite device address, message without hostname", "unit", record.Unit, "ip", device)
+	return record.Message
+}
+
+func (r *replayer) close() {
+	for _, conn := range r.conns {
+		conn.Close()
+	}
+}
+
+// parseRewrites "old=new,old2=new2"
+func parseRewrites(value string) (map[string]string, error) {
+	rewrites := make(map[string]string)
+	if value == "" {
+		return rewrites, nil
+	}
+
+	for _, rule := range strings.Split(value, ",") {
+		oldIP, newIP, ok := strings.Cut(strings.TrimSpace(rule), "=")
+		if !ok || net.ParseIP(oldIP) == nil || net.ParseIP(newIP) == nil {
+			return nil, fmt.Errorf("invalid rewrite rule: %s", rule)
+		}
+		rewrites[oldIP] = newIP
+	}
+	return rewrites, nil
+}
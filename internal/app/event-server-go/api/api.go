@@ -0,0 +1,224 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/config"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/storage"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultLimit = 100
+	maxLimit     = 1000
+
+	defaultRange = 24 * time.Hour
+)
+
+// Store events history of the API
+type Store interface {
+	PlogEvents(ctx context.Context, filter storage.PlogFilter) ([]storage.PlogRow, error)
+	SetPlogHidden(ctx context.Context, eventUUID string, hidden bool) error
+	SyslogMessages(ctx context.Context, filter storage.SyslogFilter) ([]storage.SyslogStorageMessage, error)
+}
+
+// SecurityStore security events of the API, both Clickhouse protocols
+type SecurityStore interface {
+	SecurityEvents(ctx context.Context, filter storage.SecurityFilter) ([]storage.SecurityEvent, error)
+}
+
+// Handler support API: plog, syslog and security history, bearer token auth, and camshots by signed links
+type Handler struct {
+	logger   *slog.Logger
+	store    Store
+	security SecurityStore
+	images   storage.ImageStore
+	links    *Links
+	token    string
+	mux      *http.ServeMux
+}
+
+// NewHandler plog and syslog routes are not mounted if store is nil, security route if security is nil
+func NewHandler(logger *slog.Logger, store Store, security SecurityStore, images storage.ImageStore, cfg *config.ApiConfig) *Handler {
+	if cfg.Token == "" {
+		logger.Warn("API token is not set, all requests are rejected")
+	}
+
+	h := &Handler{
+		logger:   logger,
+		store:    store,
+		security: security,
+		images:   images,
+		links:    NewLinks(cfg.PublicURL, cfg.LinkSecret, time.Duration(cfg.LinkTTL)*time.Second),
+		token:    cfg.Token,
+		mux:      http.NewServeMux(),
+	}
+
+	if store != nil {
+		h.mux.HandleFunc("GET /api/v1/plog", h.authorized(h.plogEvents))
+		h.mux.HandleFunc("POST /api/v1/plog/{event_uuid}/hide", h.authorized(h.setPlogHidden(true)))
+		h.mux.HandleFunc("POST /api/v1/plog/{event_uuid}/unhide", h.authorized(h.setPlogHidden(false)))
+		h.mux.HandleFunc("GET /api/v1/syslog", h.authorized(h.syslogMessages))
+	}
+	if security != nil {
+		h.mux.HandleFunc("GET /api/v1/security", h.authorized(h.securityEvents))
+	}
+	h.mux.HandleFunc("GET "+camshotPath+"{image_uuid}", h.camshot)
+
+	// expvar metrics: syslog queue depth, drops and handler latency per unit
+	h.mux.HandleFunc("GET /debug/vars", h.authorized(expvar.Handler().ServeHTTP))
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// authorized token from "Authorization: Bearer"
+func (h *Handler) authorized(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !h.validToken(r) {
+			h.logger.Warn("API unauthorized", "path", r.URL.Path, "ip", r.RemoteAddr)
+			writeError(w, http.StatusUnauthorized, errors.New("unauthorized"))
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (h *Handler) validToken(r *http.Request) bool {
+	token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return h.token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) == 1
+}
+
+// page list response, next_offset is null on the last page
+type page[T any] struct {
+	Items      []T  `json:"items"`
+	Limit      int  `json:"limit"`
+	Offset     int  `json:"offset"`
+	NextOffset *int `json:"next_offset"`
+}
+
+func newPage[T any](items []T, limit, offset int) page[T] {
+	p := page[T]{Items: items, Limit: limit, Offset: offset}
+	if p.Items == nil {
+		p.Items = []T{}
+	}
+	if len(items) == limit {
+		next := offset + limit
+		p.NextOffset = &next
+	}
+	return p
+}
+
+func writeJSON(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// queryParams query string parser, errors are collected
+type queryParams struct {
+	values url.Values
+	errs   []error
+}
+
+func (q *queryParams) int(name string) int {
+	value := q.values.Get(name)
+	if value == "" {
+		return 0
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		q.errs = append(q.errs, fmt.Errorf("invalid %s %q", name, value))
+		return 0
+	}
+	return n
+}
+
+// ints comma separated list
+func (q *queryParams) ints(name string) []int {
+	value := q.values.Get(name)
+	if value == "" {
+		return nil
+	}
+
+	var ns []int
+	for _, item := range strings.Split(value, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(item))
+		if err != nil {
+			q.errs = append(q.errs, fmt.Errorf("invalid %s %q", name, value))
+			return nil
+		}
+		ns = append(ns, n)
+	}
+	return ns
+}
+
+func (q *queryParams) bool(name string) bool {
+	value := q.values.Get(name)
+	if value == "" {
+		return false
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		q.errs = append(q.errs, fmt.Errorf("invalid %s %q", name, value))
+	}
+	return b
+}
+
+// time unix seconds or RFC 3339
+func (q *queryParams) time(name string, def time.Time) time.Time {
+	value := q.values.Get(name)
+	if value == "" {
+		return def
+	}
+	if unix, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(unix, 0)
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		q.errs = append(q.errs, fmt.Errorf("invalid %s %q", name, value))
+		return def
+	}
+	return t
+}
+
+// timeRange from and to, the last day by default
+func (q *queryParams) timeRange(now time.Time) (time.Time, time.Time) {
+	to := q.time("to", now)
+	from := q.time("from", to.Add(-defaultRange))
+	if !from.Before(to) {
+		q.errs = append(q.errs, errors.New("from must be before to"))
+	}
+	return from, to
+}
+
+// page limit and offset
+func (q *queryParams) page() (int, int) {
+	limit := q.int("limit")
+	if limit == 0 {
+		limit = defaultLimit
+	}
+	if limit > maxLimit {
+		q.errs = append(q.errs, fmt.Errorf("limit is over %d", maxLimit))
+	}
+	return limit, q.int("offset")
+}
+
+func (q *queryParams) err() error {
+	return errors.Join(q.errs...)
+}
@@ -0,0 +1,156 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/events"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/storage"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/utils"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	camshotMaxAge  = 24 * time.Hour
+	camshotMaxSize = 10 << 20 // thumbnail source image limit
+
+	maxThumbnailWidth = 1024
+)
+
+// camshot GET /api/v1/camshot/{image_uuid}[?width=], signed link or bearer token, the image from any images backend
+func (h *Handler) camshot(w http.ResponseWriter, r *http.Request) {
+	imageUUID := r.PathValue("image_uuid")
+	query := r.URL.Query()
+	if !h.links.Verify(imageUUID, query.Get("expires"), query.Get("sig"), time.Now()) && !h.validToken(r) {
+		h.logger.Warn("API camshot unauthorized", "imageUUID", imageUUID, "ip", r.RemoteAddr)
+		writeError(w, http.StatusUnauthorized, errors.New("unauthorized"))
+		return
+	}
+
+	width := 0
+	if value := query.Get("width"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 || n > maxThumbnailWidth {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("width must be 1..%d", maxThumbnailWidth))
+			return
+		}
+		width = n
+	}
+
+	fileID, err := utils.FromGUIDv4(imageUUID)
+	if err != nil || imageUUID == events.ImageUUIDStub {
+		writeError(w, http.StatusNotFound, errors.New("camshot not found"))
+		return
+	}
+
+	file, err := h.images.OpenImage(r.Context(), fileID)
+	if errors.Is(err, storage.ErrFileNotFound) {
+		writeError(w, http.StatusNotFound, errors.New("camshot not found"))
+		return
+	} else if err != nil {
+		h.logger.Error("API failed to open camshot", "imageUUID", imageUUID, "error", err)
+		writeError(w, http.StatusInternalServerError, errors.New("failed to get camshot"))
+		return
+	}
+	defer file.Reader.Close()
+
+	now := time.Now()
+	if !file.Expire.IsZero() && now.After(file.Expire) {
+		writeError(w, http.StatusGone, errors.New("camshot expired"))
+		return
+	}
+
+	// images are immutable, the id is the version
+	etag := `"` + file.ID
+	if width != 0 {
+		etag += "-w" + strconv.Itoa(width)
+	}
+	etag += `"`
+
+	maxAge := camshotMaxAge
+	if !file.Expire.IsZero() {
+		maxAge = min(maxAge, file.Expire.Sub(now))
+	}
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(maxAge.Seconds())))
+	w.Header().Set("Last-Modified", file.UploadDate.UTC().Format(http.TimeFormat))
+
+	if etagMatch(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	if width != 0 {
+		h.writeThumbnail(w, file, width)
+		return
+	}
+
+	contentType := file.ContentType
+	reader := io.Reader(file.Reader)
+	if contentType == "" {
+		head := make([]byte, 512)
+		n, _ := io.ReadFull(file.Reader, head)
+		contentType = http.DetectContentType(head[:n])
+		reader = io.MultiReader(bytes.NewReader(head[:n]), file.Reader)
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	if file.Length >= 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(file.Length, 10))
+	}
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, reader); err != nil {
+		h.logger.Debug("API camshot stream interrupted", "imageUUID", imageUUID, "error", err)
+	}
+}
+
+func (h *Handler) writeThumbnail(w http.ResponseWriter, file *storage.File, width int) {
+	if file.Length > camshotMaxSize {
+		writeError(w, http.StatusUnprocessableEntity, errors.New("camshot is too large for thumbnail"))
+		return
+	}
+
+	// S3 bodies may have no length
+	data, err := io.ReadAll(io.LimitReader(file.Reader, camshotMaxSize+1))
+	if err != nil {
+		h.logger.Error("API failed to read camshot", "fileID", file.ID, "error", err)
+		writeError(w, http.StatusInternalServerError, errors.New("failed to get camshot"))
+		return
+	}
+	if len(data) > camshotMaxSize {
+		writeError(w, http.StatusUnprocessableEntity, errors.New("camshot is too large for thumbnail"))
+		return
+	}
+
+	thumbnail, err := resizeJPEG(data, width)
+	if errors.Is(err, errImageTooLarge) {
+		h.logger.Warn("API camshot is too large for thumbnail", "fileID", file.ID, "error", err)
+		writeError(w, http.StatusUnprocessableEntity, errors.New("camshot is too large for thumbnail"))
+		return
+	}
+	if err != nil {
+		h.logger.Warn("API failed to make thumbnail", "fileID", file.ID, "error", err)
+		writeError(w, http.StatusUnprocessableEntity, errors.New("camshot is not an image"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("Content-Length", strconv.Itoa(len(thumbnail)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(thumbnail)
+}
+
+// etagMatch If-None-Match list or "*"
+func etagMatch(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == etag || tag == "*" {
+			return true
+		}
+	}
+	return false
+}
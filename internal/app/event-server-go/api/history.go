@@ -0,0 +1,111 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/events"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/storage"
+	"net"
+	"net/http"
+	"time"
+)
+
+// plogEvent plog row with signed camshot link
+type plogEvent struct {
+	storage.PlogRow
+	CamshotURL string `json:"camshot_url,omitempty"`
+}
+
+// plogEvents GET /api/v1/plog?flat_id=&domophone_id=&entrance_id=&house_id=&event=3,6&rfid=&code=&from=&to=&include_hidden=&limit=&offset=
+func (h *Handler) plogEvents(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	q := &queryParams{values: r.URL.Query()}
+	filter := storage.PlogFilter{
+		FlatID:        q.int("flat_id"),
+		DomophoneID:   q.int("domophone_id"),
+		EntranceID:    q.int("entrance_id"),
+		HouseID:       q.int("house_id"),
+		Events:        q.ints("event"),
+		RFID:          q.values.Get("rfid"),
+		Code:          q.values.Get("code"),
+		IncludeHidden: q.bool("include_hidden"),
+	}
+	filter.From, filter.To = q.timeRange(now)
+	filter.Limit, filter.Offset = q.page()
+	if err := q.err(); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	rows, err := h.store.PlogEvents(r.Context(), filter)
+	if err != nil {
+		h.logger.Error("API failed to get plog events", "error", err)
+		writeError(w, http.StatusInternalServerError, errors.New("failed to get plog events"))
+		return
+	}
+
+	items := make([]plogEvent, 0, len(rows))
+	for _, row := range rows {
+		item := plogEvent{PlogRow: row}
+		if row.ImageUUID != "" && row.ImageUUID != events.ImageUUIDStub {
+			item.CamshotURL = h.links.Camshot(row.ImageUUID, now)
+		}
+		items = append(items, item)
+	}
+
+	writeJSON(w, http.StatusOK, newPage(items, filter.Limit, filter.Offset))
+}
+
+// setPlogHidden POST /api/v1/plog/{event_uuid}/hide and /unhide, hidden events are not shown to subscribers
+func (h *Handler) setPlogHidden(hidden bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		eventUUID := r.PathValue("event_uuid")
+		if _, err := uuid.Parse(eventUUID); err != nil {
+			writeError(w, http.StatusBadRequest, errors.New("invalid event_uuid"))
+			return
+		}
+
+		if err := h.store.SetPlogHidden(r.Context(), eventUUID, hidden); err != nil {
+			h.logger.Error("API failed to update plog hidden", "eventUUID", eventUUID, "error", err)
+			writeError(w, http.StatusInternalServerError, errors.New("failed to update event"))
+			return
+		}
+
+		h.logger.Info("API plog event hidden changed", "eventUUID", eventUUID, "hidden", hidden, "ip", r.RemoteAddr)
+		writeJSON(w, http.StatusAccepted, map[string]any{"event_uuid": eventUUID, "hidden": hidden})
+	}
+}
+
+// syslogMessages GET /api/v1/syslog?ip=&unit=&q=&from=&to=&limit=&offset=
+func (h *Handler) syslogMessages(w http.ResponseWriter, r *http.Request) {
+	q := &queryParams{values: r.URL.Query()}
+	filter := storage.SyslogFilter{
+		Ip:   q.values.Get("ip"),
+		Unit: q.values.Get("unit"),
+		Text: q.values.Get("q"),
+	}
+	if filter.Ip != "" {
+		// IPv4 or IPv6, as the listeners format it
+		if ip := net.ParseIP(filter.Ip); ip != nil {
+			filter.Ip = ip.String()
+		} else {
+			q.errs = append(q.errs, fmt.Errorf("invalid ip %q", filter.Ip))
+		}
+	}
+	filter.From, filter.To = q.timeRange(time.Now())
+	filter.Limit, filter.Offset = q.page()
+	if err := q.err(); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	messages, err := h.store.SyslogMessages(r.Context(), filter)
+	if err != nil {
+		h.logger.Error("API failed to get syslog messages", "error", err)
+		writeError(w, http.StatusInternalServerError, errors.New("failed to get syslog messages"))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newPage(messages, filter.Limit, filter.Offset))
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	camshotPath = "/api/v1/camshot/"
+
+	defaultLinkTTL = time.Hour
+)
+
+// Links signed camshot URLs: "<public_url>/api/v1/camshot/<image_uuid>?expires=<unix>&sig=<hmac>"
+type Links struct {
+	baseURL string
+	secret  []byte
+	ttl     time.Duration
+}
+
+func NewLinks(baseURL, secret string, ttl time.Duration) *Links {
+	if ttl <= 0 {
+		ttl = defaultLinkTTL
+	}
+	return &Links{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		secret:  []byte(secret),
+		ttl:     ttl,
+	}
+}
+
+// Camshot link valid for ttl, empty if signing is not configured
+func (l *Links) Camshot(imageUUID string, now time.Time) string {
+	if len(l.secret) == 0 {
+		return ""
+	}
+
+	expires := strconv.FormatInt(now.Add(l.ttl).Unix(), 10)
+	values := url.Values{}
+	values.Set("expires", expires)
+	values.Set("sig", l.sign(imageUUID, expires))
+	return l.baseURL + camshotPath + imageUUID + "?" + values.Encode()
+}
+
+// Verify link signature and expiry
+func (l *Links) Verify(imageUUID, expires, sig string, now time.Time) bool {
+	if len(l.secret) == 0 {
+		return false
+	}
+
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || now.Unix() > expiresAt {
+		return false
+	}
+	return hmac.Equal([]byte(sig), []byte(l.sign(imageUUID, expires)))
+}
+
+func (l *Links) sign(imageUUID, expires string) string {
+	mac := hmac.New(sha256.New, l.secret)
+	mac.Write([]byte(imageUUID + ":" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,32 @@
+package api
+
+import (
+	"errors"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/storage"
+	"net/http"
+	"time"
+)
+
+// securityEvents GET /api/v1/security?domophone_id=&type=&from=&to=&limit=&offset=
+func (h *Handler) securityEvents(w http.ResponseWriter, r *http.Request) {
+	q := &queryParams{values: r.URL.Query()}
+	filter := storage.SecurityFilter{
+		DomophoneID: q.int("domophone_id"),
+		Type:        q.values.Get("type"),
+	}
+	filter.From, filter.To = q.timeRange(time.Now())
+	filter.Limit, filter.Offset = q.page()
+	if err := q.err(); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	events, err := h.security.SecurityEvents(r.Context(), filter)
+	if err != nil {
+		h.logger.Error("API failed to get security events", "error", err)
+		writeError(w, http.StatusInternalServerError, errors.New("failed to get security events"))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newPage(events, filter.Limit, filter.Offset))
+}
@@ -0,0 +1,218 @@
+package capture
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/config"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	filePrefix = "syslog-"
+	fileExt    = ".jsonl"
+
+	defaultMaxSizeMB = 100
+	defaultMaxFiles  = 10
+
+	flushInterval = time.Second
+)
+
+// Record one received syslog packet
+type Record struct {
+	Time      time.Time `json:"ts"`
+	SrcIP     string    `json:"src_ip"`
+	Unit      string    `json:"unit"`
+	Port      int       `json:"port"`
+	Transport string    `json:"transport"`
+	Message   string    `json:"msg"` // raw message
+}
+
+// Writer append records to JSON lines files, rotated by size
+type Writer struct {
+	logger   *slog.Logger
+	dir      string
+	maxSize  int64
+	maxFiles int
+
+	mu     sync.Mutex
+	file   *os.File
+	buf    *bufio.Writer
+	size   int64
+	closed bool
+	done   chan struct{}
+}
+
+func NewWriter(logger *slog.Logger, cfg *config.CaptureConfig) (*Writer, error) {
+	maxSizeMB := cfg.MaxSizeMB
+	if maxSizeMB <= 0 {
+		maxSizeMB = defaultMaxSizeMB
+	}
+	maxFiles := cfg.MaxFiles
+	if maxFiles <= 0 {
+		maxFiles = defaultMaxFiles
+	}
+
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create capture dir: %w", err)
+	}
+
+	w := &Writer{
+		logger:   logger,
+		dir:      cfg.Dir,
+		maxSize:  int64(maxSizeMB) * 1024 * 1024,
+		maxFiles: maxFiles,
+		done:     make(chan struct{}),
+	}
+	if err := w.rotate(); err != nil {
+		return nil, err
+	}
+
+	go w.flushLoop()
+
+	logger.Info("Syslog capture enabled", "dir", cfg.Dir, "maxSizeMB", maxSizeMB, "maxFiles", maxFiles)
+	return w, nil
+}
+
+// Write append record, safe for concurrent use
+func (w *Writer) Write(record Record) {
+	line, err := json.Marshal(record)
+	if err != nil {
+		w.logger.Warn("Failed to marshal capture record", "error", err)
+		return
+	}
+	line = append(line, '\n')
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return
+	}
+
+	if w.size+int64(len(line)) > w.maxSize {
+		if err := w.rotate(); err != nil {
+			w.logger.Warn("Failed to rotate capture file", "error", err)
+			return
+		}
+	}
+
+	n, err := w.buf.Write(line)
+	w.size += int64(n)
+	if err != nil {
+		w.logger.Warn("Failed to write capture record", "error", err)
+	}
+}
+
+// Close flush and close current file
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	close(w.done)
+
+	return w.closeFile()
+}
+
+func (w *Writer) flushLoop() {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+			w.mu.Lock()
+			if !w.closed {
+				if err := w.buf.Flush(); err != nil {
+					w.logger.Warn("Failed to flush capture file", "error", err)
+				}
+			}
+			w.mu.Unlock()
+		}
+	}
+}
+
+// rotate open a new file and remove the oldest ones, called with mu held
+func (w *Writer) rotate() error {
+	if err := w.closeFile(); err != nil {
+		w.logger.Warn("Failed to close capture file", "error", err)
+	}
+
+	name := filepath.Join(w.dir, filePrefix+time.Now().Format("20060102T150405.000000")+fileExt)
+	file, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open capture file: %w", err)
+	}
+
+	w.file = file
+	w.buf = bufio.NewWriter(file)
+	w.size = 0
+
+	files, err := Files(w.dir)
+	if err != nil {
+		return err
+	}
+	for len(files) > w.maxFiles {
+		if err := os.Remove(files[0]); err != nil {
+			w.logger.Warn("Failed to remove old capture file", "file", files[0], "error", err)
+		}
+		files = files[1:]
+	}
+
+	return nil
+}
+
+func (w *Writer) closeFile() error {
+	if w.file == nil {
+		return nil
+	}
+
+	flushErr := w.buf.Flush()
+	closeErr := w.file.Close()
+	w.file = nil
+
+	if flushErr != nil {
+		return flushErr
+	}
+	return closeErr
+}
+
+// Files capture files in dir, oldest first
+func Files(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, filePrefix+"*"+fileExt))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list capture files: %w", err)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// Reader read records from a capture file
+type Reader struct {
+	decoder *json.Decoder
+}
+
+func NewReader(r io.Reader) *Reader {
+	return &Reader{decoder: json.NewDecoder(bufio.NewReader(r))}
+}
+
+// Next next record, io.EOF at the end of file
+func (r *Reader) Next() (*Record, error) {
+	var record Record
+	if err := r.decoder.Decode(&record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"reflect"
+	"strings"
 )
 
 type Config struct {
@@ -16,6 +18,9 @@ type Config struct {
 	RbtApi       *RbtApi           `json:"rbtApi"`
 	FrsApi       *FrsApi           `json:"frsApi"`
 	Hw           *HwConfig         `json:"hw"`
+	Capture      *CaptureConfig    `json:"capture"`
+	Api          *ApiConfig        `json:"api"`
+	Images       *ImagesConfig     `json:"images"`
 }
 
 type Topology struct {
@@ -24,6 +29,7 @@ type Topology struct {
 
 type RbtApi struct {
 	Internal string `json:"internal"`
+	OpenDoor bool   `json:"openDoor,omitempty"` // key and flat usage through RBT "actions/openDoor" instead of Postgres
 }
 
 type FrsApi struct {
@@ -32,8 +38,38 @@ type FrsApi struct {
 }
 
 type PanelConfig struct {
-	Port        int    `json:"port"`
-	APIEndpoint string `json:"api_endpoint,omitempty"`
+	Port        int        `json:"port"`
+	APIEndpoint string     `json:"apiEndpoint,omitempty"` // HTTP panels: notifications or webhook path
+	Secret      string     `json:"secret,omitempty"`      // webhook shared secret
+	Transports  []string   `json:"transports,omitempty"`  // syslog transports: udp (default), tcp, tls
+	TLS         *TLSConfig `json:"tls,omitempty"`
+	Workers     int        `json:"workers,omitempty"`    // syslog handler workers, default 8
+	QueueSize   int        `json:"queue_size,omitempty"` // syslog messages waiting for workers, default 4096
+}
+
+// TLSConfig syslog over TLS (RFC 5425) listener settings
+type TLSConfig struct {
+	Port     int    `json:"port"` // default: panel port, must differ from it when tcp is enabled too
+	CertFile string `json:"cert_file"`
+	KeyFile  string `json:"key_file"`
+	ClientCA string `json:"client_ca,omitempty"` // optional, require and verify client certificates
+}
+
+// CaptureConfig raw syslog capture to rotating files, replay with cmd/syslog_replay
+type CaptureConfig struct {
+	Enabled   bool   `json:"enabled"`
+	Dir       string `json:"dir"`
+	MaxSizeMB int    `json:"max_size_mb"` // file size before rotation, default 100
+	MaxFiles  int    `json:"max_files"`   // files to keep, default 10
+}
+
+// ApiConfig events history API for support
+type ApiConfig struct {
+	Port       int    `json:"port"`
+	Token      string `json:"token"`       // bearer token, requests are rejected if it is not set
+	PublicURL  string `json:"public_url"`  // camshot links base URL
+	LinkSecret string `json:"link_secret"` // camshot links HMAC key
+	LinkTTL    int    `json:"link_ttl"`    // camshot link lifetime seconds, default 3600
 }
 
 type ClickhouseConfig struct {
@@ -42,6 +78,18 @@ type ClickhouseConfig struct {
 	Database string `json:"database"`
 	Username string `json:"username"`
 	Password string `json:"password"`
+	Protocol string `json:"protocol,omitempty"` // "http" (default, port 8123) or "native" (port 9000)
+	Migrate  bool   `json:"migrate,omitempty"`  // apply schema migrations at startup, or run "event-server-go migrate"
+
+	Batch *ClickhouseBatchConfig `json:"batch,omitempty"`
+}
+
+// ClickhouseBatchConfig buffered inserts, batches are spooled to disk while Clickhouse is unreachable
+type ClickhouseBatchConfig struct {
+	Size            int    `json:"size"`              // rows per table before flush, default 1000
+	FlushIntervalMs int    `json:"flush_interval_ms"` // default 1000
+	MaxRetries      int    `json:"max_retries"`       // send attempts before spooling, default 3
+	SpoolDir        string `json:"spool_dir"`         // default "spool"
 }
 
 type RedisConfig struct {
@@ -64,6 +112,34 @@ type RedisStreams struct {
 type MongoDbConfig struct {
 	URI      string `json:"uri"`
 	Database string `json:"database"`
+
+	Reaper *ReaperConfig `json:"reaper,omitempty"`
+}
+
+// ImagesConfig camshot storage, images are written to backend and read from all configured ones
+type ImagesConfig struct {
+	Backend string    `json:"backend"`       // "gridfs" (default), "fs" or "s3"
+	Dir     string    `json:"dir,omitempty"` // fs root
+	S3      *S3Config `json:"s3,omitempty"`
+}
+
+// S3Config S3 compatible storage, path-style requests
+type S3Config struct {
+	Endpoint  string `json:"endpoint"` // e.g. http://127.0.0.1:9000
+	Region    string `json:"region"`   // default us-east-1
+	Bucket    string `json:"bucket"`
+	Prefix    string `json:"prefix,omitempty"` // object key prefix
+	AccessKey string `json:"access_key"`
+	SecretKey string `json:"secret_key"`
+}
+
+// ReaperConfig deletion of GridFS and images.dir files after metadata.expire
+type ReaperConfig struct {
+	Enabled     bool `json:"enabled"`
+	IntervalMin int  `json:"interval_min"` // default 60
+	BatchSize   int  `json:"batch_size"`   // files per delete, default 500
+	MaxBatches  int  `json:"max_batches"`  // batches per run, default 100
+	DryRun      bool `json:"dry_run"`      // only report expired files and bytes
 }
 
 type PostgresConfig struct {
@@ -91,6 +167,7 @@ type HwConfig struct {
 type SpamFilters struct {
 	Beward       []string `json:"beward"`
 	Qtech        []string `json:"qtech"`
+	IS           []string `json:"is"`
 	Akuvox       []string `json:"akuvox"`
 	Rubetek      []string `json:"rubetek"`
 	SputnikCloud []string `json:"sputnik_cloud"`
@@ -98,6 +175,54 @@ type SpamFilters struct {
 	Ufanet       []string `json:"ufanet"`
 }
 
+// NamedPanel panel config with HwConfig json name
+type NamedPanel struct {
+	Name string
+	PanelConfig
+}
+
+// Panels all panels in HwConfig field order
+func (c *HwConfig) Panels() []NamedPanel {
+	value := reflect.ValueOf(*c)
+	panels := make([]NamedPanel, 0, value.NumField())
+	for i := 0; i < value.NumField(); i++ {
+		name, _, _ := strings.Cut(value.Type().Field(i).Tag.Get("json"), ",")
+		panels = append(panels, NamedPanel{
+			Name:        name,
+			PanelConfig: value.Field(i).Interface().(PanelConfig),
+		})
+	}
+	return panels
+}
+
+// Words spam words by panel json name, nil safe
+func (f *SpamFilters) Words(name string) []string {
+	if f == nil {
+		return nil
+	}
+
+	switch name {
+	// DS and DKS panels share firmware noise
+	case "beward", "beward_ds":
+		return f.Beward
+	case "qtech":
+		return f.Qtech
+	case "is":
+		return f.IS
+	case "akuvox":
+		return f.Akuvox
+	case "rubetek":
+		return f.Rubetek
+	case "sputnik_cloud":
+		return f.SputnikCloud
+	case "omny":
+		return f.Omny
+	case "ufanet":
+		return f.Ufanet
+	}
+	return nil
+}
+
 // New parse json config file
 func New(fileName string) (*Config, error) {
 	file, err := os.Open(fileName)
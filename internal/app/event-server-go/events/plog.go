@@ -0,0 +1,154 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/repository/models"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/storage"
+)
+
+// plog event types
+const (
+	NotAnswered  = 1
+	Answered     = 2
+	OpenByKey    = 3
+	OpenByApp    = 4
+	OpenByFaceID = 5
+	OpenByCode   = 6
+	OpenByCall   = 7
+	OpenByButton = 8
+	OpenByPlate  = 9
+)
+
+// camshot preview
+const (
+	PreviewNone  = 0
+	PreviewIPCam = 1 // image from DVR
+	PreviewFRS   = 2 // image from FRS, with face
+)
+
+// ImageUUIDStub image_uuid of the event without camshot
+const ImageUUIDStub = "00000000-0000-0000-0000-000000000000"
+
+// PlogEvent plog table row
+type PlogEvent struct {
+	Date      int64         `json:"date"`
+	EventUUID string        `json:"event_uuid"`
+	Hidden    int           `json:"hidden"`
+	ImageUUID string        `json:"image_uuid"`
+	FlatID    int           `json:"flat_id"`
+	Domophone PlogDomophone `json:"domophone"`
+	Event     int           `json:"event"`
+	Opened    int           `json:"opened"` // bool
+	Face      *Face         `json:"face"`
+	RFID      string        `json:"rfid"`
+	Code      string        `json:"code"`
+	Phones    Phones        `json:"phones"`
+	Preview   int           `json:"preview"`
+	CallInfo  any           `json:"call_info,omitempty"`
+}
+
+// PlogDomophone entrance the event happened at
+type PlogDomophone struct {
+	CameraID    int    `json:"camera_id"` // 0 if the entrance has no camera
+	Description string `json:"domophone_description"`
+	DomophoneID int    `json:"domophone_id"`
+	Output      int    `json:"domophone_output"`
+	EntranceID  int    `json:"entrance_id"`
+	HouseID     int    `json:"house_id"`
+}
+
+// Face FRS face position on the camshot
+type Face struct {
+	Left   int `json:"left"`
+	Top    int `json:"top"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// Phones subscriber phone of the app opening
+type Phones struct {
+	UserPhone string `json:"user_phone,omitempty"`
+}
+
+// NewPlogDomophone domophone data of the entrance
+func NewPlogDomophone(domophoneID int, entrance *models.HouseEntrance) PlogDomophone {
+	domophone := PlogDomophone{
+		Description: entrance.Entrance,
+		DomophoneID: domophoneID,
+		EntranceID:  entrance.HouseEntranceID,
+		HouseID:     entrance.AddressHouseID,
+	}
+	if entrance.DomophoneOutput != nil {
+		domophone.Output = *entrance.DomophoneOutput
+	}
+	if entrance.CameraID != nil {
+		domophone.CameraID = *entrance.CameraID
+	}
+	return domophone
+}
+
+// Validate required fields and value ranges
+func (e *PlogEvent) Validate() error {
+	var errs []error
+	if e.Date <= 0 {
+		errs = append(errs, errors.New("date is not set"))
+	}
+	if _, err := uuid.Parse(e.EventUUID); err != nil {
+		errs = append(errs, fmt.Errorf("invalid event_uuid %q", e.EventUUID))
+	}
+	if _, err := uuid.Parse(e.ImageUUID); err != nil {
+		errs = append(errs, fmt.Errorf("invalid image_uuid %q", e.ImageUUID))
+	}
+	if e.Event < NotAnswered || e.Event > OpenByPlate {
+		errs = append(errs, fmt.Errorf("unknown event %d", e.Event))
+	}
+	if e.Opened != 0 && e.Opened != 1 {
+		errs = append(errs, fmt.Errorf("invalid opened %d", e.Opened))
+	}
+	if e.Preview < PreviewNone || e.Preview > PreviewFRS {
+		errs = append(errs, fmt.Errorf("invalid preview %d", e.Preview))
+	}
+	if e.Domophone.DomophoneID <= 0 {
+		errs = append(errs, errors.New("domophone_id is not set"))
+	}
+	if e.Preview == PreviewFRS && e.Face == nil {
+		errs = append(errs, errors.New("face is not set for FRS preview"))
+	}
+	return errors.Join(errs...)
+}
+
+// Row plog table row with JSON encoded domophone, face, phones and call info
+func (e *PlogEvent) Row() (storage.PlogRow, error) {
+	row := storage.PlogRow{
+		Date:      e.Date,
+		EventUUID: e.EventUUID,
+		Hidden:    e.Hidden,
+		ImageUUID: e.ImageUUID,
+		FlatID:    e.FlatID,
+		Event:     e.Event,
+		Opened:    e.Opened,
+		RFID:      e.RFID,
+		Code:      e.Code,
+		Preview:   e.Preview,
+	}
+
+	var err error
+	if row.Domophone, err = json.Marshal(e.Domophone); err != nil {
+		return row, fmt.Errorf("failed to marshal domophone: %w", err)
+	}
+	if row.Face, err = json.Marshal(e.Face); err != nil {
+		return row, fmt.Errorf("failed to marshal face: %w", err)
+	}
+	if row.Phones, err = json.Marshal(e.Phones); err != nil {
+		return row, fmt.Errorf("failed to marshal phones: %w", err)
+	}
+	if e.CallInfo != nil {
+		if row.CallInfo, err = json.Marshal(e.CallInfo); err != nil {
+			return row, fmt.Errorf("failed to marshal call info: %w", err)
+		}
+	}
+	return row, nil
+}
@@ -0,0 +1,239 @@
+package events
+
+import (
+	"context"
+	"crypto/md5"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/config"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/repository"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/storage"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/utils"
+	"github.com/redis/go-redis/v9"
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+const (
+	camshotTTL     = time.Hour * 24 * 30 * 6
+	camshotURLPath = "/frs/camshot/"
+
+	// push image lifetime in redis "shot_<hash>"
+	pushShotTTL = 15 * time.Minute
+)
+
+// Shot event camshot saved to the image store
+type Shot struct {
+	ImageUUID string
+	Preview   int
+	Face      *Face
+	Hash      string // redis "shot_" key suffix for push
+}
+
+// Push watcher notification details, after the address
+type Push struct {
+	Body string
+	Hash string
+}
+
+// EventWriter plog events: camshot attachment, ClickHouse insert and watchers push
+type EventWriter struct {
+	logger  *slog.Logger
+	storage storage.EventStore
+	images  storage.ImageStore
+	repo    *repository.PostgresRepository
+	redis   *redis.Client
+	rbtApi  *config.RbtApi
+	frsApi  *config.FrsApi
+}
+
+func NewEventWriter(
+	logger *slog.Logger,
+	storage storage.EventStore,
+	images storage.ImageStore,
+	repo *repository.PostgresRepository,
+	redis *redis.Client,
+	rbtApi *config.RbtApi,
+	frsApi *config.FrsApi,
+) *EventWriter {
+	return &EventWriter{
+		logger:  logger,
+		storage: storage,
+		images:  images,
+		repo:    repo,
+		redis:   redis,
+		rbtApi:  rbtApi,
+		frsApi:  frsApi,
+	}
+}
+
+// Shot camera or FRS best quality screenshot at the time
+func (w *EventWriter) Shot(ctx context.Context, cameraID int, timestamp time.Time) Shot {
+	shot := Shot{ImageUUID: ImageUUIDStub, Preview: PreviewNone}
+
+	camera, err := w.repo.Cameras.GetCamera(ctx, cameraID)
+	if err != nil {
+		w.logger.Warn("Failed to get camera", "cameraID", cameraID, "error", err)
+		return shot
+	}
+
+	// 01 - get screenshot from domophone camera
+	image, err := utils.DownloadFile(w.rbtApi.Internal + camshotURLPath + strconv.Itoa(camera.CameraID))
+	if err != nil {
+		w.logger.Debug("RBT DownloadFile", "err", err)
+	} else {
+		shot.Preview = PreviewIPCam
+	}
+
+	// 02 - get screenshot from FRS
+	if camera.FRS != nil && *camera.FRS != "-" {
+		bqResponse, _ := utils.GetBestQuality(w.frsApi, camera.CameraID, timestamp)
+		if frsImage, face := w.bestQualityImage(bqResponse); frsImage != nil {
+			image = frsImage
+			shot.Preview = PreviewFRS
+			shot.Face = face
+		}
+	}
+
+	return w.SaveShot(ctx, image, timestamp, shot)
+}
+
+// ShotByFRSEvent FRS screenshot of the recognition event
+func (w *EventWriter) ShotByFRSEvent(ctx context.Context, cameraID int, frsEventID string, timestamp time.Time) Shot {
+	shot := Shot{ImageUUID: ImageUUIDStub, Preview: PreviewNone}
+
+	bqResponse, _ := utils.GetBestQualityByEvent(w.frsApi, cameraID, frsEventID)
+	image, face := w.bestQualityImage(bqResponse)
+	if image != nil {
+		shot.Preview = PreviewFRS
+		shot.Face = face
+	}
+
+	return w.SaveShot(ctx, image, timestamp, shot)
+}
+
+// SaveShot save image to redis for push and to the image store for plog, the shot without image is returned as is
+func (w *EventWriter) SaveShot(ctx context.Context, image []byte, timestamp time.Time, shot Shot) Shot {
+	if len(image) == 0 {
+		shot.ImageUUID = ImageUUIDStub
+		shot.Preview = PreviewNone
+		shot.Face = nil
+		return shot
+	}
+
+	// hash for push event
+	shot.Hash = fmt.Sprintf("%x", md5.Sum([]byte(uuid.New().String())))
+	if err := w.redis.SetEx(ctx, "shot_"+shot.Hash, image, pushShotTTL).Err(); err != nil {
+		w.logger.Debug("failed to save screenshot to Redis", "err", err)
+	}
+
+	meta := storage.ImageMeta{
+		ContentType: "image/jpeg",
+		Expire:      timestamp.Add(camshotTTL),
+	}
+	fileID, err := w.images.SaveImage(ctx, image, meta)
+	if err != nil {
+		w.logger.Warn("Failed to save camshot", "err", err)
+		shot.ImageUUID = ImageUUIDStub
+		shot.Preview = PreviewNone
+		shot.Face = nil
+		return shot
+	}
+
+	shot.ImageUUID = utils.ToGUIDv4(fileID)
+	return shot
+}
+
+func (w *EventWriter) bestQualityImage(bqResponse *utils.FRSBestQualityResponse) ([]byte, *Face) {
+	if bqResponse == nil || bqResponse.Data == nil || bqResponse.Data.Screenshot == "" {
+		return nil, nil
+	}
+
+	image, err := utils.DownloadFile(bqResponse.Data.Screenshot)
+	if err != nil {
+		w.logger.Debug("FRS DownloadFile", "err", err)
+		return nil, nil
+	}
+
+	return image, &Face{
+		Left:   bqResponse.Data.Left,
+		Top:    bqResponse.Data.Top,
+		Width:  bqResponse.Data.Width,
+		Height: bqResponse.Data.Height,
+	}
+}
+
+// Write validate and insert plog event, then send push to the flat watchers subscribed to the event type
+func (w *EventWriter) Write(ctx context.Context, event PlogEvent, push Push) error {
+	if event.EventUUID == "" {
+		event.EventUUID = uuid.New().String()
+	}
+	if event.ImageUUID == "" {
+		event.ImageUUID = ImageUUIDStub
+	}
+
+	if err := event.Validate(); err != nil {
+		return fmt.Errorf("invalid plog event: %w", err)
+	}
+
+	row, err := event.Row()
+	if err != nil {
+		return fmt.Errorf("failed to marshal plog event: %w", err)
+	}
+
+	if err := w.storage.InsertPlog(ctx, []storage.PlogRow{row}); err != nil {
+		return fmt.Errorf("failed to insert plog event: %w", err)
+	}
+
+	if event.FlatID != 0 {
+		w.notifyWatchers(ctx, event, push)
+	}
+	return nil
+}
+
+// notifyWatchers flat and address are loaded only if the flat has subscribed watchers
+func (w *EventWriter) notifyWatchers(ctx context.Context, event PlogEvent, push Push) {
+	watchers, err := w.repo.Households.GetWatchersByFlatID(ctx, event.FlatID)
+	if err != nil {
+		w.logger.Warn("Failed to get watchers", "flatID", event.FlatID, "error", err)
+		return
+	}
+
+	eventType := strconv.Itoa(event.Event)
+	var address string
+	for _, watcher := range watchers {
+		if watcher.EventType != eventType {
+			continue
+		}
+
+		if address == "" {
+			address = w.flatAddress(ctx, event)
+		}
+
+		device, err := w.repo.Households.GetMobileDeviceByID(ctx, watcher.DeviceID)
+		if err != nil {
+			w.logger.Warn("Failed to get watcher device", "deviceID", watcher.DeviceID, "error", err)
+			continue
+		}
+
+		msgTitle := "Открытие двери"
+		msgBody := fmt.Sprintf("Адрес: %s\n%s", address, push.Body)
+		go utils.SendPush(push.Hash, msgTitle, msgBody, device.PushToken, device.PushTokenType, device.Platform)
+	}
+}
+
+func (w *EventWriter) flatAddress(ctx context.Context, event PlogEvent) string {
+	house, err := w.repo.Households.GetHouseByEntranceID(ctx, event.Domophone.EntranceID)
+	if err != nil {
+		w.logger.Warn("Failed to get house", "entranceID", event.Domophone.EntranceID, "error", err)
+	}
+
+	flat, err := w.repo.Households.GetFlatByID(ctx, event.FlatID)
+	if err != nil {
+		w.logger.Warn("Failed to get flat", "flatID", event.FlatID, "error", err)
+		return house.HouseFull
+	}
+
+	return house.HouseFull + "кв." + flat.Flat
+}
@@ -2,14 +2,13 @@ package feature
 
 import (
 	"context"
-	"crypto/md5"
 	"encoding/json"
 	"fmt"
 	"github.com/google/uuid"
 	"github.com/kulakoff/event-server-go/internal/app/event-server-go/config"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/events"
 	"github.com/kulakoff/event-server-go/internal/app/event-server-go/repository"
 	"github.com/kulakoff/event-server-go/internal/app/event-server-go/storage"
-	"github.com/kulakoff/event-server-go/internal/app/event-server-go/utils"
 	"github.com/redis/go-redis/v9"
 	"log/slog"
 	"strconv"
@@ -19,12 +18,6 @@ import (
 )
 
 const (
-	PREVIEW_NONE  = 0
-	PREVIEW_IPCAM = 1
-	PREVIEW_FRS   = 2
-
-	TTL_CAMSHOT_HOURS = time.Hour * 24 * 30 * 6
-
 	EVENT_UNANSWERED_CALL      = 1
 	EVENT_ANSWERED_CALL        = 2
 	EVENT_OPENED_BY_KEY        = 3
@@ -33,18 +26,18 @@ const (
 	EVENT_OPENED_BY_CODE       = 6
 	EVENT_OPENED_GATES_BY_CALL = 7
 	EVENT_OPENED_BY_VEHICLE    = 9
-
-	MONGO_SCREENSHOT_NAME = "camshot"
 )
 
-// FIXME:
-const API_URL_RBT = "https://rbt-demo.lanta.me:55544/internal"
-const IMAGE_UUID_STUB = "00000000-0000-0000-0000-000000000000"
 const (
 	DOOR_MAIN      = 0
 	DOOR_SECONDARY = 1
 )
 
+// UsageMarker key and flat last usage, handlers.UsageUpdater
+type UsageMarker interface {
+	Used(event int, detail, host string, flatIDs []int, timestamp time.Time)
+}
+
 // DoorOpenEvent - parse structure from php backend
 type DoorOpenEvent struct {
 	Date        int64  `json:"date"`
@@ -69,21 +62,25 @@ type StreamProcessor struct {
 	logger  *slog.Logger
 	redis   *storage.RedisStorage
 	fsFiles *storage.MongoHandler
-	storage *storage.ClickhouseHttpClient
+	storage storage.EventStore
 	config  StreamProcessorConfig
 	wg      sync.WaitGroup
 	repo    *repository.PostgresRepository
 	frsApi  *config.FrsApi
+	writer  *events.EventWriter
+	usage   UsageMarker
 }
 
 func NewStreamProcessor(
 	logger *slog.Logger,
 	redisStorage *storage.RedisStorage,
 	fsFiles *storage.MongoHandler,
-	storage *storage.ClickhouseHttpClient,
+	storage storage.EventStore,
 	config StreamProcessorConfig,
 	repo *repository.PostgresRepository,
 	frsApi *config.FrsApi,
+	writer *events.EventWriter,
+	usage UsageMarker,
 ) *StreamProcessor {
 	return &StreamProcessor{
 		logger:  logger,
@@ -93,6 +90,8 @@ func NewStreamProcessor(
 		config:  config,
 		repo:    repo,
 		frsApi:  frsApi,
+		writer:  writer,
+		usage:   usage,
 	}
 }
 
@@ -267,10 +266,6 @@ func (s *StreamProcessor) processOpenByAPP(ctx context.Context, event DoorOpenEv
 
 	s.logger.Debug("processOpenByAPP")
 
-	var faceData map[string]interface{} // face data stub
-	imageGUIDv4 := ""
-	preview := PREVIEW_IPCAM
-
 	// get entrance
 	entrance, err := s.repo.Households.GetEntrance(ctx, event.DomophoneId, event.Door)
 	if err != nil {
@@ -279,65 +274,11 @@ func (s *StreamProcessor) processOpenByAPP(ctx context.Context, event DoorOpenEv
 	}
 
 	// Entrance not usage camera
-	if entrance.CameraID == nil {
-		s.logger.Debug("Entrance not usage camera, set PREVIEW mode 0")
-		preview = PREVIEW_NONE
-		imageGUIDv4 = IMAGE_UUID_STUB
-	}
-
-	// camera found
+	shot := events.Shot{ImageUUID: events.ImageUUIDStub, Preview: events.PreviewNone}
 	if entrance.CameraID != nil {
-		camera, err := s.repo.Cameras.GetCamera(context.Background(), *entrance.CameraID)
-		if err != nil {
-			s.logger.Error("Failed to get camera")
-			return false
-		}
-
-		// 01 - get screenshot from domophone camera
-		imgURL := API_URL_RBT + "/frs/camshot/" + strconv.Itoa(camera.CameraID)
-		s.logger.Debug("Open by RFID", "_imageUrl >>", imgURL)
-
-		var camScreenShot []byte
-		camScreenShot, err = utils.DownloadFile(imgURL)
-		if err != nil {
-			preview = PREVIEW_NONE
-			s.logger.Warn("Failed to get image from camera API, set preview mode 0", "err", err)
-		}
-
-		// check best quality image from FRS service
-		bqResponse, _ := utils.GetBestQuality(s.frsApi, *entrance.CameraID, time.Now())
-		if bqResponse != nil {
-			camScreenShot, err = utils.DownloadFile(bqResponse.Data.Screenshot)
-			if err != nil {
-				s.logger.Error("Failed to download screenshot")
-				return false
-			}
-
-			preview = PREVIEW_FRS
-
-			faceData = map[string]interface{}{
-				"left":   bqResponse.Data.Left,
-				"top":    bqResponse.Data.Top,
-				"width":  bqResponse.Data.Width,
-				"height": bqResponse.Data.Height,
-			}
-		} else {
-			s.logger.Debug("NO image from FRS service")
-		}
-
-		metadata := map[string]interface{}{
-			"contentType": "image/jpeg",
-			"expire":      int32(time.Unix(event.Date, 0).Add(TTL_CAMSHOT_HOURS).Unix()),
-		}
-
-		// save data to MongoDb
-		fileId, err := s.fsFiles.SaveFile(MONGO_SCREENSHOT_NAME, metadata, camScreenShot)
-		if err != nil {
-			s.logger.Debug("MongoDB SaveFile", "err", err)
-		}
-
-		// generate image_uuid
-		imageGUIDv4 = utils.ToGUIDv4(fileId)
+		shot = s.writer.Shot(ctx, *entrance.CameraID, time.Unix(event.Date, 0))
+	} else {
+		s.logger.Debug("Entrance not usage camera, set PREVIEW mode 0")
 	}
 
 	flatList, err := s.repo.Households.FlatIDsByDomophoneIDAndPhone(ctx, event.DomophoneId, event.Detail)
@@ -346,42 +287,31 @@ func (s *StreamProcessor) processOpenByAPP(ctx context.Context, event DoorOpenEv
 		return false
 	}
 
+	plogEvent := events.PlogEvent{
+		Date:      event.Date,
+		ImageUUID: shot.ImageUUID,
+		Domophone: events.NewPlogDomophone(event.DomophoneId, entrance),
+		Event:     EVENT_OPENED_BY_APP,
+		Opened:    1,
+		Face:      shot.Face,
+		Phones:    events.Phones{UserPhone: event.Detail},
+		Preview:   shot.Preview,
+	}
 	for _, flatID := range flatList {
-		eventGUIDv4 := uuid.New().String()
-		plogData := map[string]interface{}{
-			"date":       event.Date,
-			"event_uuid": eventGUIDv4,
-			"hidden":     0,
-			"image_uuid": imageGUIDv4,
-			"flat_id":    flatID,
-			"domophone": map[string]interface{}{
-				"camera_id":             *entrance.CameraID,
-				"domophone_description": entrance.Entrance,
-				"domophone_id":          event.DomophoneId,
-				"domophone_output":      entrance.DomophoneOutput,
-				"entrance_id":           entrance.HouseEntranceID,
-				"house_id":              entrance.AddressHouseID,
-			},
-			"event":  EVENT_OPENED_BY_APP,
-			"opened": 1, // bool
-			"face":   faceData,
-			"rfid":   "",
-			"code":   "",
-			"phones": map[string]interface{}{
-				"user_phone": event.Detail,
-			},
-			"preview": preview, // 0 no image, 1 - image from DVR, 2 - image from FRS
-		}
-
-		plogDataString, err := json.Marshal(plogData)
-		if err != nil {
-			s.logger.Debug("Failed marshal JSON")
+		plogEvent.EventUUID = uuid.New().String()
+		plogEvent.FlatID = flatID
+		if err := s.writer.Write(ctx, plogEvent, events.Push{Hash: shot.Hash}); err != nil {
+			s.logger.Error("Error insert to plog", "err", err)
 		}
+	}
 
-		err = s.storage.Insert("plog", string(plogDataString))
-		if err != nil {
-			s.logger.Error("Error insert to plog", "err", err)
+	// app opening of one flat is marked by the flat, of several flats by the user phone
+	if len(flatList) > 0 {
+		detail := event.Detail
+		if len(flatList) == 1 {
+			detail = strconv.Itoa(flatList[0])
 		}
+		s.usage.Used(EVENT_OPENED_BY_APP, detail, event.IP, flatList, time.Unix(event.Date, 0))
 	}
 
 	return true
@@ -390,7 +320,6 @@ func (s *StreamProcessor) processOpenByAPP(ctx context.Context, event DoorOpenEv
 // processOpenByFRS - process events open by FRS service
 func (s *StreamProcessor) processOpenByFRS(ctx context.Context, event DoorOpenEvent) bool {
 	var faceId, frsEventId string
-	var faceData map[string]interface{}
 	door := DOOR_MAIN
 
 	if event.EventType == EVENT_OPENED_BY_FACE {
@@ -404,126 +333,51 @@ func (s *StreamProcessor) processOpenByFRS(ctx context.Context, event DoorOpenEv
 		return false
 	}
 
-	eventGUIDv4 := uuid.New().String()
-
 	// get entrance
 	entrance, err := s.repo.Households.GetEntrance(ctx, event.DomophoneId, door)
 	if err != nil {
 		s.logger.Error("Failed to get entrance")
 		return false
 	}
-
-	// ----- get home data
-	house, err := s.repo.Households.GetHouseByEntranceID(ctx, entrance.HouseEntranceID)
-	if err != nil {
-		s.logger.Warn("Failed to get house", "error", err)
-	}
-
-	camera, err := s.repo.Cameras.GetCamera(ctx, *entrance.CameraID)
-	if err != nil {
-		s.logger.Error("Failed to get camera")
+	if entrance.CameraID == nil {
+		s.logger.Error("Failed to get camera", "entranceId", entrance.HouseEntranceID)
 		return false
 	}
 
 	// get screenShot from FRS service
-	var camScreenShot []byte
-	bqResponse, _ := utils.GetBestQualityByEvent(s.frsApi, *entrance.CameraID, frsEventId)
-	if bqResponse != nil {
-		camScreenShot, err = utils.DownloadFile(bqResponse.Data.Screenshot)
-		faceData = map[string]interface{}{
-			"left":   bqResponse.Data.Left,
-			"top":    bqResponse.Data.Top,
-			"width":  bqResponse.Data.Width,
-			"height": bqResponse.Data.Height,
-		}
-	}
+	shot := s.writer.ShotByFRSEvent(ctx, *entrance.CameraID, frsEventId, time.Unix(event.Date, 0))
 
-	// push crutch
-	// hash for push event
-	hash := fmt.Sprintf("%x", md5.Sum([]byte(uuid.New().String())))
-	shotKey := "shot_" + hash
-	if err := s.redis.Client.SetEx(ctx, shotKey, camScreenShot, 15*60*time.Second).Err(); err != nil {
-		s.logger.Debug("failed to save screenshot to Redis", "err", err)
-	}
-
-	metadata := map[string]interface{}{
-		"contentType": "image/jpeg",
-		"expire":      int32(time.Unix(event.Date, 0).Add(TTL_CAMSHOT_HOURS).Unix()),
-	}
-
-	// save data to MongoDb
-	fileId, err := s.fsFiles.SaveFile(MONGO_SCREENSHOT_NAME, metadata, camScreenShot)
+	// flats of the entrance linked to the face
+	flatList, err := s.repo.Households.GetFlatsByFaceIdFrs(ctx, faceId, strconv.Itoa(entrance.HouseEntranceID))
 	if err != nil {
-		s.logger.Debug("MongoDB SaveFile", "err", err)
-	}
-
-	// generate image_uuid
-	imageGUIDv4 := utils.ToGUIDv4(fileId)
-
-	flatList, _ := s.repo.Households.GetFlatsByFaceIdFrs(ctx, faceId, strconv.Itoa(event.DomophoneId))
-
-	// push crutch
-	flatDetail, err := s.repo.Households.GetFlatByID(ctx, flatList[0])
-	if err != nil {
-		s.logger.Debug("Failed to get flat", "err", err)
+		s.logger.Debug("Failed to get flatIDs", "err", err)
 		return false
 	}
-
-	plogData := map[string]interface{}{
-		"date":       event.Date,
-		"event_uuid": eventGUIDv4,
-		"hidden":     0,
-		"image_uuid": imageGUIDv4,
-		"flat_id":    flatList[0],
-		"domophone": map[string]interface{}{
-			"camera_id":             camera.CameraID,
-			"domophone_description": entrance.Entrance,
-			"domophone_id":          event.DomophoneId,
-			"domophone_output":      entrance.DomophoneOutput,
-			"entrance_id":           entrance.HouseEntranceID,
-			"house_id":              entrance.AddressHouseID,
-		},
-		"event":   EVENT_OPENED_BY_FACE,
-		"opened":  1, // bool
-		"face":    faceData,
-		"rfid":    "",
-		"code":    "",
-		"phones":  map[string]interface{}{},
-		"preview": PREVIEW_FRS, // 0 no image, 1 - image from DVR, 2 - image from FRS
-	}
-	plogDataString, err := json.Marshal(plogData)
-	if err != nil {
-		s.logger.Debug("Failed marshal JSON")
-	}
-
-	err = s.storage.Insert("plog", string(plogDataString))
-	if err != nil {
-		s.logger.Error("Error insert to plog", "err", err)
+	if len(flatList) == 0 {
+		s.logger.Warn("No flats found by face", "faceId", faceId, "entranceId", entrance.HouseEntranceID)
+		return true
 	}
 
-	// FIXME: push crutch
-	// get watchers
-	watchers, err := s.repo.Households.GetWatchersByFlatID(ctx, flatDetail.HouseFlatID)
-	if err != nil {
-		s.logger.Warn("Failed to get watchers", "error", err)
+	plogEvent := events.PlogEvent{
+		Date:      event.Date,
+		ImageUUID: shot.ImageUUID,
+		Domophone: events.NewPlogDomophone(event.DomophoneId, entrance),
+		Event:     EVENT_OPENED_BY_FACE,
+		Opened:    1,
+		Face:      shot.Face,
+		Preview:   shot.Preview,
 	}
-
-	if watchers != nil && len(watchers) > 0 {
-		for _, watcher := range watchers {
-			// watch FACE EVENT
-			if watcher.EventType == strconv.Itoa(EVENT_OPENED_BY_FACE) {
-				msgTitle := "Открытие двери"
-				addressStr := house.HouseFull + "кв." + flatDetail.Flat
-				msgBody := fmt.Sprintf("Адрес: %s\nПерсона: %s\n", addressStr, "имя жильца")
-				device, _ := s.repo.Households.GetMobileDeviceByID(ctx, watcher.DeviceID)
-
-				go utils.SendPush(hash, msgTitle, msgBody, device.PushToken, device.PushTokenType, device.Platform)
-			}
+	push := events.Push{Body: fmt.Sprintf("Персона: %s", "имя жильца"), Hash: shot.Hash}
+	for _, flatID := range flatList {
+		plogEvent.EventUUID = uuid.New().String()
+		plogEvent.FlatID = flatID
+		if err := s.writer.Write(ctx, plogEvent, push); err != nil {
+			s.logger.Error("Error insert to plog", "err", err)
 		}
-	} else {
-		s.logger.Debug("Watchers not found")
 	}
 
+	s.usage.Used(EVENT_OPENED_BY_FACE, faceId, event.IP, flatList, time.Unix(event.Date, 0))
+
 	return true
 }
 
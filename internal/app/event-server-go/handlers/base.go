@@ -0,0 +1,407 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/config"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/events"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/repository"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/repository/models"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/services/frs"
+	storage2 "github.com/kulakoff/event-server-go/internal/app/event-server-go/storage"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/syslog_custom"
+	"log/slog"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// baseHandler dependencies and event processing shared by the panel handlers
+type baseHandler struct {
+	logger    *slog.Logger
+	unit      string // syslog unit: beward, qtech, ...
+	spamWords []string
+	storage   storage2.EventStore
+	repo      *repository.PostgresRepository
+	rbtApi    *config.RbtApi
+	frsApi    *config.FrsApi
+	usage     *UsageUpdater
+	writer    *events.EventWriter
+}
+
+// DoorOpenEvent door opened without a call: RFID key, personal code or exit button
+type DoorOpenEvent struct {
+	Timestamp *time.Time
+	Host      string            // domophone IP
+	Domophone *models.Domophone // optional, resolved by Host if nil
+	Door      int               // domophone output: DOOR_MAIN, DOOR_SECONDARY
+	Event     int               // Event.OpenByKey, Event.OpenByCode, Event.OpenByButton
+	Flats     []models.Flat
+	RFID      string
+	Code      string
+	FaceID    string // FRS face id, flats are resolved by the door entrance
+	PushBody  string // event details for watchers push, after the address
+}
+
+func newBaseHandler(deps *Deps, unit string) baseHandler {
+	return baseHandler{
+		logger:    deps.Logger,
+		unit:      unit,
+		spamWords: deps.SpamFilters.Words(unit),
+		storage:   deps.Clickhouse,
+		repo:      deps.Repo,
+		rbtApi:    deps.RbtApi,
+		frsApi:    deps.FrsApi,
+		usage:     deps.Usage,
+		writer:    deps.Writer,
+	}
+}
+
+// resolveHost use host ip from syslog message, the packet source address is NAT address usually
+func (h *baseHandler) resolveHost(srcIP string, message *syslog_custom.SyslogMessage) string {
+	if net.ParseIP(message.HostName) != nil && message.HostName != "127.0.0.1" && srcIP != message.HostName {
+		return message.HostName
+	}
+	return srcIP
+}
+
+// storeSyslog send syslog message to remote storage
+func (h *baseHandler) storeSyslog(host, message string) {
+	storageMessage := storage2.SyslogStorageMessage{
+		Date:  strconv.FormatInt(time.Now().Unix(), 10),
+		Ip:    host,
+		SubId: "",
+		Unit:  h.unit,
+		Msg:   message,
+	}
+
+	if err := h.storage.InsertSyslog(context.Background(), []storage2.SyslogStorageMessage{storageMessage}); err != nil {
+		h.logger.Warn("Failed to insert syslog message", "unit", h.unit, "error", err)
+	}
+}
+
+// processDoorOpen make plog event per flat, save camshot and send push to watchers
+func (h *baseHandler) processDoorOpen(ctx context.Context, event DoorOpenEvent) {
+	// get domophone
+	domophone := event.Domophone
+	if domophone == nil {
+		var err error
+		domophone, err = h.repo.Households.GetDomophone(ctx, "ip", event.Host)
+		if err != nil {
+			h.logger.Warn("Failed to get domophone", "host", event.Host, "error", err)
+			return
+		}
+	}
+
+	// get entrance
+	entrance, err := h.repo.Households.GetEntrance(ctx, domophone.HouseDomophoneID, event.Door)
+	if err != nil {
+		h.logger.Warn("Failed to get entrance", "host", event.Host, "door", event.Door, "error", err)
+		return
+	}
+
+	flats := h.domophoneFlats(ctx, domophone, event.Flats)
+	if event.FaceID != "" && len(flats) == 0 {
+		flats = h.getFlatsByFace(ctx, event.FaceID, entrance.HouseEntranceID)
+	}
+
+	// exit button: no flat, one entrance record, other opens need a flat of the domophone
+	if len(flats) == 0 {
+		if event.Event != Event.OpenByButton {
+			h.logger.Warn("Door open without domophone flat is dropped", "unit", h.unit, "host", event.Host,
+				"event", event.Event, "rfid", event.RFID, "code", event.Code, "faceID", event.FaceID, "flats", len(event.Flats))
+			return
+		}
+		flats = []models.Flat{{}}
+	}
+
+	shot := events.Shot{ImageUUID: events.ImageUUIDStub, Preview: events.PreviewNone}
+	if entrance.CameraID != nil {
+		shot = h.writer.Shot(ctx, *entrance.CameraID, *event.Timestamp)
+	} else {
+		h.logger.Warn("Failed to get camera id", "host", event.Host)
+	}
+
+	plogEvent := events.PlogEvent{
+		Date:      event.Timestamp.Unix(),
+		ImageUUID: shot.ImageUUID,
+		Domophone: events.NewPlogDomophone(domophone.HouseDomophoneID, entrance),
+		Event:     event.Event,
+		Opened:    1,
+		Face:      shot.Face,
+		RFID:      event.RFID,
+		Code:      event.Code,
+		Preview:   shot.Preview,
+	}
+	for _, flat := range flats {
+		// one event_uuid per flat row, hidden by the flat independently
+		plogEvent.EventUUID = uuid.New().String()
+		plogEvent.FlatID = flat.HouseFlatID
+		if err := h.writer.Write(ctx, plogEvent, events.Push{Body: event.PushBody, Hash: shot.Hash}); err != nil {
+			h.logger.Warn("Failed to write plog event", "unit", h.unit, "flatID", flat.HouseFlatID, "error", err)
+		}
+	}
+
+	h.markUsage(event, flats)
+}
+
+// markUsage key last seen and flats last opened, exit button is not counted
+func (h *baseHandler) markUsage(event DoorOpenEvent, flats []models.Flat) {
+	var detail string
+	switch event.Event {
+	case Event.OpenByKey:
+		detail = event.RFID
+	case Event.OpenByCode:
+		detail = event.Code
+	case Event.OpenByFaceID:
+		detail = event.FaceID
+	case Event.OpenByApp:
+	default:
+		return
+	}
+
+	ids := flatIDs(flats)
+	if detail == "" && len(ids) == 1 {
+		detail = strconv.Itoa(ids[0])
+	}
+
+	h.usage.Used(event.Event, detail, event.Host, ids, *event.Timestamp)
+}
+
+// flatIDs ids of the found flats, the exit button stub flat is skipped
+func flatIDs(flats []models.Flat) []int {
+	ids := make([]int, 0, len(flats))
+	for _, flat := range flats {
+		if flat.HouseFlatID != 0 {
+			ids = append(ids, flat.HouseFlatID)
+		}
+	}
+	return ids
+}
+
+// domophoneFlats flats served by the domophone entrances: keys and codes are shared by flats of other houses
+func (h *baseHandler) domophoneFlats(ctx context.Context, domophone *models.Domophone, flats []models.Flat) []models.Flat {
+	if len(flats) == 0 {
+		return nil
+	}
+
+	served, err := h.repo.Households.GetDomophoneFlatIDs(ctx, domophone.HouseDomophoneID, flatIDs(flats))
+	if err != nil {
+		// fail closed: the event is dropped rather than sent to flats of other houses
+		h.logger.Warn("Failed to get domophone flats", "domophoneID", domophone.HouseDomophoneID, "error", err)
+		return nil
+	}
+
+	// one row per flat, the key may be added to the flat twice
+	result := make([]models.Flat, 0, len(served))
+	for _, flat := range flats {
+		if slices.Contains(served, flat.HouseFlatID) && !slices.Contains(flatIDs(result), flat.HouseFlatID) {
+			result = append(result, flat)
+		}
+	}
+	return result
+}
+
+// openByKey door opened by RFID key: update last seen and make plog event for the key flats
+func (h *baseHandler) openByKey(ctx context.Context, event DoorOpenEvent, key string) {
+	rfidKey := normalizeRFIDKey(key)
+	if rfidKey == "" {
+		h.logger.Warn("RFID key not found", "unit", h.unit, "host", event.Host, "key", key)
+		return
+	}
+
+	flats, err := h.repo.Households.GetFlatIDsByRFID_new(ctx, rfidKey)
+	if err != nil {
+		h.logger.Warn("Failed to get flats by RFID", "rfid", rfidKey, "error", err)
+		return
+	}
+
+	event.Event = Event.OpenByKey
+	event.Flats = flats
+	event.RFID = rfidKey
+	event.PushBody = fmt.Sprintf("Ключ: %s", rfidKey)
+	h.processDoorOpen(ctx, event)
+}
+
+// openByCode door opened by flat open code
+func (h *baseHandler) openByCode(ctx context.Context, event DoorOpenEvent, code string) {
+	flats, err := h.repo.Households.GetFlatIDsByCode_new(ctx, code)
+	if err != nil {
+		h.logger.Warn("Failed to get flats by code", "error", err)
+		return
+	}
+
+	event.Event = Event.OpenByCode
+	event.Flats = flats
+	event.Code = code
+	event.PushBody = fmt.Sprintf("Код: %s", code)
+	h.processDoorOpen(ctx, event)
+}
+
+// getFlatsByFace flats of the entrance linked to FRS face
+func (h *baseHandler) getFlatsByFace(ctx context.Context, faceID string, entranceID int) []models.Flat {
+	flatIDs, err := h.repo.Households.GetFlatsByFaceIdFrs(ctx, faceID, strconv.Itoa(entranceID))
+	if err != nil {
+		h.logger.Warn("Failed to get flats by face", "faceID", faceID, "error", err)
+		return nil
+	}
+
+	flats := make([]models.Flat, 0, len(flatIDs))
+	for _, flatID := range flatIDs {
+		flat, err := h.repo.Households.GetFlatByID(ctx, flatID)
+		if err != nil {
+			h.logger.Warn("Failed to get flat", "flatID", flatID, "error", err)
+			continue
+		}
+		flats = append(flats, flat)
+	}
+	return flats
+}
+
+// normalizeRFIDKey 14 hex digits key as stored in houses_rfids, panels send keys without leading zeros
+func normalizeRFIDKey(key string) string {
+	key = strings.ToUpper(strings.TrimSpace(key))
+	if key == "" || len(key) > 14 {
+		return ""
+	}
+	if _, err := strconv.ParseUint(key, 16, 64); err != nil {
+		return ""
+	}
+	return strings.Repeat("0", 14-len(key)) + key
+}
+
+// FilterMessage skip not informational syslog message
+func (h *baseHandler) FilterMessage(message string) bool {
+	for _, word := range h.spamWords {
+		if strings.Contains(message, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// HandleMotionDetection send motion start or stop to FRS, if enabled for domophone camera
+func (h *baseHandler) HandleMotionDetection(timestamp *time.Time, host string, motionActive bool) {
+	h.logger.Debug("HandleMotionDetection", "host", host, "motionActive", motionActive)
+
+	camera, err := h.repo.Cameras.GetCameraByIP(context.Background(), host)
+	if err != nil || camera == nil {
+		h.logger.Debug("Motion detect, camera not found", "host", host, "error", err)
+		return
+	}
+
+	// check if FRS enable
+	if camera.FRS != nil && *camera.FRS != "-" {
+		err := frs.MotionDetection(camera.CameraID, motionActive, *camera.FRS)
+		if err != nil {
+			h.logger.Warn("Failed to send motion detect to FRS service", "error", err)
+			return
+		}
+	}
+}
+
+func (h *baseHandler) prepareFinalCallEvent(callData *CallData) {
+	h.logger.Info("🎃 - prepareFinalCallEvent start")
+	startTime := time.Now()
+
+	// Wait until the screenshots are ready (maximum 15 seconds)
+	for i := 0; i < 30; i++ {
+		callData.callMutex.Lock()
+		ready := callData.screenshotsReady
+		callData.callMutex.Unlock()
+
+		if ready {
+			break
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+
+	callData.callMutex.Lock()
+	if !callData.screenshotsReady {
+		h.logger.Warn("Screenshots not ready for final event", "callID", callData.CallID)
+	}
+	callData.callMutex.Unlock()
+
+	// make final event
+	h.saveFinalCallEvent(callData)
+
+	h.logger.Info("Call processing completed",
+		"callID", callData.CallID,
+		"duration", time.Since(startTime))
+}
+
+// getCallScreenshots camera or FRS best quality screenshot at the call start
+func (h *baseHandler) getCallScreenshots(callData *CallData) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	h.logger.Info("Starting call screenshots processing", "callId", callData.CallID)
+
+	shot := events.Shot{ImageUUID: events.ImageUUIDStub, Preview: events.PreviewNone}
+	if callData.CameraID > 0 {
+		shot = h.writer.Shot(ctx, callData.CameraID, *callData.StartTime)
+	} else {
+		h.logger.Warn("Failed to get screenshot, no camera", "callId", callData.CallID)
+	}
+
+	callData.callMutex.Lock()
+	callData.imageUUID = shot.ImageUUID
+	callData.Face = shot.Face
+	callData.PreviewType = shot.Preview
+	callData.screenshotsReady = true
+	callData.callMutex.Unlock()
+
+	h.logger.Info("Call screenshot processed", "callId", callData.CallID, "imageUUID", shot.ImageUUID)
+}
+
+func (h *baseHandler) saveFinalCallEvent(callData *CallData) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	h.logger.Info("🎃 - saveFinalCallEvent start")
+	// Определяем тип события на основе того, что произошло во время звонка
+	eventType := Event.NotAnswered
+	opened := 0
+
+	if callData.Answered && callData.DoorOpened {
+		eventType = Event.Answered
+		opened = 1
+	} else if callData.Answered {
+		eventType = Event.Answered
+	}
+
+	if callData.Domophone == nil || callData.Entrance == nil {
+		h.logger.Warn("Final call event without domophone entrance", "callID", callData.CallID, "host", callData.DomophoneIP)
+		return
+	}
+
+	callData.callMutex.Lock()
+	plogEvent := events.PlogEvent{
+		Date:      callData.StartTime.Unix(),
+		EventUUID: uuid.New().String(),
+		ImageUUID: callData.imageUUID,
+		FlatID:    callData.FlatID,
+		Domophone: events.NewPlogDomophone(callData.Domophone.HouseDomophoneID, callData.Entrance),
+		Event:     eventType,
+		Opened:    opened,
+		Face:      callData.Face,
+		Preview:   callData.PreviewType,
+		CallInfo:  callData.callInfo(),
+	}
+	callData.callMutex.Unlock()
+
+	if err := h.writer.Write(ctx, plogEvent, events.Push{}); err != nil {
+		h.logger.Warn("Failed to write final call event", "callID", callData.CallID, "error", err)
+		return
+	}
+
+	h.logger.Info("🎃 - Final call event saved to plog",
+		"callID", callData.CallID,
+		"apartment", callData.Apartment,
+		"eventType", eventType,
+		"answered", callData.Answered,
+		"doorOpened", callData.DoorOpened)
+}
@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// CallState Beward call lifecycle: ringing -> early -> connected -> door-opened -> ended
+type CallState int
+
+const (
+	CallRinging CallState = iota
+	CallEarly
+	CallConnected
+	CallDoorOpened
+	CallEnded
+)
+
+var callStateNames = [...]string{"ringing", "early", "connected", "door-opened", "ended"}
+
+func (s CallState) String() string {
+	if s < 0 || int(s) >= len(callStateNames) {
+		return "unknown"
+	}
+	return callStateNames[s]
+}
+
+// callStateTimeouts max time in the state without messages, then the sweeper finalizes the call
+var callStateTimeouts = map[CallState]time.Duration{
+	CallRinging:    90 * time.Second,
+	CallEarly:      90 * time.Second,
+	CallConnected:  10 * time.Minute,
+	CallDoorOpened: 2 * time.Minute,
+	CallEnded:      10 * time.Second, // waiting for "All calls are done"
+}
+
+const callSweepInterval = 5 * time.Second
+
+// callKey Beward call id is unique per panel only
+type callKey struct {
+	host   string
+	callID int
+}
+
+// callTracker active calls keyed by domophone IP and Beward call id, persisted to store if set
+type callTracker struct {
+	mu    sync.Mutex
+	calls map[callKey]*CallData
+	store *callStore
+}
+
+func newCallTracker(store *callStore) *callTracker {
+	return &callTracker{
+		calls: make(map[callKey]*CallData),
+		store: store,
+	}
+}
+
+// get active call
+func (t *callTracker) get(host string, callID int) (*CallData, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	callData, exists := t.calls[callKey{host, callID}]
+	return callData, exists
+}
+
+// findSIPCall Beward call id by SIP call number
+func (t *callTracker) findSIPCall(host string, sipCallID int) (int, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for key, callData := range t.calls {
+		// SIP call number is known after the first SIP state line
+		if key.host == host && len(callData.SIPStates) > 0 && callData.SIPCallID == sipCallID {
+			return key.callID, true
+		}
+	}
+	return 0, false
+}
+
+// add register call in ringing state, returns the active call if it is already registered
+func (t *callTracker) add(callData *CallData, now time.Time) (*CallData, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := callKey{callData.DomophoneIP, callData.CallID}
+	if active, exists := t.calls[key]; exists {
+		return active, false
+	}
+
+	callData.State = CallRinging
+	callData.StateTime = now
+	t.calls[key] = callData
+	t.save(callData)
+	return callData, true
+}
+
+// advance move call forward to the state and apply update, the state never goes back
+func (t *callTracker) advance(host string, callID int, state CallState, now time.Time, update func(callData *CallData)) (*CallData, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	callData, exists := t.calls[callKey{host, callID}]
+	if !exists {
+		return nil, false
+	}
+
+	if state > callData.State {
+		callData.State = state
+	}
+	callData.StateTime = now
+	if update != nil {
+		update(callData)
+	}
+	t.save(callData)
+	return callData, true
+}
+
+// persist save call changed outside of transitions: camshot, flat
+func (t *callTracker) persist(callData *CallData) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// finished calls are not saved again
+	if t.calls[callKey{callData.DomophoneIP, callData.CallID}] == callData {
+		t.save(callData)
+	}
+}
+
+// remove finished call, the stored state is deleted before the final event
+func (t *callTracker) remove(host string, callID int) (*CallData, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := callKey{host, callID}
+	callData, exists := t.calls[key]
+	if exists {
+		delete(t.calls, key)
+		t.delete(callData)
+	}
+	return callData, exists
+}
+
+// expired remove calls with state timeout passed
+func (t *callTracker) expired(now time.Time) []*CallData {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var expired []*CallData
+	for key, callData := range t.calls {
+		if now.Sub(callData.StateTime) > callStateTimeouts[callData.State] {
+			expired = append(expired, callData)
+			delete(t.calls, key)
+			t.delete(callData)
+		}
+	}
+	return expired
+}
+
+// restore stored calls after restart, returns restored calls count
+func (t *callTracker) restore(ctx context.Context) (int, error) {
+	if t.store == nil {
+		return 0, nil
+	}
+
+	calls, err := t.store.load(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	restored := 0
+	for _, callData := range calls {
+		key := callKey{callData.DomophoneIP, callData.CallID}
+		if _, exists := t.calls[key]; !exists {
+			t.calls[key] = callData
+			restored++
+		}
+	}
+	return restored, nil
+}
+
+// runStore write call states to the store until ctx is done
+func (t *callTracker) runStore(ctx context.Context) {
+	if t.store != nil {
+		t.store.run(ctx)
+	}
+}
+
+// save and delete queue store ops with t.mu locked, the store keeps their order per call
+func (t *callTracker) save(callData *CallData) {
+	if t.store != nil {
+		t.store.save(newCallSnapshot(callData))
+	}
+}
+
+func (t *callTracker) delete(callData *CallData) {
+	if t.store != nil {
+		t.store.delete(callData.DomophoneIP, callData.CallID)
+	}
+}
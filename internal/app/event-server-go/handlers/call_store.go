@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/events"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/repository/models"
+	"github.com/redis/go-redis/v9"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// callStateTTL active call state lifetime in Redis, longer than any call state timeout
+	callStateTTL = 30 * time.Minute
+
+	callStoreTimeout = 2 * time.Second
+
+	// callStoreRetryInterval failed writes are retried with the next queued ops or by interval
+	callStoreRetryInterval = 5 * time.Second
+)
+
+// callSnapshot call state stored to Redis
+type callSnapshot struct {
+	CallID           int                   `json:"call_id"`
+	Apartment        int                   `json:"apartment"`
+	DomophoneIP      string                `json:"domophone_ip"`
+	StartTime        *time.Time            `json:"start_time"`
+	EndTime          *time.Time            `json:"end_time,omitempty"`
+	Answered         bool                  `json:"answered"`
+	DoorOpened       bool                  `json:"door_opened"`
+	CallType         string                `json:"call_type"`
+	State            CallState             `json:"state"`
+	StateTime        time.Time             `json:"state_time"`
+	AnswerTime       *time.Time            `json:"answer_time,omitempty"`
+	SIPCallID        int                   `json:"sip_call_id"`
+	SIPStates        []SIPState            `json:"sip_states,omitempty"`
+	DisconnectReason string                `json:"disconnect_reason,omitempty"`
+	DTMF             string                `json:"dtmf,omitempty"`
+	CameraID         int                   `json:"camera_id"`
+	CameraFRS        string                `json:"camera_frs"`
+	Domophone        *models.Domophone     `json:"domophone"`
+	Entrance         *models.HouseEntrance `json:"entrance"`
+	FlatID           int                   `json:"flat_id"`
+	Face             *events.Face          `json:"face,omitempty"`
+	PreviewType      int                   `json:"preview_type"`
+	ImageUUID        string                `json:"image_uuid,omitempty"`
+	ScreenshotsReady bool                  `json:"screenshots_ready"`
+}
+
+func newCallSnapshot(callData *CallData) callSnapshot {
+	callData.callMutex.Lock()
+	defer callData.callMutex.Unlock()
+
+	return callSnapshot{
+		CallID:           callData.CallID,
+		Apartment:        callData.Apartment,
+		DomophoneIP:      callData.DomophoneIP,
+		StartTime:        callData.StartTime,
+		EndTime:          callData.EndTime,
+		Answered:         callData.Answered,
+		DoorOpened:       callData.DoorOpened,
+		CallType:         callData.CallType,
+		State:            callData.State,
+		StateTime:        callData.StateTime,
+		AnswerTime:       callData.AnswerTime,
+		SIPCallID:        callData.SIPCallID,
+		SIPStates:        append([]SIPState(nil), callData.SIPStates...),
+		DisconnectReason: callData.DisconnectReason,
+		DTMF:             callData.DTMF,
+		CameraID:         callData.CameraID,
+		CameraFRS:        callData.CameraFRS,
+		Domophone:        callData.Domophone,
+		Entrance:         callData.Entrance,
+		FlatID:           callData.FlatID,
+		Face:             callData.Face,
+		PreviewType:      callData.PreviewType,
+		ImageUUID:        callData.imageUUID,
+		ScreenshotsReady: callData.screenshotsReady,
+	}
+}
+
+// callData restored call, camshot not taken before restart is not taken again
+func (s callSnapshot) callData() *CallData {
+	return &CallData{
+		CallID:           s.CallID,
+		Apartment:        s.Apartment,
+		DomophoneIP:      s.DomophoneIP,
+		StartTime:        s.StartTime,
+		EndTime:          s.EndTime,
+		Answered:         s.Answered,
+		DoorOpened:       s.DoorOpened,
+		CallType:         s.CallType,
+		State:            s.State,
+		StateTime:        s.StateTime,
+		AnswerTime:       s.AnswerTime,
+		SIPCallID:        s.SIPCallID,
+		SIPStates:        s.SIPStates,
+		DisconnectReason: s.DisconnectReason,
+		DTMF:             s.DTMF,
+		CameraID:         s.CameraID,
+		CameraFRS:        s.CameraFRS,
+		Domophone:        s.Domophone,
+		Entrance:         s.Entrance,
+		FlatID:           s.FlatID,
+		Face:             s.Face,
+		PreviewType:      s.PreviewType,
+		imageUUID:        s.ImageUUID,
+		screenshotsReady: true,
+	}
+}
+
+// callStore active calls in Redis: "call_<unit>_<host>_<callID>" keys with TTL.
+// save and delete only queue the latest op per key, one writer goroutine writes them:
+// Redis round-trips are never made under the call tracker lock
+type callStore struct {
+	logger *slog.Logger
+	client *redis.Client
+	prefix string
+
+	mu      sync.Mutex
+	pending map[string]*callSnapshot // nil snapshot: delete
+	notify  chan struct{}
+}
+
+func newCallStore(logger *slog.Logger, client *redis.Client, unit string) *callStore {
+	return &callStore{
+		logger:  logger,
+		client:  client,
+		prefix:  "call_" + unit + "_",
+		pending: make(map[string]*callSnapshot),
+		notify:  make(chan struct{}, 1),
+	}
+}
+
+func (s *callStore) key(host string, callID int) string {
+	return s.prefix + host + "_" + strconv.Itoa(callID)
+}
+
+// save queue call state, replaces the queued op of the call
+func (s *callStore) save(snapshot callSnapshot) {
+	s.enqueue(s.key(snapshot.DomophoneIP, snapshot.CallID), &snapshot)
+}
+
+// delete queue call state delete, a queued save of the call is dropped
+func (s *callStore) delete(host string, callID int) {
+	s.enqueue(s.key(host, callID), nil)
+}
+
+func (s *callStore) enqueue(key string, snapshot *callSnapshot) {
+	s.mu.Lock()
+	s.pending[key] = snapshot
+	s.mu.Unlock()
+
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+// run write queued ops until ctx is done, then write the rest once
+func (s *callStore) run(ctx context.Context) {
+	ticker := time.NewTicker(callStoreRetryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.flush()
+			return
+		case <-s.notify:
+		case <-ticker.C:
+		}
+		s.flush()
+	}
+}
+
+// flush write queued ops, the first failed write stops the pass: Redis is down.
+// Failed and not written ops are queued again unless the call got a newer op meanwhile,
+// a late save never restores a deleted call
+func (s *callStore) flush() {
+	s.mu.Lock()
+	ops := s.pending
+	s.pending = make(map[string]*callSnapshot)
+	s.mu.Unlock()
+
+	var err error
+	for key, snapshot := range ops {
+		if err = s.write(key, snapshot); err != nil {
+			break
+		}
+		delete(ops, key)
+	}
+	if err == nil {
+		return
+	}
+
+	s.logger.Warn("Failed to write call states, retry", "ops", len(ops), "error", err)
+
+	s.mu.Lock()
+	for key, snapshot := range ops {
+		if _, newer := s.pending[key]; !newer {
+			s.pending[key] = snapshot
+		}
+	}
+	s.mu.Unlock()
+}
+
+func (s *callStore) write(key string, snapshot *callSnapshot) error {
+	ctx, cancel := context.WithTimeout(context.Background(), callStoreTimeout)
+	defer cancel()
+
+	if snapshot == nil {
+		return s.client.Del(ctx, key).Err()
+	}
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		// not retried
+		s.logger.Warn("Failed to marshal call state", "callID", snapshot.CallID, "error", err)
+		return nil
+	}
+	return s.client.SetEx(ctx, key, data, callStateTTL).Err()
+}
+
+// load all stored calls
+func (s *callStore) load(ctx context.Context) ([]*CallData, error) {
+	var calls []*CallData
+
+	iter := s.client.Scan(ctx, 0, s.prefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		data, err := s.client.Get(ctx, iter.Val()).Bytes()
+		if err != nil {
+			// expired after scan
+			continue
+		}
+
+		var snapshot callSnapshot
+		if err := json.Unmarshal(data, &snapshot); err != nil {
+			s.logger.Warn("Failed to parse call state", "key", iter.Val(), "error", err)
+			continue
+		}
+		calls = append(calls, snapshot.callData())
+	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("failed to scan call states: %w", err)
+	}
+
+	return calls, nil
+}
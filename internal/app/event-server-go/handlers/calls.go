@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/repository/models"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// apartmentCallTimeout call without end event is finished on the next call start
+const apartmentCallTimeout = 5 * time.Minute
+
+// apartmentCalls active calls of panels without call id in messages, key: domophone ip and apartment
+type apartmentCalls struct {
+	mu    sync.Mutex
+	calls map[string]*CallData
+	seq   int
+}
+
+func newApartmentCalls() *apartmentCalls {
+	return &apartmentCalls{calls: make(map[string]*CallData)}
+}
+
+// start register new call, returns calls finished without end event
+func (c *apartmentCalls) start(callData *CallData) []*CallData {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.seq++
+	callData.CallID = c.seq
+	key := apartmentCallKey(callData.DomophoneIP, callData.Apartment)
+
+	var stale []*CallData
+	for k, call := range c.calls {
+		if k == key || callData.StartTime.Sub(*call.StartTime) > apartmentCallTimeout {
+			stale = append(stale, call)
+			delete(c.calls, k)
+		}
+	}
+
+	c.calls[key] = callData
+	return stale
+}
+
+// update change active call state, false for unknown call
+func (c *apartmentCalls) update(host string, apartment int, update func(callData *CallData)) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	callData, exists := c.calls[apartmentCallKey(host, apartment)]
+	if !exists {
+		return false
+	}
+
+	update(callData)
+	return true
+}
+
+// end remove active call
+func (c *apartmentCalls) end(host string, apartment int) (*CallData, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := apartmentCallKey(host, apartment)
+	callData, exists := c.calls[key]
+	delete(c.calls, key)
+	return callData, exists
+}
+
+func apartmentCallKey(host string, apartment int) string {
+	return host + "|" + strconv.Itoa(apartment)
+}
+
+// startApartmentCall register call and get camshot, the domophone is resolved by host if nil
+func (h *baseHandler) startApartmentCall(calls *apartmentCalls, timestamp *time.Time, host string, domophone *models.Domophone, apartment int, callType string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	callData, err := h.newCallData(ctx, timestamp, host, domophone, apartment, callType)
+	if err != nil {
+		h.logger.Warn("Failed to collect call data", "unit", h.unit, "host", host, "apartment", apartment, "error", err)
+		return
+	}
+
+	for _, call := range calls.start(callData) {
+		h.logger.Warn("Call finished without end event", "unit", h.unit, "host", call.DomophoneIP, "apartment", call.Apartment)
+		go h.prepareFinalCallEvent(call)
+	}
+
+	h.logger.Info("Call started", "unit", h.unit, "host", host, "apartment", apartment, "callType", callType, "flatID", callData.FlatID)
+
+	go h.getCallScreenshots(callData)
+}
+
+// newCallData collect call data: domophone, main entrance, camera and flat if apartment is known
+func (h *baseHandler) newCallData(ctx context.Context, timestamp *time.Time, host string, domophone *models.Domophone, apartment int, callType string) (*CallData, error) {
+	if domophone == nil {
+		var err error
+		domophone, err = h.repo.Households.GetDomophone(ctx, "ip", host)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get domophone: %w", err)
+		}
+	}
+
+	entrance, err := h.repo.Households.GetEntrance(ctx, domophone.HouseDomophoneID, DOOR_MAIN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get entrance: %w", err)
+	}
+
+	// Beward SIP calls get apartment later
+	var flatID int
+	if apartment > 0 {
+		flatID, err = h.repo.Households.GetFlatIDByApartment(ctx, apartment, domophone.HouseDomophoneID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get flat: %w", err)
+		}
+	}
+
+	callData := &CallData{
+		Apartment:   apartment,
+		DomophoneIP: host,
+		StartTime:   timestamp,
+		CallType:    callType,
+		Domophone:   domophone,
+		Entrance:    entrance,
+		FlatID:      flatID,
+	}
+
+	if entrance.CameraID != nil {
+		callData.CameraID = *entrance.CameraID
+		camera, err := h.repo.Cameras.GetCamera(ctx, *entrance.CameraID)
+		if err != nil {
+			h.logger.Warn("Failed to get camera", "host", host, "error", err)
+		} else if camera.FRS != nil && *camera.FRS != "-" {
+			callData.CameraFRS = *camera.FRS
+		}
+	}
+
+	return callData, nil
+}
+
+// endApartmentCall make final call event
+func (h *baseHandler) endApartmentCall(calls *apartmentCalls, timestamp *time.Time, host string, apartment int) {
+	callData, exists := calls.end(host, apartment)
+	if !exists {
+		h.logger.Debug("Call end for unknown call", "unit", h.unit, "host", host, "apartment", apartment)
+		return
+	}
+
+	callData.EndTime = timestamp
+
+	h.logger.Info("Call ended",
+		"unit", h.unit,
+		"host", host,
+		"apartment", apartment,
+		"answered", callData.Answered,
+		"doorOpen", callData.DoorOpened)
+
+	go h.prepareFinalCallEvent(callData)
+}
+
+// updateApartmentCall change active call state
+func (h *baseHandler) updateApartmentCall(calls *apartmentCalls, host string, apartment int, update func(callData *CallData)) {
+	if !calls.update(host, apartment, update) {
+		h.logger.Debug("Call event for unknown call", "unit", h.unit, "host", host, "apartment", apartment)
+	}
+}
+
+// answer mark call answered, the first answer time is kept
+func (c *CallData) answer(timestamp *time.Time) {
+	c.Answered = true
+	if c.AnswerTime == nil {
+		c.AnswerTime = timestamp
+	}
+}
+
+// openDoor door opened during the call, the call is answered by opening
+func (c *CallData) openDoor(timestamp *time.Time) {
+	c.answer(timestamp)
+	c.DoorOpened = true
+}
+
+// callInfo final call event details: durations in seconds, SIP timeline and DTMF.
+// Ring and talk durations are left out for answered calls without answer time
+func (c *CallData) callInfo() map[string]interface{} {
+	endTime := c.StartTime
+	if c.EndTime != nil {
+		endTime = c.EndTime
+	}
+
+	info := map[string]interface{}{
+		"call_id":     c.CallID,
+		"apartment":   c.Apartment,
+		"call_type":   c.CallType,
+		"answered":    c.Answered,
+		"door_opened": c.DoorOpened,
+		"duration":    endTime.Sub(*c.StartTime).Seconds(),
+	}
+	switch {
+	case c.AnswerTime != nil:
+		info["ring_duration"] = c.AnswerTime.Sub(*c.StartTime).Seconds()
+		info["talk_duration"] = endTime.Sub(*c.AnswerTime).Seconds()
+	case !c.Answered:
+		info["ring_duration"] = endTime.Sub(*c.StartTime).Seconds()
+		info["talk_duration"] = 0.0
+	}
+	if len(c.SIPStates) > 0 {
+		info["sip_states"] = c.SIPStates
+	}
+	if c.DisconnectReason != "" {
+		info["disconnect_reason"] = c.DisconnectReason
+	}
+	if c.DTMF != "" {
+		info["dtmf"] = c.DTMF
+	}
+	return info
+}
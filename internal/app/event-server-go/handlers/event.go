@@ -1,5 +1,7 @@
 package handlers
 
+import "github.com/kulakoff/event-server-go/internal/app/event-server-go/events"
+
 type Events struct {
 	NotAnswered  int
 	Answered     int
@@ -16,14 +18,14 @@ var Event Events
 
 func init() {
 	Event = Events{
-		NotAnswered:  1,
-		Answered:     2,
-		OpenByKey:    3,
-		OpenByApp:    4,
-		OpenByFaceID: 5,
-		OpenByCode:   6,
-		OpenByCall:   7,
-		OpenByButton: 8,
-		OpenByPlate:  9,
+		NotAnswered:  events.NotAnswered,
+		Answered:     events.Answered,
+		OpenByKey:    events.OpenByKey,
+		OpenByApp:    events.OpenByApp,
+		OpenByFaceID: events.OpenByFaceID,
+		OpenByCode:   events.OpenByCode,
+		OpenByCall:   events.OpenByCall,
+		OpenByButton: events.OpenByButton,
+		OpenByPlate:  events.OpenByPlate,
 	}
 }
@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"context"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/config"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/syslog_custom"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Akuvox message tags and door open types, see draft/events_akuvox.md
+const (
+	AKUVOX_TAG_OPEN_DOOR = "OPENDOOR_LOG:"
+	AKUVOX_TAG_DTMF      = "DTMF_LOG:"
+	AKUVOX_TAG_SIP       = "SIP_LOG:"
+
+	AKUVOX_OPEN_BY_RFID   = "RF"
+	AKUVOX_OPEN_BY_PIN    = "PrivateKey"
+	AKUVOX_OPEN_BY_FACE   = "FACE"
+	AKUVOX_OPEN_BY_BUTTON = "INPUT"
+
+	AKUVOX_STATUS_SUCCESSFUL = "Successful"
+)
+
+// Name:value fields
+var akuvoxFieldRegex = regexp.MustCompile(`(\w+):(\S+)`)
+
+// AkuvoxHandler handles messages specific to Akuvox panels
+type AkuvoxHandler struct {
+	baseHandler
+	calls *apartmentCalls
+}
+
+func init() {
+	Register(Vendor{
+		Name:  "akuvox",
+		Unit:  "Akuvox",
+		Needs: needEvents | NeedFrs,
+		Syslog: func(deps *Deps, _ config.PanelConfig) syslog_custom.MessageHandler {
+			return NewAkuvoxHandler(deps)
+		},
+	})
+}
+
+// NewAkuvoxHandler creates a new AkuvoxHandler
+func NewAkuvoxHandler(deps *Deps) *AkuvoxHandler {
+	return &AkuvoxHandler{
+		baseHandler: newBaseHandler(deps, "akuvox"),
+		calls:       newApartmentCalls(),
+	}
+}
+
+// HandleMessage processes Akuvox-specific messages
+func (h *AkuvoxHandler) HandleMessage(srcIP string, message *syslog_custom.SyslogMessage) {
+	// FIXME: load location from system or config
+	location, _ := time.LoadLocation("Europe/Moscow")
+	now := time.Now().In(location).Truncate(time.Second)
+
+	// filter
+	if h.FilterMessage(message.Message) {
+		return
+	}
+
+	h.logger.Debug("HandleMessage || Processing Akuvox message", "ip", srcIP, "host", message.HostName, "message", message.Message)
+
+	host := h.resolveHost(srcIP, message)
+	h.storeSyslog(host, message.Message)
+
+	msg := message.Message
+	switch {
+	case strings.Contains(msg, AKUVOX_TAG_OPEN_DOOR):
+		h.HandleOpenDoor(&now, host, akuvoxFields(msg, AKUVOX_TAG_OPEN_DOOR))
+
+	case strings.Contains(msg, AKUVOX_TAG_DTMF):
+		fields := akuvoxFields(msg, AKUVOX_TAG_DTMF)
+		if fields["Status"] != "" && fields["Status"] != AKUVOX_STATUS_SUCCESSFUL {
+			return
+		}
+		h.HandleCallUpdate(host, fields, func(callData *CallData) { callData.openDoor(&now) })
+
+	case strings.Contains(msg, AKUVOX_TAG_SIP+"Call Start"):
+		h.HandleCallStart(&now, host, akuvoxFields(msg, AKUVOX_TAG_SIP))
+
+	case strings.Contains(msg, AKUVOX_TAG_SIP+"Call Established"):
+		h.HandleCallUpdate(host, akuvoxFields(msg, AKUVOX_TAG_SIP), func(callData *CallData) { callData.answer(&now) })
+
+	case strings.Contains(msg, AKUVOX_TAG_SIP+"Call Finished"),
+		strings.Contains(msg, AKUVOX_TAG_SIP+"Call Failed"):
+		h.HandleCallEnd(&now, host, akuvoxFields(msg, AKUVOX_TAG_SIP))
+	}
+}
+
+// HandleOpenDoor RFID, private key, face or exit button
+func (h *AkuvoxHandler) HandleOpenDoor(timestamp *time.Time, host string, fields map[string]string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	openType := fields["Type"]
+	if fields["Status"] != AKUVOX_STATUS_SUCCESSFUL {
+		h.logger.Debug("Akuvox door not opened", "host", host, "type", openType, "status", fields["Status"])
+		return
+	}
+
+	// relay 1 - main door, relay 2 - additional door
+	door := DOOR_MAIN
+	if relay, err := strconv.Atoi(fields["Relay"]); err == nil && relay > 1 {
+		door = relay - 1
+	}
+
+	event := DoorOpenEvent{
+		Timestamp: timestamp,
+		Host:      host,
+		Door:      door,
+	}
+	keyCode := fields["KeyCode"]
+
+	h.logger.Debug("Akuvox door opened", "host", host, "type", openType, "door", door)
+
+	switch openType {
+	case AKUVOX_OPEN_BY_RFID:
+		h.openByKey(ctx, event, keyCode)
+		return
+
+	case AKUVOX_OPEN_BY_PIN:
+		if _, err := strconv.Atoi(keyCode); err != nil {
+			h.logger.Warn("Invalid private key in Akuvox message", "host", host, "keyCode", keyCode)
+			return
+		}
+		h.openByCode(ctx, event, keyCode)
+		return
+
+	case AKUVOX_OPEN_BY_FACE:
+		if keyCode == "" {
+			h.logger.Warn("Face id not found", "host", host)
+			return
+		}
+
+		event.Event = Event.OpenByFaceID
+		event.FaceID = keyCode
+		event.PushBody = "Открытие по лицу"
+
+	case AKUVOX_OPEN_BY_BUTTON:
+		event.Event = Event.OpenByButton
+
+	default:
+		h.logger.Debug("Akuvox door open type not processed", "host", host, "type", openType)
+		return
+	}
+
+	h.processDoorOpen(ctx, event)
+}
+
+// HandleCallStart SIP call to apartment
+func (h *AkuvoxHandler) HandleCallStart(timestamp *time.Time, host string, fields map[string]string) {
+	apartment, err := strconv.Atoi(fields["Number"])
+	if err != nil {
+		h.logger.Warn("Failed to extract apartment from call start", "host", host, "fields", fields)
+		return
+	}
+
+	h.startApartmentCall(h.calls, timestamp, host, nil, apartment, CALL_TYPE_SIP)
+}
+
+// HandleCallUpdate call answered or door opened by DTMF
+func (h *AkuvoxHandler) HandleCallUpdate(host string, fields map[string]string, update func(callData *CallData)) {
+	apartment, err := strconv.Atoi(fields["Number"])
+	if err != nil {
+		h.logger.Warn("Failed to extract apartment from call event", "host", host, "fields", fields)
+		return
+	}
+
+	h.updateApartmentCall(h.calls, host, apartment, update)
+}
+
+// HandleCallEnd make final call event
+func (h *AkuvoxHandler) HandleCallEnd(timestamp *time.Time, host string, fields map[string]string) {
+	apartment, err := strconv.Atoi(fields["Number"])
+	if err != nil {
+		h.logger.Warn("Failed to extract apartment from call end", "host", host, "fields", fields)
+		return
+	}
+
+	h.endApartmentCall(h.calls, timestamp, host, apartment)
+}
+
+// akuvoxFields "OPENDOOR_LOG:Type:RF KeyCode:0600C9F5C9 Relay:1 Status:Successful"
+func akuvoxFields(message, tag string) map[string]string {
+	_, rest, _ := strings.Cut(message, tag)
+
+	fields := make(map[string]string)
+	for _, match := range akuvoxFieldRegex.FindAllStringSubmatch(rest, -1) {
+		fields[match[1]] = match[2]
+	}
+	return fields
+}
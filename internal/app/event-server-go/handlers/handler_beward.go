@@ -2,38 +2,23 @@ package handlers
 
 import (
 	"context"
-	"crypto/md5"
-	"encoding/json"
 	"fmt"
-	"github.com/redis/go-redis/v9"
-	"log/slog"
-	"net"
+	"regexp"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/events"
 	"github.com/kulakoff/event-server-go/internal/app/event-server-go/repository/models"
 
-	"github.com/kulakoff/event-server-go/internal/app/event-server-go/repository"
-	"github.com/kulakoff/event-server-go/internal/app/event-server-go/services/frs"
-	storage2 "github.com/kulakoff/event-server-go/internal/app/event-server-go/storage"
 	"github.com/kulakoff/event-server-go/internal/app/event-server-go/syslog_custom"
 	"github.com/kulakoff/event-server-go/internal/app/event-server-go/utils"
 
-	"github.com/google/uuid"
 	"github.com/kulakoff/event-server-go/internal/app/event-server-go/config"
 )
 
 const (
-	IMAGE_UUID_STUB = "00000000-0000-0000-0000-000000000000"
-
-	PREVIEW_NONE  = 0
-	PREVIEW_IPCAM = 1
-	PREVIEW_FRS   = 2
-
-	TTL_CAMSHOT_HOURS = time.Hour * 24 * 30 * 6
-
 	CALL_TYPE_SIP = "sip"
 	CALL_TYPE_CMS = "cms"
 
@@ -51,8 +36,6 @@ const (
 
 	DOOR_MAIN      = 0
 	DOOR_SECONDARY = 1
-
-	SCREENSHOOT_URL_PATH = "/frs/camshot/"
 )
 
 type CallData struct {
@@ -64,6 +47,15 @@ type CallData struct {
 	Answered    bool
 	DoorOpened  bool
 	CallType    string
+	State       CallState  // Beward call lifecycle
+	StateTime   time.Time  // last call message
+	AnswerTime  *time.Time // first answer: ring and talk durations
+
+	// Beward SIP call
+	SIPCallID        int        // "SIP call N", DTMF lines refer to it
+	SIPStates        []SIPState // SIP state timeline
+	DisconnectReason string
+	DTMF             string // received DTMF digits
 
 	// Data for event
 	CameraID  int
@@ -72,29 +64,38 @@ type CallData struct {
 	Entrance  *models.HouseEntrance
 	FlatID    int
 
-	// Images data
-	ScreenshotData []byte
-	FaceData       map[string]interface{}
-	PreviewType    int
+	// Camshot
+	Face        *events.Face
+	PreviewType int
 
-	// Screenshot storage info
-	screenshotFileID string
+	imageUUID        string // plog image_uuid, stub without camshot
 	screenshotsReady bool
 	callMutex        sync.Mutex
 }
 
+// SIPState SIP call state change
+type SIPState struct {
+	State  string    `json:"state"`
+	Time   time.Time `json:"time"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+var (
+	// "[61255] SIP call 4 state changed to EARLY", "[61255] SIP call 4 is DISCONNECTED [reason=200 (Normal call clearing)]"
+	bewardSIPStateRegex = regexp.MustCompile(`SIP call (\d+) (?:state changed to|is) ([A-Z]+)(?: \[reason=([^\]]*)\])?`)
+
+	// "Incoming DTMF RFC2833 on call 4: 1", no Beward call id
+	bewardDTMFRegex = regexp.MustCompile(`Incoming DTMF \S+ on call (\d+): (\S+)`)
+)
+
 // BewardHandler handles messages specific to Beward panels
 type BewardHandler struct {
-	logger      *slog.Logger
-	spamWords   []string
-	storage     *storage2.ClickhouseHttpClient
-	fsFiles     *storage2.MongoHandler
-	repo        *repository.PostgresRepository
-	rbtApi      *config.RbtApi
-	frsApi      *config.FrsApi
-	activeCalls map[int]*CallData // key: beward callId
-	callMutex   sync.Mutex
-	redisClient *redis.Client
+	baseHandler
+	activeCalls *callTracker // key: domophone ip and beward callId
+
+	// final call events in progress, Start waits for them on shutdown:
+	// the call is already deleted from the store
+	finals sync.WaitGroup
 }
 
 type OpenDoorMsg struct {
@@ -105,39 +106,23 @@ type OpenDoorMsg struct {
 	Detail string `json:"detail"`
 }
 
-// NewBewardHandler creates a new BewardHandler
-func NewBewardHandler(
-	logger *slog.Logger,
-	filters []string,
-	storage *storage2.ClickhouseHttpClient,
-	mongo *storage2.MongoHandler,
-	repo *repository.PostgresRepository,
-	rbtApi *config.RbtApi,
-	frsApi *config.FrsApi,
-	redisClient *redis.Client,
-) *BewardHandler {
-	return &BewardHandler{
-		logger:      logger,
-		spamWords:   filters,
-		storage:     storage,
-		fsFiles:     mongo,
-		repo:        repo,
-		rbtApi:      rbtApi,
-		frsApi:      frsApi,
-		activeCalls: make(map[int]*CallData),
-		redisClient: redisClient,
-	}
+func init() {
+	Register(Vendor{
+		Name:  "beward",
+		Unit:  "Beward",
+		Needs: needEvents | NeedFrs,
+		Syslog: func(deps *Deps, _ config.PanelConfig) syslog_custom.MessageHandler {
+			return NewBewardHandler(deps)
+		},
+	})
 }
 
-// FilterMessage skip not informational syslog message
-func (h *BewardHandler) FilterMessage(message string) bool {
-	for _, word := range h.spamWords {
-		//if strings.Contains(strings.ToLower(message), word) {}
-		if strings.Contains(message, word) {
-			return true
-		}
+// NewBewardHandler creates a new BewardHandler
+func NewBewardHandler(deps *Deps) *BewardHandler {
+	return &BewardHandler{
+		baseHandler: newBaseHandler(deps, "beward"),
+		activeCalls: newCallTracker(newCallStore(deps.Logger, deps.Redis, "beward")),
 	}
-	return false
 }
 
 // HandleMessage processes Beward-specific messages
@@ -166,30 +151,10 @@ func (h *BewardHandler) HandleMessage(srcIP string, message *syslog_custom.Syslo
 	h.logger.Debug("HandleMessage || Processing Beward message", "ip", srcIP, "host", message.HostName, "message", message.Message)
 
 	// 3 ----- storage message
-	var host string
-	// use host ip from syslog message
-	if net.ParseIP(message.HostName) != nil && message.HostName != "127.0.0.1" && srcIP != message.HostName {
-		host = message.HostName
-	} else {
-		host = srcIP
-	}
-
-	storageMessage := storage2.SyslogStorageMessage{
-		Date:  strconv.FormatInt(time.Now().Unix(), 10),
-		Ip:    host,
-		SubId: "",
-		Unit:  "beward",
-		Msg:   message.Message,
-	}
-
-	// convert JSON to string
-	storageMessageJson, err := json.Marshal(storageMessage)
-	if err != nil {
-		h.logger.Warn("Failed to marshal storage message", "error", err)
-	}
+	host := h.resolveHost(srcIP, message)
 
 	// 4 ----- send syslog message to remote storage
-	h.storage.Insert("syslog", string(storageMessageJson))
+	h.storeSyslog(host, message.Message)
 
 	// --------------------
 	// Implement Beward-specific message processing here
@@ -218,8 +183,7 @@ func (h *BewardHandler) HandleMessage(srcIP string, message *syslog_custom.Syslo
 
 	// Tracks open door by code
 	if strings.Contains(message.Message, "Opening door by code") {
-		//h.HandleOpenByCode(&now, host, message.Message)
-		h.HandleOpenByCodeTest(&now, host, message.Message)
+		h.HandleOpenByCode(&now, host, message.Message)
 	}
 
 	// Tracks open door by RFID key
@@ -233,14 +197,17 @@ func (h *BewardHandler) HandleMessage(srcIP string, message *syslog_custom.Syslo
 		h.HandleOpenByButton(&now, host, message.Message)
 	}
 
-	// TODO: implement me
-	// Tracks alarm button
+	// Tracks break in alarm
 	if strings.Contains(message.Message, "Intercom break in detected") {
-		h.logger.Debug("processing not implemented", "msg", message.Message)
+		h.HandleBreakIn(&now, host, message.Message)
+	}
+
+	// Tracks tamper alarm
+	if isTamperMessage(message.Message) {
+		h.HandleTamper(&now, host, message.Message)
 	}
 
-	// TODO: implement me
-	// 		- Tracks calls
+	// Tracks calls
 	if strings.Contains(message.Message, "CMS handset call started") ||
 		strings.Contains(message.Message, "CMS handset talk started") ||
 		strings.Contains(message.Message, "Opening door by CMS handset") ||
@@ -251,1070 +218,404 @@ func (h *BewardHandler) HandleMessage(srcIP string, message *syslog_custom.Syslo
 		strings.Contains(message.Message, "SIP call done") ||
 		strings.Contains(message.Message, "All calls are done") ||
 		strings.Contains(message.Message, "CMS handset") ||
+		strings.Contains(message.Message, "Opening door by DTMF command") ||
+		strings.Contains(message.Message, "Incoming DTMF") ||
 		strings.Contains(message.Message, "Unable to call CMS") {
 		h.HandleCallFlow(&now, host, message.Message)
 	}
 }
 
-// FIXME:
-// APICallToRBT Update RFID usage timestamp
-func (h *BewardHandler) APICallToRBT(payload *OpenDoorMsg) error {
-	//url := "http://172.28.0.2/internal/actions/openDoor"
-	url := "https://webhook.site/5e9d4c4d-73eb-44c4-be20-e1886cbea2b4/openDoor"
-
-	headers := map[string]string{
-		"Content-Type": "application/json",
-	}
-
-	_, _, err := utils.SendPostRequest(url, headers, payload)
-	if err != nil {
-		return err
-	}
-
-	h.logger.Debug("Successfully sent OpenDoorMsg")
-	return nil
-}
-
-// HandleMotionDetection - complete
-func (h *BewardHandler) HandleMotionDetection(timestamp *time.Time, host string, motionActive bool) {
-	// implement motion detection logic
-	// get streamId by intercom IP and call to API FRS. message motion start or stop
-	h.logger.Debug("HandleMotionDetection", "host", host, "motionActive", motionActive)
-	/**
-	TODO:
-		1 get stream by ip
-		2 check FRS enable, not eq "-"
-		3 send req to FRS service
-	*/
-	camera, _ := h.repo.Cameras.GetCameraByIP(context.Background(), host)
-	//h.logger.Debug("Motion detect process", "camera", camera.FRS)
-	// check if FRS enable
-	if *camera.FRS != "-" {
-		//h.logger.Debug("Motion detect process, FRS enabled")
-		err := frs.MotionDetection(camera.CameraID, motionActive, *camera.FRS)
-		if err != nil {
-			h.logger.Warn("Failed to send motion detect to FRS service", "error", err)
-			return
-		}
-	}
-}
-
-// HandleOpenByCode - complete
+// HandleOpenByCode flat open code: "Opening door by code 55544, apartment 1"
 func (h *BewardHandler) HandleOpenByCode(timestamp *time.Time, host, message string) {
-	// implement open door by code logic
-	h.logger.Debug("Open door by code", "host", host, "message", message)
-
-	frsEnabled := false
-	preview := PREVIEW_IPCAM
-	rbtAPI := h.rbtApi.Internal
-	var faceData map[string]interface{}
-	door := 0 // main door usage digit code
-
-	// get code
-	// TODO: move get code to utils
-	parts := strings.SplitN(message, "code", 2)
-	if len(parts) < 2 {
-		h.logger.Error("Invalid message format - no content after 'code'", "message", message)
-		return
-	}
-
-	codePart := strings.SplitN(parts[1], ",", 2)[0]
-	codeStr := strings.TrimSpace(codePart)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
 
-	code, err := strconv.Atoi(codeStr)
+	code, err := extractOpenCode(message)
 	if err != nil {
-		h.logger.Error("Failed to convert code to integer",
-			"error", err,
-			"code_str", codeStr,
-			"message", message)
+		h.logger.Warn("HandleOpenByCode extractOpenCode", "host", host, "message", message, "err", err)
 		return
 	}
-	h.logger.Debug("Successfully extracted code", "code", code, "host", host)
-
-	// get domophone
-	domophone, err := h.repo.Households.GetDomophone(context.Background(), "ip", host)
-	if err != nil {
-		h.logger.Warn("Failed to get domophone", "error", err)
-	}
 
-	// get entrance
-	entrance, err := h.repo.Households.GetEntrance(context.Background(), domophone.HouseDomophoneID, door)
-	if err != nil {
-		h.logger.Warn("Failed to get entrance", "error", err)
-	}
-	h.logger.Debug("Successfully extracted entrance", "entrance", entrance)
-
-	if entrance.CameraID == nil {
-		h.logger.Warn("Failed to get camera id")
-		return
-	}
+	h.openByCode(ctx, DoorOpenEvent{Timestamp: timestamp, Host: host, Door: DOOR_MAIN}, code)
+}
 
-	// get entrance camera
-	camera, err := h.repo.Cameras.GetCamera(context.Background(), *entrance.CameraID)
-	if err != nil {
-		h.logger.Warn("Failed to get camera", "error", err)
-	}
+// HandleOpenByRFID main or external reader
+func (h *BewardHandler) HandleOpenByRFID(timestamp *time.Time, host, message string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
 
-	// check FRS enabled
-	if *camera.FRS != "-" {
-		h.logger.Debug("HandleDebug, FRS enabled")
-		frsEnabled = true
+	door := DOOR_MAIN
+	if strings.Contains(message, "external") {
+		door = DOOR_SECONDARY
 	}
 
-	// 01 - get screenshot from domophone camera
-	imgURL := rbtAPI + "/frs/camshot/" + strconv.Itoa(camera.CameraID)
-	camScreenShot, err := utils.DownloadFile(imgURL)
-	if err != nil {
-		h.logger.Debug("RBT DownloadFile", "err", err)
-	}
+	rfidKey := utils.ExtractRFIDKey(message)
+	h.openByKey(ctx, DoorOpenEvent{Timestamp: timestamp, Host: host, Door: door}, rfidKey)
 
-	// 02 get screenshot from FRS
-	if frsEnabled {
-		h.logger.Debug("HandleMessage | HandleDebug | FRS enabled")
-		bqResponse, _ := utils.GetBestQuality(h.frsApi, camera.CameraID, *timestamp)
-		if bqResponse != nil {
-			h.logger.Debug("FRS BEST bq response", "response", bqResponse)
-
-			faceData = map[string]interface{}{
-				"left":   bqResponse.Data.Left,
-				"top":    bqResponse.Data.Top,
-				"width":  bqResponse.Data.Width,
-				"height": bqResponse.Data.Height,
-			}
-			preview = PREVIEW_FRS
-
-			h.logger.Debug("HandleMessage | HandleDebug | get img from FRS")
-			camScreenShot = nil
-			camScreenShot, err = utils.DownloadFile(bqResponse.Data.Screenshot)
-		}
-	}
+	go h.watcherProcess(rfidKey)
+}
 
-	metadata := map[string]interface{}{
-		"contentType": "image/jpeg",
-		"expire":      int32(timestamp.Add(TTL_CAMSHOT_HOURS).Unix()),
-	}
+// HandleOpenByButton main or additional door exit button
+func (h *BewardHandler) HandleOpenByButton(timestamp *time.Time, host, message string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
 
-	// save data to MongoDb
-	fileId, err := h.fsFiles.SaveFile("camshot", metadata, camScreenShot)
-	if err != nil {
-		h.logger.Debug("MongoDB SaveFile", "err", err)
-	}
-	h.logger.Debug("MongoDB SaveFile", "fileId", fileId)
-	camScreenShot = nil
-
-	// 9
-	eventGUIDv4 := uuid.New().String()
-	imageGUIDv4 := utils.ToGUIDv4(fileId)
-
-	flatList, _ := h.repo.Households.GetFlatIDsByCode(context.Background(), strconv.Itoa(code))
-
-	plogData := map[string]interface{}{
-		"date":       timestamp.Unix(),
-		"event_uuid": eventGUIDv4,
-		"hidden":     0,
-		"image_uuid": imageGUIDv4,
-		"flat_id":    flatList[0],
-		"domophone": map[string]interface{}{
-			"camera_id":             camera.CameraID,
-			"domophone_description": entrance.Entrance,
-			"domophone_id":          domophone.HouseDomophoneID,
-			"domophone_output":      entrance.DomophoneOutput,
-			"entrance_id":           entrance.HouseEntranceID,
-			"house_id":              entrance.AddressHouseID,
-		},
-		"event":   Event.OpenByCode,
-		"opened":  1, // bool
-		"face":    faceData,
-		"rfid":    "",
-		"code":    code,
-		"phones":  map[string]interface{}{},
-		"preview": preview, // 0 no image, 1 - image from DVR, 2 - image from FRS
+	door := DOOR_MAIN
+	if strings.Contains(message, "Additional") {
+		door = DOOR_SECONDARY
 	}
 
-	plogDataString, err := json.Marshal(plogData)
-	if err != nil {
-		h.logger.Debug("Failed marshal JSON")
-	}
+	h.processDoorOpen(ctx, DoorOpenEvent{
+		Timestamp: timestamp,
+		Host:      host,
+		Door:      door,
+		Event:     Event.OpenByButton,
+	})
+}
 
-	err = h.storage.Insert("plog", string(plogDataString))
-	if err != nil {
-		fmt.Println("INSERT ERR", err)
-	}
+// HandleBreakIn door opened without open command
+func (h *BewardHandler) HandleBreakIn(timestamp *time.Time, host, message string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
 
-	// get flat by code and domophone ip
-	// TODO: update last usage code
+	h.processSecurityEvent(ctx, SecurityEvent{
+		Timestamp: timestamp,
+		Host:      host,
+		Door:      DOOR_MAIN,
+		Type:      SECURITY_BREAK_IN,
+		Message:   message,
+	})
 }
 
-// TODO implement method
-// HandleOpenByCodeTest - complete
-func (h *BewardHandler) HandleOpenByCodeTest(timestamp *time.Time, host, message string) {
-	// implement open door by code logic
-	h.logger.Debug("Open door by code", "host", host, "message", message)
-
+// HandleTamper panel case opened
+func (h *BewardHandler) HandleTamper(timestamp *time.Time, host, message string) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel() // гарантированное освобождение ресурсов
-
-	frsEnabled := false
-	preview := PREVIEW_IPCAM
-	rbtAPI := h.rbtApi.Internal
-	var faceData map[string]interface{}
-	var domophoneData map[string]interface{}
-	var imageGUIDv4 string
-	var hash string
-	cameraEnabled := false
-	door := DOOR_MAIN
+	defer cancel()
 
-	// get code
-	// TODO: move get code to utils
-	parts := strings.SplitN(message, "code", 2)
-	if len(parts) < 2 {
-		h.logger.Error("Invalid message format - no content after 'code'", "message", message)
-		return
-	}
+	h.processSecurityEvent(ctx, SecurityEvent{
+		Timestamp: timestamp,
+		Host:      host,
+		Door:      DOOR_MAIN,
+		Type:      SECURITY_TAMPER,
+		Message:   message,
+	})
+}
 
-	codePart := strings.SplitN(parts[1], ",", 2)[0]
-	codeStr := strings.TrimSpace(codePart)
+// -------
 
-	code, err := strconv.Atoi(codeStr)
-	if err != nil {
-		h.logger.Error("Failed to convert code to integer",
-			"error", err,
-			"code_str", codeStr,
-			"message", message)
+// HandleCallFlow call state machine, the call is registered by the first message with call id
+func (h *BewardHandler) HandleCallFlow(timestamp *time.Time, host, message string) {
+	if strings.Contains(message, "Incoming DTMF") {
+		h.HandleDTMF(timestamp, host, message)
 		return
 	}
-	h.logger.Debug("Successfully extracted code", "code", code, "host", host)
-
-	// get domophone
-	domophone, err := h.repo.Households.GetDomophone(ctx, "ip", host)
-	if err != nil {
-		h.logger.Warn("Failed to get domophone", "error", err)
-	}
-
-	// get entrance
-	entrance, err := h.repo.Households.GetEntrance(ctx, domophone.HouseDomophoneID, door)
-	if err != nil {
-		h.logger.Warn("Failed to get entrance", "error", err)
-	}
-
-	if entrance.CameraID == nil {
-		h.logger.Warn("Failed to get camera id")
-		imageGUIDv4 = IMAGE_UUID_STUB
-		preview = PREVIEW_NONE
-	} else {
-		cameraEnabled = true
-	}
 
-	// ----- get home data. full address
-	house, err := h.repo.Households.GetHouseByEntranceID(ctx, entrance.HouseEntranceID)
+	callID, err := extractCallID(message)
 	if err != nil {
-		h.logger.Warn("Failed to get house", "error", err)
-	}
-
-	domophoneData = map[string]interface{}{
-		"camera_id":             "-",
-		"domophone_description": entrance.Entrance,
-		"domophone_id":          domophone.HouseDomophoneID,
-		"domophone_output":      entrance.DomophoneOutput,
-		"entrance_id":           entrance.HouseEntranceID,
-		"house_id":              entrance.AddressHouseID,
-	}
-
-	// get image
-	if cameraEnabled {
-		domophoneData["camera_id"] = *entrance.CameraID
-
-		// get entrance camera
-		camera, err := h.repo.Cameras.GetCamera(ctx, *entrance.CameraID)
-		if err != nil {
-			h.logger.Warn("Failed to get camera", "error", err)
-		}
-
-		// check FRS enabled
-		if *camera.FRS != "-" {
-			h.logger.Debug("HandleDebug, FRS enabled")
-			frsEnabled = true
-		} else {
-			h.logger.Debug("HandleDebug, FRS disabled")
-		}
-
-		// 01 - get screenshot from domophone camera
-		imgURL := rbtAPI + SCREENSHOOT_URL_PATH + strconv.Itoa(camera.CameraID)
-
-		// get scrrenshot from camera
-		camScreenShot, err := utils.DownloadFile(imgURL)
-		if err != nil {
-			h.logger.Debug("RBT DownloadFile", "err", err)
-			preview = PREVIEW_NONE
-		}
-
-		// 02 get screenshot from FRS
-		if frsEnabled {
-			h.logger.Debug("HandleMessage | HandleDebug | FRS enabled")
-			bqResponse, _ := utils.GetBestQuality(h.frsApi, camera.CameraID, *timestamp)
-			if bqResponse != nil {
-				h.logger.Debug("FRS BEST bq response", "response", bqResponse)
-
-				faceData = map[string]interface{}{
-					"left":   bqResponse.Data.Left,
-					"top":    bqResponse.Data.Top,
-					"width":  bqResponse.Data.Width,
-					"height": bqResponse.Data.Height,
-				}
-				preview = PREVIEW_FRS
-
-				h.logger.Debug("HandleMessage | HandleDebug | get img from FRS")
-				camScreenShot = nil
-				camScreenShot, err = utils.DownloadFile(bqResponse.Data.Screenshot)
-			}
-		}
-
-		// hash for push event
-		hash = fmt.Sprintf("%x", md5.Sum([]byte(uuid.New().String())))
-
-		shotKey := "shot_" + hash
-		if err := h.redisClient.SetEx(ctx, shotKey, camScreenShot, 15*60*time.Second).Err(); err != nil {
-			h.logger.Debug("failed to save screenshot to Redis", "err", err)
-		}
-
-		// save data to MongoDb
-		metadata := map[string]interface{}{
-			"contentType": "image/jpeg",
-			"expire":      int32(timestamp.Add(time.Hour * 24 * 30 * 6).Unix()),
-		}
-		fileId, err := h.fsFiles.SaveFile("camshot", metadata, camScreenShot)
-		if err != nil {
-			h.logger.Debug("MongoDB SaveFile", "err", err)
-		}
-		h.logger.Debug("MongoDB SaveFile", "fileId", fileId)
-		camScreenShot = nil
-
-		imageGUIDv4 = utils.ToGUIDv4(fileId)
-	}
-
-	// event id
-	eventGUIDv4 := uuid.New().String()
-
-	flatList, _ := h.repo.Households.GetFlatIDsByCode_new(ctx, strconv.Itoa(code))
-
-	plogData := map[string]interface{}{
-		"date":       timestamp.Unix(),
-		"event_uuid": eventGUIDv4,
-		"hidden":     0,
-		"image_uuid": imageGUIDv4,
-		"flat_id":    flatList[0].HouseFlatID,
-		"domophone":  domophoneData,
-		"event":      Event.OpenByCode,
-		"opened":     1, // bool
-		"face":       faceData,
-		"rfid":       "",
-		"code":       code,
-		"phones":     map[string]interface{}{},
-		"preview":    preview, // 0 no image, 1 - image from DVR, 2 - image from FRS
+		h.logger.Warn("HandleCallFlow extractCallID", "host", host, "err", err)
+		return
 	}
 
-	plogDataString, err := json.Marshal(plogData)
-	if err != nil {
-		h.logger.Debug("Failed marshal JSON")
-	}
+	switch {
+	// 01 - Call start, SIP messages have no apartment
+	case strings.Contains(message, "CMS handset call started for apartment"),
+		strings.Contains(message, "Unable to call CMS apartment"),
+		strings.Contains(message, "CMS handset is not connected for apartment"),
+		strings.Contains(message, "Calling sip:"):
+		h.HandleCallStart(timestamp, host, message, callID)
 
-	err = h.storage.Insert("plog", string(plogDataString))
-	if err != nil {
-		fmt.Println("INSERT ERR", err)
-	}
+	// 02 - SIP state timeline: early media, answer, disconnect reason
+	case bewardSIPStateRegex.MatchString(message):
+		h.HandleSIPState(timestamp, host, message, callID)
 
-	// send push
-	// check watchers for flat
-	watchers, err := h.repo.Households.GetWatchersByFlatID(ctx, flatList[0].HouseFlatID)
-	if err != nil {
-		h.logger.Warn("Failed to get watchers", "error", err)
-	}
+	// 03 - Call answered
+	case strings.Contains(message, "CMS handset talk started for apartment"),
+		strings.Contains(message, "SIP talk started for apartment"):
+		h.HandleCallAnswered(timestamp, host, message, callID)
 
-	if watchers != nil && len(watchers) > 0 {
-		for _, watcher := range watchers {
+	// 04 - Door opened
+	case strings.Contains(message, "Opening door by CMS handset for apartment"),
+		strings.Contains(message, "Opening door by DTMF command for apartment"):
+		h.HandleDoorOpen(timestamp, host, message, callID)
 
-			// watch CODE
-			// Если тип события - открытие по коду
-			if watcher.EventType == strconv.Itoa(Event.OpenByCode) {
-				h.logger.Debug("WATCERS", "watcher found", watcher)
-				msgTitle := "Открытие двери"
-				addressStr := house.HouseFull + "кв." + flatList[0].Flat
-				msgBody := fmt.Sprintf("Адрес: %s\nКод: %d\n", addressStr, code)
-				device, _ := h.repo.Households.GetMobileDeviceByID(ctx, watcher.DeviceID)
+	// 05 - CMS or SIP call done, the final event waits for "All calls are done"
+	case strings.Contains(message, "CMS handset call done for apartment"),
+		strings.Contains(message, "SIP call done for apartment"):
+		h.HandleCallEnd(timestamp, host, message, callID)
 
-				go utils.SendPush(hash, msgTitle, msgBody, device.PushToken, device.PushTokenType, device.Platform)
-			}
-		}
-	} else {
-		h.logger.Debug("Watchers not found")
+	// 06 - make final event
+	case strings.Contains(message, "All calls are done for apartment"):
+		h.HandleAllCallsDone(timestamp, host, message, callID)
 	}
-
-	// TODO: make update last usage code
 }
 
-// HandleOpenByRFID - complete
-func (h *BewardHandler) HandleOpenByRFID(timestamp *time.Time, host, message string) {
-	// implement open door by RFID key logic
-	h.logger.Debug("Open door by RFID")
-	frsEnabled := false
-	isExternalReader := false
-	var faceData map[string]interface{}
-	preview := PREVIEW_IPCAM
-
-	/**
-	TODO:
-		1 get external reader
-		2 get RFID key
-		3 get door (main or addition)
-		4 update the RFID key last usage date (API call to RBT)
-		5 get streamName, streamId
-		6 get best quality image from FRS, if exist
-		7 get screenshot from media server if FRS not have image
-		8 storage image to MongoDB
-		9 create "plog" record to clickhouse
-	*/
-
-	// ----- 1
-	//isExternalReader := false
-	if strings.Contains(message, "external") {
-		isExternalReader = true
-	}
-
-	// ----- 2
-	door := 0
-	if isExternalReader {
-		door = 1
-	}
-
-	// ----- 3
-	rfidKey := utils.ExtractRFIDKey(message)
-	if rfidKey != "" {
-		h.logger.Debug("RFID key found", "host", host, "rfid", rfidKey)
-	} else {
-		h.logger.Warn("RFID key not found", "host", host)
-	}
-
-	h.logger.Debug("Open by RFID", "door", door, "rfid", rfidKey)
-	/**
-	TODO:
-		- 1. API call to update RFID usage timestamp
-		- 2. get stream name
-		- 3. get best quality image from FRS or DVR by stream name
-		- 4. save image to mongoDb
-		- 5. save plog to clickhouse
-	*/
-
-	// TODO: implement me
-	// ----- 4
-	err := h.repo.Households.UpdateRFIDLastSeen(context.Background(), rfidKey)
-	if err != nil {
-		h.logger.Warn("Failed to update RFID", "error", err)
+// HandleCallStart register call in ringing state and get camshot
+func (h *BewardHandler) HandleCallStart(timestamp *time.Time, host string, message string, callID int) {
+	if _, exists := h.activeCalls.get(host, callID); exists {
+		// CMS and SIP calls of the same call id
+		h.advanceCall(timestamp, host, message, callID, CallRinging, nil)
 		return
 	}
 
-	//h.sendTestNotification(1, rfidKey, "Тест")
-	go h.watcherProcess(rfidKey)
-
-	//domophoneId, _ := h.repo.Households.GetDomophoneByIP(context.Background(), host)
-	/*
-		+ 1 получаем домофон по ip
-		2 полчаем вход (основной или дополнительный)  на основании считывателя
-		3 получаем камеру входа
-	*/
-
-	//domophone, _ := h.repo.Households.GetDomophone(context.Background(), "ip", host)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
 
-	// ----- 5
-	// TODO: implement get "streamName" and "streamID" by ip intercom
+	// CMS messages have apartment, SIP calls get it later
+	apartment, _ := extractApartment(message)
 
-	// get domophone
-	domophone, err := h.repo.Households.GetDomophone(context.Background(), "ip", host)
-	if err != nil {
-		h.logger.Warn("Failed to get domophone", "error", err)
+	// call type: SIP or CMS
+	callType := CALL_TYPE_SIP
+	if strings.Contains(message, "CMS") {
+		callType = CALL_TYPE_CMS
 	}
 
-	// get entrance
-	entrance, err := h.repo.Households.GetEntrance(context.Background(), domophone.HouseDomophoneID, door)
+	callData, err := h.newCallData(ctx, timestamp, host, nil, apartment, callType)
 	if err != nil {
-		h.logger.Warn("Failed to get entrance", "error", err)
-	}
-
-	if entrance.CameraID == nil {
-		h.logger.Warn("Failed to get camera id")
+		h.logger.Warn("Failed to collect call data", "host", host, "callID", callID, "apartment", apartment, "error", err)
 		return
 	}
+	callData.CallID = callID
 
-	// get entrance camera
-	camera, err := h.repo.Cameras.GetCamera(context.Background(), *entrance.CameraID)
-	if err != nil {
-		h.logger.Warn("Failed to get camera", "error", err)
-	}
-
-	/**
-	TODO:
-		get screenshot from camera
-		frs enabled - get GetBestQuality
-			if GetBestQuality result - use FRS screenshot
-			if not GetBestQuality result - use first camera screen
-	*/
-	// check FRS enabled
-	if *camera.FRS != "-" {
-		h.logger.Debug("HandleDebug, FRS enabled")
-		frsEnabled = true
-	}
-	h.logger.Debug("Open by RFID", "frsEnabled >>", frsEnabled)
-
-	rbtAPI := h.rbtApi.Internal
-
-	// download file from RBT
-	//_screenShot, err := utils.DownloadFile(_imageUrl)
-	//if err != nil {
-	//	h.logger.Debug("FRS DownloadFile", "err", err)
-	//}
-	//
-	//// if frs enabled - call bestQuality
-	//if *camera.FRS != "-" {
-	//
-	//	bestQualityResp, err := utils.GetBestQuality(camera.CameraID, *timestamp)
-	//	if err != nil {
-	//
-	//		return
-	//	}
-	//
-	//	if bestQualityResp == nil {
-	//		h.logger.Debug("Open by RFID", "GetBestQuality response", bestQualityResp)
-	//
-	//		// download file from FRS
-	//		//https://rbt-demo.lanta.me:8443/rbt-demo-0016/index.m3u8?token=phei9quohmoochoth5es3eo9Koh5ua9i
-	//		//https://rbt-demo.lanta.me:8443/rbt-demo-0016/preview.mp4?token=phei9quohmoochoth5es3eo9Koh5ua9i
-	//
-	//	}
-	//} else {
-	//	//get image from DVR
-	//	h.logger.Debug("Open by RFID, FRS is DIS")
-	//	h.logger.Debug("Open by RFID", "DVR URL", camera.DVRStream)
-	//}
-
-	// 01 - get screenshot from domophone camera
-	imgURL := rbtAPI + "/frs/camshot/" + strconv.Itoa(camera.CameraID)
-	h.logger.Debug("Open by RFID", "_imageUrl >>", imgURL)
-
-	camScreenShot, err := utils.DownloadFile(imgURL)
-	if err != nil {
-		h.logger.Debug("RBT DownloadFile", "err", err)
+	if _, added := h.activeCalls.add(callData, *timestamp); !added {
+		h.advanceCall(timestamp, host, message, callID, CallRinging, nil)
+		return
 	}
 
-	// 02 get screenshot from FRS
-	if frsEnabled {
-		h.logger.Debug("HandleMessage | HandleDebug | FRS enabled")
-		bqResponse, _ := utils.GetBestQuality(h.frsApi, camera.CameraID, *timestamp)
-		if bqResponse != nil {
-			h.logger.Debug("FRS BEST bq response", "response", bqResponse)
-
-			faceData = map[string]interface{}{
-				"left":   bqResponse.Data.Left,
-				"top":    bqResponse.Data.Top,
-				"width":  bqResponse.Data.Width,
-				"height": bqResponse.Data.Height,
-			}
-			preview = PREVIEW_FRS
-
-			h.logger.Debug("HandleMessage | HandleDebug | get img from FRS")
-			camScreenShot = nil
-			camScreenShot, err = utils.DownloadFile(bqResponse.Data.Screenshot)
-		}
-	}
+	h.logger.Info("Call started",
+		"host", host,
+		"callID", callID,
+		"callType", callType,
+		"apartment", apartment,
+		"flatID", callData.FlatID)
 
-	//	TODO:
-	//		- 	generate hash for redis key
-	// 		-	store screenshot to redis
-	//		-	send push
-	//
+	go func() {
+		h.getCallScreenshots(callData)
+		h.activeCalls.persist(callData)
+	}()
+}
 
-	metadata := map[string]interface{}{
-		"contentType": "image/jpeg",
-		"expire":      int32(timestamp.Add(time.Hour * 24 * 30 * 6).Unix()),
-	}
-	// save data to MongoDb
-	fileId, err := h.fsFiles.SaveFile("camshot", metadata, camScreenShot)
-	if err != nil {
-		h.logger.Debug("MongoDB SaveFile", "err", err)
+func (h *BewardHandler) HandleCallAnswered(timestamp *time.Time, host string, message string, callID int) {
+	callData, ok := h.advanceCall(timestamp, host, message, callID, CallConnected, func(callData *CallData) {
+		callData.answer(timestamp)
+	})
+	if ok {
+		h.logger.Info("Call answered", "host", host, "callID", callID, "apartment", callData.Apartment)
 	}
-	h.logger.Debug("MongoDB SaveFile", "fileId", fileId)
-	camScreenShot = nil
-
-	// 9
-	eventGUIDv4 := uuid.New().String()
-	imageGUIDv4 := utils.ToGUIDv4(fileId)
-
-	flatList, _ := h.repo.Households.GetFlatIDsByRFID(context.Background(), rfidKey)
+}
 
-	watchers, err := h.repo.Households.GetWatchersByFlatID(context.Background(), flatList[0])
-	if err != nil {
-		h.logger.Warn("Failed to get watchers", "error", err)
+// HandleDoorOpen door opened by CMS handset or DTMF, the call is answered
+func (h *BewardHandler) HandleDoorOpen(timestamp *time.Time, host string, message string, callID int) {
+	callData, ok := h.advanceCall(timestamp, host, message, callID, CallDoorOpened, func(callData *CallData) {
+		callData.openDoor(timestamp)
+	})
+	if ok {
+		h.logger.Info("Door has opened by call", "host", host, "callID", callID, "apartment", callData.Apartment)
 	}
-	h.logger.Info("WATCERS", "watchers", watchers)
-
-	if watchers != nil && len(watchers) > 0 {
-		for _, watcher := range watchers {
-			if watcher.EventType == strconv.Itoa(Event.OpenByKey) {
+}
 
-			}
+// HandleSIPState SIP call state timeline, CONFIRMED is answered call
+func (h *BewardHandler) HandleSIPState(timestamp *time.Time, host string, message string, callID int) {
+	matches := bewardSIPStateRegex.FindStringSubmatch(message)
+	sipCallID, _ := strconv.Atoi(matches[1])
+	sipState, reason := matches[2], matches[3]
+
+	state := CallRinging
+	switch sipState {
+	case "EARLY":
+		state = CallEarly
+	case "CONFIRMED":
+		state = CallConnected
+	}
+
+	callData, ok := h.advanceCall(timestamp, host, message, callID, state, func(callData *CallData) {
+		callData.SIPCallID = sipCallID
+		callData.SIPStates = append(callData.SIPStates, SIPState{State: sipState, Time: *timestamp, Reason: reason})
+		if state == CallConnected {
+			callData.answer(timestamp)
 		}
-	}
-
-	// TODO: We're currently updating only one apartment out of the ones found.
-	//		Add processing to all apartments using this RFID key.
-	plogData := map[string]interface{}{
-		"date":       timestamp.Unix(),
-		"event_uuid": eventGUIDv4,
-		"hidden":     0,
-		"image_uuid": imageGUIDv4,
-		"flat_id":    flatList[0],
-		"domophone": map[string]interface{}{
-			"camera_id":             camera.CameraID,
-			"domophone_description": entrance.Entrance,
-			"domophone_id":          domophone.HouseDomophoneID,
-			"domophone_output":      entrance.DomophoneOutput,
-			"entrance_id":           entrance.HouseEntranceID,
-			"house_id":              entrance.AddressHouseID,
-		},
-		"event":   Event.OpenByKey,
-		"opened":  1, // bool
-		"face":    faceData,
-		"rfid":    rfidKey,
-		"code":    "",
-		"phones":  map[string]interface{}{},
-		"preview": preview, // 0 no image, 1 - image from DVR, 2 - image from FRS
-	}
-
-	plogDataString, err := json.Marshal(plogData)
-	if err != nil {
-		h.logger.Debug("Failed marshal JSON")
-	}
-
-	err = h.storage.Insert("plog", string(plogDataString))
-	if err != nil {
-		fmt.Println("INSERT ERR", err)
+		if reason != "" {
+			callData.DisconnectReason = reason
+		}
+	})
+	if ok {
+		h.logger.Debug("SIP call state", "host", host, "callID", callID, "sipCallID", sipCallID, "state", sipState, "reason", reason, "apartment", callData.Apartment)
 	}
 }
 
-// HandleOpenByRFID - complete
-func (h *BewardHandler) HandleOpenByRFIDTest(timestamp *time.Time, host, message string) {
-	// implement open door by RFID key logic
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel() // гарантированное освобождение ресурсов
-
-	startTime := time.Now()
-	h.logger.Debug("Open door by RFID")
-
-	frsEnabled := false
-	cameraEnabled := false
-	isExternalReader := false // external door
-	var faceData map[string]interface{}
-	var domophoneData map[string]interface{}
-	var imageGUIDv4 string
-	var hash string
-	preview := PREVIEW_IPCAM
-
-	/**
-	TODO:
-		+1 get external reader
-		+2 get door
-		+3 get RFID key from message
-		+4 update the RFID key last usage date (API call to RBT)
-		5 get streamName, streamId
-		6 get best quality image from FRS, if exist
-		7 get screenshot from media server if FRS not have image
-		8 storage image to MongoDB
-		9 create "plog" record to clickhouse
-	*/
-	// ----- 1. Get RFID reader. main or external
-	if strings.Contains(message, "external") {
-		isExternalReader = true
-	}
-
-	// ----- 2. Get door. main or addition
-	door := DOOR_MAIN
-	if isExternalReader {
-		door = DOOR_SECONDARY
-	}
-
-	// ----- 3. Extract RFID key
-	rfidKey := utils.ExtractRFIDKey(message)
-	if rfidKey != "" {
-		h.logger.Debug("RFID key found", "host", host, "rfid", rfidKey)
-	} else {
-		h.logger.Warn("RFID key not found", "host", host)
-	}
-
-	h.logger.Debug("Open by RFID", "door", door, "rfid", rfidKey)
-	/**
-	TODO:
-		- 1. API call to update RFID usage timestamp
-		- 2. get stream name
-		- 3. get best quality image from FRS or DVR by stream name
-		- 4. save image to mongoDb
-		- 5. save plog to clickhouse
-	*/
-
-	// ----- 4. Update RFID last usage
-	err := h.repo.Households.UpdateRFIDLastSeen(ctx, rfidKey)
-	if err != nil {
-		h.logger.Warn("Failed to update RFID", "error", err)
+// HandleDTMF DTMF digit by SIP call number, the domophone DTMF code opens the door
+func (h *BewardHandler) HandleDTMF(timestamp *time.Time, host string, message string) {
+	matches := bewardDTMFRegex.FindStringSubmatch(message)
+	if matches == nil {
+		h.logger.Debug("Failed to parse DTMF message", "host", host, "message", message)
 		return
 	}
+	sipCallID, _ := strconv.Atoi(matches[1])
+	digit := matches[2]
 
-	// ----- 5
-	// TODO: implement get "streamName" and "streamID" by ip intercom
-
-	// ----- get domophone
-	domophone, err := h.repo.Households.GetDomophone(ctx, "ip", host)
-	if err != nil {
-		h.logger.Warn("Failed to get domophone", "error", err)
+	callID, ok := h.activeCalls.findSIPCall(host, sipCallID)
+	if !ok {
+		h.logger.Debug("DTMF for unknown call", "host", host, "sipCallID", sipCallID)
+		return
 	}
 
-	// ----- get entrance
-	entrance, err := h.repo.Households.GetEntrance(ctx, domophone.HouseDomophoneID, door)
-	if err != nil {
-		h.logger.Warn("Failed to get entrance", "error", err)
-	}
+	h.activeCalls.advance(host, callID, CallConnected, *timestamp, func(callData *CallData) {
+		callData.answer(timestamp)
+		callData.DTMF += digit
+		if callData.Domophone != nil && callData.Domophone.DTMF != "" && digit == callData.Domophone.DTMF {
+			callData.State = CallDoorOpened
+			callData.DoorOpened = true
+		}
+	})
+}
 
-	if entrance.CameraID == nil {
-		h.logger.Warn("Failed to get camera id")
-		preview = PREVIEW_NONE
-		imageGUIDv4 = IMAGE_UUID_STUB
-	} else {
-		cameraEnabled = true
+// HandleCallEnd one of CMS or SIP calls is done, the sweeper finalizes the call if "All calls are done" is lost
+func (h *BewardHandler) HandleCallEnd(timestamp *time.Time, host string, message string, callID int) {
+	callData, ok := h.advanceCall(timestamp, host, message, callID, CallEnded, func(callData *CallData) {
+		callData.EndTime = timestamp
+	})
+	if ok {
+		h.logger.Info("Call ended",
+			"host", host,
+			"callID", callID,
+			"apartment", callData.Apartment,
+			"answered", callData.Answered,
+			"doorOpen", callData.DoorOpened)
 	}
+}
 
-	// ----- get home data. full address
-	house, err := h.repo.Households.GetHouseByEntranceID(ctx, entrance.HouseEntranceID)
-	if err != nil {
-		h.logger.Warn("Failed to get house", "error", err)
+// HandleAllCallsDone make final call event
+func (h *BewardHandler) HandleAllCallsDone(timestamp *time.Time, host string, message string, callID int) {
+	if _, ok := h.advanceCall(timestamp, host, message, callID, CallEnded, nil); !ok {
+		return
 	}
 
-	domophoneData = map[string]interface{}{
-		"camera_id":             "-",
-		"domophone_description": entrance.Entrance,
-		"domophone_id":          domophone.HouseDomophoneID,
-		"domophone_output":      entrance.DomophoneOutput,
-		"entrance_id":           entrance.HouseEntranceID,
-		"house_id":              entrance.AddressHouseID,
+	callData, exists := h.activeCalls.remove(host, callID)
+	if !exists {
+		return
 	}
-
-	// process if cam usage
-	if cameraEnabled {
-		//todo add camera id
-		domophoneData["camera_id"] = *entrance.CameraID
-
-		// get entrance camera
-		camera, err := h.repo.Cameras.GetCamera(ctx, *entrance.CameraID)
-		if err != nil {
-			h.logger.Warn("Failed to get camera", "error", err)
-		}
-
-		/**
-		TODO:
-			get screenshot from camera
-			frs enabled - get GetBestQuality
-				if GetBestQuality result - use FRS screenshot
-				if not GetBestQuality result - use first camera screen
-		*/
-		// check FRS enabled
-		if *camera.FRS != "-" {
-			h.logger.Debug("HandleDebug, FRS enabled")
-			frsEnabled = true
-		} else {
-			h.logger.Debug("Open by RFID", "frsEnabled >>", frsEnabled)
-		}
-
-		rbtAPI := h.rbtApi.Internal
-
-		// 01 - get screenshot from domophone camera
-		imgURL := rbtAPI + "/frs/camshot/" + strconv.Itoa(camera.CameraID)
-		h.logger.Debug("Open by RFID", "_imageUrl >>", imgURL)
-
-		camScreenShot, err := utils.DownloadFile(imgURL)
-		if err != nil {
-			h.logger.Debug("RBT DownloadFile", "err", err)
-			preview = PREVIEW_NONE
-		}
-
-		// 02 get screenshot from FRS
-		if frsEnabled {
-			h.logger.Debug("HandleMessage | HandleDebug | FRS enabled")
-			bqResponse, _ := utils.GetBestQuality(h.frsApi, camera.CameraID, *timestamp)
-			if bqResponse != nil {
-				h.logger.Debug("FRS BEST bq response", "response", bqResponse)
-
-				faceData = map[string]interface{}{
-					"left":   bqResponse.Data.Left,
-					"top":    bqResponse.Data.Top,
-					"width":  bqResponse.Data.Width,
-					"height": bqResponse.Data.Height,
-				}
-				preview = PREVIEW_FRS
-
-				h.logger.Debug("HandleMessage | HandleDebug | get img from FRS")
-				camScreenShot = nil
-				camScreenShot, err = utils.DownloadFile(bqResponse.Data.Screenshot)
-			}
-		}
-
-		// hash for push event
-		hash = fmt.Sprintf("%x", md5.Sum([]byte(uuid.New().String())))
-
-		shotKey := "shot_" + hash
-		if err := h.redisClient.SetEx(ctx, shotKey, camScreenShot, 15*60*time.Second).Err(); err != nil {
-			h.logger.Debug("failed to save screenshot to Redis", "err", err)
-		}
-
-		// save data to MongoDb
-		metadata := map[string]interface{}{
-			"contentType": "image/jpeg",
-			"expire":      int32(timestamp.Add(time.Hour * 24 * 30 * 6).Unix()),
-		}
-		fileId, err := h.fsFiles.SaveFile("camshot", metadata, camScreenShot)
-		if err != nil {
-			h.logger.Debug("MongoDB SaveFile", "err", err)
-		}
-		h.logger.Debug("MongoDB SaveFile", "fileId", fileId)
-		camScreenShot = nil
-
-		imageGUIDv4 = utils.ToGUIDv4(fileId)
+	if callData.EndTime == nil {
+		callData.EndTime = timestamp
 	}
 
-	// 9
-	eventGUIDv4 := uuid.New().String()
-
-	flatList, _ := h.repo.Households.GetFlatIDsByRFID_new(ctx, rfidKey)
-	h.logger.Debug("GET flat list", "result", flatList)
-
-	// TODO: get flat number for event
-	for _, flat := range flatList {
-		h.logger.Debug("✅ Flat found", "result", flat)
-
-		// TODO:
-		// 		Проходим по квартирам
-		// 		создаем событие открытие двери для каждой квартиры
-		//		проверяем подписчиков на уведомления, отправляем push
-
-		// check watchers for flat
-		watchers, err := h.repo.Households.GetWatchersByFlatID(ctx, flat.HouseFlatID)
-		if err != nil {
-			h.logger.Warn("Failed to get watchers", "error", err)
-		}
-
-		if watchers != nil && len(watchers) > 0 {
-			for _, watcher := range watchers {
-
-				// watch RFID
-				// TODO: get RFID key name
-				if watcher.EventType == strconv.Itoa(Event.OpenByKey) {
-					h.logger.Debug("WATCERS", "watcher found", watcher)
-					msgTitle := "Открытие двери"
-
-					addressStr := house.HouseFull + "кв." + flat.Flat
-					msgBody := fmt.Sprintf("Адрес: %s\nКлюч: %s\nВладелец: %s",
-						addressStr, "key name example", rfidKey)
-
-					device, _ := h.repo.Households.GetMobileDeviceByID(ctx, watcher.DeviceID)
-
-					go utils.SendPush(hash, msgTitle, msgBody, device.PushToken, device.PushTokenType, device.Platform)
-				}
-			}
-		} else {
-			h.logger.Debug("Watchers not found")
-		}
+	h.logger.Info("All calls completed for apartment", "host", host, "callID", callID, "apartment", callData.Apartment)
 
-		plogData := map[string]interface{}{
-			"date":       timestamp.Unix(),
-			"event_uuid": eventGUIDv4,
-			"hidden":     0,
-			"image_uuid": imageGUIDv4,
-			"flat_id":    flat.HouseFlatID,
-			"domophone":  domophoneData,
-			"event":      Event.OpenByKey,
-			"opened":     1, // bool
-			"face":       faceData,
-			"rfid":       rfidKey,
-			"code":       "",
-			"phones":     map[string]interface{}{},
-			"preview":    preview,
-		}
+	h.finalizeCall(callData)
+}
 
-		plogDataString, err := json.Marshal(plogData)
-		if err != nil {
-			h.logger.Debug("Failed marshal JSON")
+// advanceCall move call state, unknown call is registered first: start messages may be lost.
+// End messages of unknown call are dropped, the call is already finalized: second call leg, reordered UDP or sweeper
+func (h *BewardHandler) advanceCall(timestamp *time.Time, host, message string, callID int, state CallState, update func(callData *CallData)) (*CallData, bool) {
+	if _, exists := h.activeCalls.get(host, callID); !exists {
+		if state == CallEnded {
+			h.logger.Debug("Call end for unknown call, dropped", "host", host, "callID", callID)
+			return nil, false
 		}
-
-		err = h.storage.Insert("plog", string(plogDataString))
-		if err != nil {
-			fmt.Println("INSERT ERR", err)
+		if state != CallRinging {
+			h.logger.Debug("Call event for unknown call", "host", host, "callID", callID, "state", state)
+			h.HandleCallStart(timestamp, host, message, callID)
 		}
 	}
 
-	duration := time.Since(startTime)
-	h.logger.Debug("HandleOpenByRFIDTest finish", "duration seconds", duration.Seconds())
-}
-
-// HandleOpenByButton - not implemented
-func (h *BewardHandler) HandleOpenByButton(timestamp *time.Time, host, message string) {
-	// implement open door by open button
-	h.logger.Debug("Open door by button", "host", host, "message", message)
-	var door int
-	var detail string
-
-	door = 0
-	detail = "main"
-
-	if strings.Contains(message, "Additional") {
-		door = 1
-		detail = "second"
-	}
-
-	h.logger.Debug("Open door by button", "host", host, "detail", detail, "door", door)
-}
-
-// -------
-
-func (h *BewardHandler) HandleCallFlow(timestamp *time.Time, host, message string) {
-	// implement call flow logic
-	//h.logger.Info("⚠️ HandleCallFlow Start")
-	callID, err := h.extractCallID(message)
-	if err != nil {
-		h.logger.Warn("HandleCallFlow extractCallID", "err", err)
-	}
-
-	h.logger.Debug("callID", "callID", callID)
-
-	// 01 - Call start +
-	if strings.Contains(message, "CMS handset call started for apartment") ||
-		strings.Contains(message, "Unable to call CMS apartment") ||
-		strings.Contains(message, "CMS handset is not connected for apartment") {
-		//strings.Contains(message, "Calling sip:")
-
-		h.HandleCallStart(timestamp, host, message, callID)
-		return
-	}
-
-	// 02 - Call answered +
-	if strings.Contains(message, "CMS handset talk started for apartment") ||
-		strings.Contains(message, "SIP talk started for apartment") {
-		h.HandleCallAnswered(timestamp, host, message, callID)
-		return
-	}
-
-	// Door opened +
-	if strings.Contains(message, "Opening door by CMS handset for apartment") {
-		h.HandleDoorOpen(timestamp, host, message, callID)
-		return
+	callData, exists := h.activeCalls.advance(host, callID, state, *timestamp, update)
+	if !exists {
+		return nil, false
 	}
 
-	// Call ended, make event
-	if strings.Contains(message, "CMS handset call done for apartment") ||
-		strings.Contains(message, "SIP call done for apartment") {
-		h.HandleCallEnd(timestamp, host, message, callID)
-		return
+	// SIP call apartment
+	if callData.FlatID == 0 {
+		if apartment, err := extractApartment(message); err == nil {
+			h.setCallApartment(callData, apartment)
+		}
 	}
 
-	// Clear call flow
-	if strings.Contains(message, "All calls are done for apartment") {
-		h.HandleAllCallsDone(timestamp, host, message, callID)
-		return
-	}
+	return callData, true
 }
 
-// HandleCallStart -  get base event info
-func (h *BewardHandler) HandleCallStart(timestamp *time.Time, host string, message string, callID int) {
-	h.logger.Info("🎃 01 - HandleCallStart start")
-
+// setCallApartment resolve flat for call registered without apartment
+func (h *BewardHandler) setCallApartment(callData *CallData, apartment int) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
 	defer cancel()
 
-	// get flat number
-	apartment, err := h.extractApartment(message)
+	flatID, err := h.repo.Households.GetFlatIDByApartment(ctx, apartment, callData.Domophone.HouseDomophoneID)
 	if err != nil {
-		h.logger.Warn("Failed to extract apartment from call start", "message", message)
+		h.logger.Warn("Failed to get flatID", "callID", callData.CallID, "apartment", apartment, "error", err)
 		return
 	}
 
-	h.logger.Debug("🎃 01 - HandleCallStart start", "apartment", apartment)
-
-	// call type: SIP or CMS
-	callType := CALL_TYPE_SIP
-	if strings.Contains(message, "CMS handset call started") {
-		callType = CALL_TYPE_CMS
-	}
+	callData.callMutex.Lock()
+	callData.Apartment = apartment
+	callData.FlatID = flatID
+	callData.callMutex.Unlock()
 
-	h.callMutex.Lock()
-	defer h.callMutex.Unlock()
+	h.activeCalls.persist(callData)
+}
 
-	// get domophone data
-	domophone, err := h.repo.Households.GetDomophone(ctx, "ip", host)
-	if err != nil {
-		h.logger.Warn("Failed to get domophone", "callID", callID, "err", err)
-		return
+// Start restore calls active before restart, run call states writer and call sweeper,
+// the sweeper finalizes calls stuck in a state: lost UDP messages.
+// On shutdown it returns after the final call events are queued
+func (h *BewardHandler) Start(ctx context.Context) error {
+	restored, err := h.activeCalls.restore(ctx)
+	if err != nil {
+		h.logger.Warn("Failed to restore active calls", "error", err)
+	} else if restored > 0 {
+		h.logger.Info("Active calls restored", "count", restored)
+	}
+
+	// call states writer, the last states are written on shutdown
+	storeDone := make(chan struct{})
+	go func() {
+		defer close(storeDone)
+		h.activeCalls.runStore(ctx)
+	}()
+
+	ticker := time.NewTicker(callSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			h.finals.Wait()
+			<-storeDone
+			return nil
+		case now := <-ticker.C:
+			h.sweep(now)
+		}
 	}
+}
 
-	// get entrance
-	entrance, err := h.repo.Households.GetEntrance(ctx, domophone.HouseDomophoneID, 0)
-	if err != nil {
-		h.logger.Warn("Failed to get entrance info", "callID", callID, "error", err)
-		return
-	}
+// sweep finalize calls with state timeout passed
+func (h *BewardHandler) sweep(now time.Time) {
+	for _, callData := range h.activeCalls.expired(now) {
+		h.logger.Warn("Call finalized by timeout",
+			"host", callData.DomophoneIP,
+			"callID", callData.CallID,
+			"state", callData.State,
+			"apartment", callData.Apartment)
 
-	// get camera data
-	camera, err := h.repo.Cameras.GetCamera(ctx, *entrance.CameraID)
-	if err != nil {
-		h.logger.Warn("Failed to get camera", "callID", callID, "error", err)
-		return
+		if callData.EndTime == nil {
+			endTime := callData.StateTime
+			callData.EndTime = &endTime
+		}
+		h.finalizeCall(callData)
 	}
+}
 
-	// get flat
-	flatID, err := h.repo.Households.GetFlatIDByApartment(ctx, apartment, domophone.HouseDomophoneID)
-	if err != nil {
-		h.logger.Warn("Failed to get flatID", "callID", callID, "error", err)
+// finalizeCall plog NotAnswered or Answered call event
+func (h *BewardHandler) finalizeCall(callData *CallData) {
+	if callData.FlatID == 0 {
+		h.logger.Warn("Call without flat, event skipped", "host", callData.DomophoneIP, "callID", callData.CallID)
 		return
 	}
 
-	// make data structure
-	callData := &CallData{
-		CallID:      callID,
-		Apartment:   apartment,
-		DomophoneIP: host,
-		StartTime:   timestamp,
-		CallType:    callType,
-		Answered:    false,
-		DoorOpened:  false,
-		Domophone:   domophone,
-		Entrance:    entrance,
-		FlatID:      flatID,
-	}
-
-	// store cameraId if exist
-	if entrance.CameraID != nil {
-		callData.CameraID = *entrance.CameraID
-	}
-	if *camera.FRS != "-" {
-		callData.CameraFRS = *camera.FRS
-	}
-
-	h.activeCalls[callID] = callData
-
-	h.logger.Info("️️️️️️⚠️️️ Call started - data collected",
-		"callID", callID,
-		"apartment", apartment,
-		"domophone", domophone.HouseDomophoneID,
-		"flatID", flatID)
-
-	// get cam screenshot
-	go h.getCallScreenshots(callData)
+	h.finals.Add(1)
+	go func() {
+		defer h.finals.Done()
+		h.prepareFinalCallEvent(callData)
+	}()
 }
 
-// utils,  get callID and apartment
-func (h *BewardHandler) extractCallID(message string) (int, error) {
+// utils, get callID and apartment, shared by Beward DKS and DS handlers
+func extractCallID(message string) (int, error) {
 	start := strings.Index(message, "[")
 	if start == -1 {
 		return 0, fmt.Errorf("opening bracket not found")
@@ -1336,7 +637,7 @@ func (h *BewardHandler) extractCallID(message string) (int, error) {
 	return callID, nil
 }
 
-func (h *BewardHandler) extractApartment(message string) (int, error) {
+func extractApartment(message string) (int, error) {
 	if idx := strings.Index(message, "apartment "); idx != -1 {
 		start := idx + len("apartment ")
 		end := strings.IndexAny(message[start:], " ,!].")
@@ -1350,7 +651,7 @@ func (h *BewardHandler) extractApartment(message string) (int, error) {
 	return 0, fmt.Errorf("apartment not found in message")
 }
 
-func (h *BewardHandler) extractSIPCallID(message string) (int, error) {
+func extractSIPCallID(message string) (int, error) {
 	if idx := strings.Index(message, "SIP call "); idx != -1 {
 		start := idx + len("SIP call ")
 		end := strings.IndexAny(message[start:], " ]")
@@ -1382,140 +683,6 @@ func (h *BewardHandler) extractSIPCallID(message string) (int, error) {
 //	return ""
 //}
 
-func (h *BewardHandler) HandleCallAnswered(timestamp *time.Time, host string, message string, callID int) {
-	// TODO: implement me!
-	h.logger.Info("🎃 -  HandleCallAnswered start")
-	h.logger.Info("🎃 -  HandleCallAnswered start", "callID", callID, "msg", message)
-
-	h.callMutex.Lock()
-	defer h.callMutex.Unlock()
-
-	callData, exists := h.activeCalls[callID]
-	if !exists {
-		h.logger.Debug("Call answered for unknown call", "callID", callID)
-		return
-	}
-
-	callData.Answered = true
-
-	h.logger.Info("Call answered",
-		"callID", callID,
-		"apartment", callData.Apartment)
-}
-
-func (h *BewardHandler) HandleDoorOpen(timestamp *time.Time, host string, message string, callID int) {
-	// TODO: implement me!
-	// set door opened for call state
-	h.logger.Info("🎃 -  HandleDoorOpen start")
-
-	h.callMutex.Lock()
-	defer h.callMutex.Unlock()
-
-	callData, exists := h.activeCalls[callID]
-	if !exists {
-		h.logger.Debug("Call door open for unknown call", "callID", callID)
-	}
-
-	callData.DoorOpened = true
-	h.logger.Info("Door has opened by call",
-		"callID", callID,
-		"apartment", callData.Apartment)
-}
-
-func (h *BewardHandler) HandleCallEnd(timestamp *time.Time, host string, message string, callID int) {
-	// TODO: implement me!
-	h.logger.Info("🎃 - HandleCallEnd start")
-
-	h.callMutex.Lock()
-	callData, exists := h.activeCalls[callID]
-	defer h.callMutex.Unlock()
-
-	if !exists {
-		h.logger.Debug("Call end for unknown call", "callID", callID)
-		return
-	}
-
-	callData.EndTime = timestamp
-
-	h.logger.Info("Call ended, prepare event",
-		"callID", callID,
-		"apartment", callData.Apartment,
-		"answered", callData.Answered,
-		"doorOpen", callData.DoorOpened,
-	)
-
-	// process make event
-	//go h.prepareFinalCallEvent(callData)
-}
-
-func (h *BewardHandler) prepareFinalCallEvent(callData *CallData) {
-	h.logger.Info("🎃 - prepareFinalCallEvent start")
-	startTime := time.Now()
-
-	// Wait until the screenshots are ready (maximum 15 seconds)
-	for i := 0; i < 30; i++ {
-		callData.callMutex.Lock()
-		ready := callData.screenshotsReady
-		callData.callMutex.Unlock()
-
-		if ready {
-			break
-		}
-		time.Sleep(500 * time.Millisecond)
-	}
-
-	callData.callMutex.Lock()
-	fileID := callData.screenshotFileID
-	callData.callMutex.Unlock()
-
-	//FIXME:
-	if fileID == "" {
-		h.logger.Warn("Screenshots not ready for final event", "callID", callData.CallID)
-		// store  with not image
-		fileID = "no_screenshot"
-	}
-
-	// make final event
-	h.saveFinalCallEvent(callData, fileID)
-
-	h.logger.Info("Call processing completed",
-		"callID", callData.CallID,
-		"duration", time.Since(startTime))
-}
-
-func (h *BewardHandler) HandleAllCallsDone(timestamp *time.Time, host string, message string, callID int) {
-	h.logger.Info("🎃 - HandleAllCallsDone start")
-	apartment, err := h.extractApartment(message)
-	if err != nil {
-		h.logger.Warn("Failed to extract apartment from all calls done", "callID", callID)
-		return
-	}
-
-	h.callMutex.Lock()
-	callData, exists := h.activeCalls[callID]
-	defer h.callMutex.Unlock()
-
-	if !exists {
-		h.logger.Debug("Call end for unknown call", "callID", callID)
-		return
-	}
-
-	callData.EndTime = timestamp
-
-	// process make event
-	go h.prepareFinalCallEvent(callData)
-
-	// Удаляем все звонки для этой квартиры
-	for id, callData := range h.activeCalls {
-		if callData.Apartment == apartment {
-			delete(h.activeCalls, id)
-			h.logger.Debug("Removed call data", "callID", id, "apartment", apartment)
-		}
-	}
-
-	h.logger.Info("🎃 All calls completed for apartment", "apartment", apartment)
-}
-
 //func (h *BewardHandler) processCallEvent(callData *CallData) {
 //	// process event
 //	//startTime := time.Now()
@@ -1529,189 +696,18 @@ func (h *BewardHandler) HandleAllCallsDone(timestamp *time.Time, host string, me
 //	// get
 //}
 
-func (h *BewardHandler) getCallScreenshots(callData *CallData) {
-	// TODO : implement get image from cam and best screen from FRS service
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	h.logger.Info("Starting call screenshots processing", "callId", callData.CallID)
-
-	// + 1 get screen from camera domophone
-	if err := h.getCameraScreenshot(ctx, callData); err != nil {
-		h.logger.Warn("Failed to get screenshot from domophone camera", "callId", callData.CallID, "error", err)
-	} else {
-		h.logger.Debug("Camera screenshot completed", "callID", callData.CallID, "dataSize", len(callData.ScreenshotData))
-	}
-
-	// 2 get image from FRS (optional)
-	// TODO: refactor CameraFRS value
-	if callData.CameraID > 0 && callData.CameraFRS != "" {
-		if err := h.getFRSBestQuality(ctx, callData); err != nil {
-			h.logger.Debug("FRS best quality not available", "err", err)
-		} else {
-			h.logger.Debug("FRS processing completed")
-		}
-	}
-
-	// 3 store image
-	fileID, err := h.saveScreenshotToMongo(callData)
-	if err != nil {
-		h.logger.Warn("Failed to save screenshot to Mongo", "callId", callData.CallID, "error", err)
-	}
-
-	// 4 update callData
-	callData.callMutex.Lock()
-	callData.screenshotFileID = fileID
-	callData.screenshotsReady = true
-	callData.callMutex.Unlock()
-
-	h.logger.Info("Call screenshot processed", "callId", callData.CallID, "fileID", fileID)
-}
-
-func (h *BewardHandler) getCameraScreenshot(ctx context.Context, callData *CallData) error {
-	if callData.CameraID == 0 {
-		return fmt.Errorf("no camera ID available")
-	}
-
-	imgURL := h.rbtApi.Internal + "/frs/camshot/" + strconv.Itoa(callData.CameraID)
-
-	h.logger.Debug("Getting camera screenshot", "callID", callData.CallID, "url", imgURL)
-
-	screenshotData, err := utils.DownloadFile(imgURL)
-	if err != nil {
-		return fmt.Errorf("failed to download screenshot: %w", err)
+// extractOpenCode code from "Opening door by code 55544, apartment 1"
+func extractOpenCode(message string) (string, error) {
+	_, after, found := strings.Cut(message, "code")
+	if !found {
+		return "", fmt.Errorf("no code in message")
 	}
 
-	callData.ScreenshotData = screenshotData
-	callData.PreviewType = PREVIEW_IPCAM
-
-	h.logger.Debug("Camera screenshot obtained", "callID", callData.CallID, "size", len(screenshotData))
-	return nil
-}
-func (h *BewardHandler) getFRSBestQuality(ctx context.Context, callData *CallData) error {
-	h.logger.Debug("Getting FRS best quality", "callID", callData.CallID, "camera", callData.CameraID)
-
-	// Get best frame from FRS (call started screenshot)
-	bqResponse, err := utils.GetBestQuality(h.frsApi, callData.CameraID, *callData.StartTime)
-	if err != nil {
-		return fmt.Errorf("failed to get FRS best quality: %w", err)
-	}
-
-	if bqResponse == nil || bqResponse.Data.Screenshot == "" {
-		return fmt.Errorf("no FRS best quality data available")
-	}
-
-	// get screenshot from FRS service
-	frsScreenshot, err := utils.DownloadFile(bqResponse.Data.Screenshot)
-	if err != nil {
-		return fmt.Errorf("failed to download FRS screenshot: %w", err)
-	}
-
-	// use FRS image
-	callData.ScreenshotData = frsScreenshot
-	callData.PreviewType = PREVIEW_FRS
-
-	// set face data
-	callData.FaceData = map[string]interface{}{
-		"left":   bqResponse.Data.Left,
-		"top":    bqResponse.Data.Top,
-		"width":  bqResponse.Data.Width,
-		"height": bqResponse.Data.Height,
-	}
-
-	h.logger.Debug("FRS best quality obtained", "callID", callData.CallID, "faceData", callData.FaceData)
-	return nil
-}
-func (h *BewardHandler) saveScreenshotToMongo(callData *CallData) (string, error) {
-	if callData.ScreenshotData == nil {
-		return "", fmt.Errorf("no screenshot data available")
-	}
-
-	metadata := map[string]interface{}{
-		"contentType": "image/jpeg",
-		"expire":      int32(time.Now().Add(TTL_CAMSHOT_HOURS).Unix()),
-		"call_id":     callData.CallID,           // optional, test field
-		"camera_id":   callData.CameraID,         // optional, test field
-		"timestamp":   callData.StartTime.Unix(), // optional, test field
-	}
-
-	fileID, err := h.fsFiles.SaveFile("camshot", metadata, callData.ScreenshotData)
-	if err != nil {
-		return "", fmt.Errorf("failed to save file to MongoDB: %w", err)
-	}
-
-	// clear memory
-	callData.ScreenshotData = nil
-
-	h.logger.Debug("Screenshot saved to MongoDB", "callID", callData.CallID, "fileID", fileID)
-	return fileID, nil
-}
-
-func (h *BewardHandler) saveFinalCallEvent(callData *CallData, fileID string) {
-	h.logger.Info("🎃 - saveFinalCallEvent start")
-	// Определяем тип события на основе того, что произошло во время звонка
-	eventType := Event.NotAnswered
-	opened := 0
-
-	if callData.Answered && callData.DoorOpened {
-		eventType = Event.Answered
-		opened = 1
-	} else if callData.Answered {
-		eventType = Event.Answered
-	}
-
-	// make GUID from fileID
-	imageGUID := utils.ToGUIDv4(fileID)
-
-	//  make plof data
-	plogData := map[string]interface{}{
-		"date":       callData.StartTime.Unix(),
-		"event_uuid": uuid.New().String(),
-		"hidden":     0,
-		"image_uuid": imageGUID,
-		"flat_id":    callData.FlatID,
-		"domophone": map[string]interface{}{
-			"camera_id":             callData.CameraID,
-			"domophone_description": callData.Entrance.Entrance,
-			"domophone_id":          callData.Domophone.HouseDomophoneID,
-			"domophone_output":      callData.Entrance.DomophoneOutput,
-			"entrance_id":           callData.Entrance.HouseEntranceID,
-			"house_id":              callData.Entrance.AddressHouseID,
-		},
-		"event":   eventType,
-		"opened":  opened,
-		"face":    callData.FaceData,
-		"preview": callData.PreviewType,
-		//"call_info": map[string]interface{}{
-		//	"call_id":     callData.CallID,
-		//	"apartment":   callData.Apartment,
-		//	"call_type":   callData.CallType,
-		//	"answered":    callData.Answered,
-		//	"door_opened": callData.DoorOpened,
-		//	"duration":    callData.EndTime.Sub(*callData.StartTime).Seconds(),
-		//},
-		"phones": map[string]interface{}{},
-	}
-
-	// Конвертируем в JSON
-	plogDataString, err := json.Marshal(plogData)
-	if err != nil {
-		h.logger.Warn("Failed to marshal final call event", "callID", callData.CallID, "error", err)
-		return
-	}
-
-	// Сохраняем в хранилище
-	err = h.storage.Insert("plog", string(plogDataString))
-	if err != nil {
-		h.logger.Warn("Failed to insert final call event to plog", "callID", callData.CallID, "error", err)
-	} else {
-		h.logger.Info("🎃 - Final call event saved to plog",
-			"callID", callData.CallID,
-			"apartment", callData.Apartment,
-			"eventType", eventType,
-			"answered", callData.Answered,
-			"doorOpened", callData.DoorOpened)
+	code := strings.TrimSpace(strings.SplitN(after, ",", 2)[0])
+	if _, err := strconv.Atoi(code); err != nil {
+		return "", fmt.Errorf("invalid code %q: %w", code, err)
 	}
+	return code, nil
 }
 
 // --- debug
@@ -1720,7 +716,7 @@ func (h *BewardHandler) HandleDebug(timestamp *time.Time, host, message string)
 	//fakeMsg := "Opening door by RFID 00000033750177, apartment 0"
 	fakeMsg := "Opening door by code 55544, apartment 1"
 
-	h.HandleOpenByCodeTest(timestamp, host, fakeMsg)
+	h.HandleOpenByCode(timestamp, host, fakeMsg)
 
 	//fakeRfid := "00000004030201"
 	//h.watcherProcess(fakeRfid)
@@ -0,0 +1,325 @@
+package handlers
+
+import (
+	"context"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/config"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/syslog_custom"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/utils"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Beward DS events, see draft/events_beward_ds.md
+const (
+	BEWARD_DS_MOTION_START = iota + 1
+	BEWARD_DS_MOTION_STOP
+	BEWARD_DS_OPEN_BY_RFID
+	BEWARD_DS_OPEN_BY_BUTTON
+	BEWARD_DS_CALL_START
+	BEWARD_DS_CALL_ANSWERED
+	BEWARD_DS_CALL_DOOR_OPENED
+	BEWARD_DS_CALL_DONE
+	BEWARD_DS_BREAK_IN
+	BEWARD_DS_TAMPER
+)
+
+// bewardDSMessages DS message fragments, first match wins
+var bewardDSMessages = []struct {
+	pattern string
+	event   int
+}{
+	{"SS_MAINAPI_ReportAlarmHappen", BEWARD_DS_MOTION_START},
+	{"SS_MAINAPI_ReportAlarmFinish", BEWARD_DS_MOTION_STOP},
+	{"Opening door by RFID", BEWARD_DS_OPEN_BY_RFID},
+	{"Opening door by external RFID", BEWARD_DS_OPEN_BY_RFID},
+	{"door button pressed", BEWARD_DS_OPEN_BY_BUTTON},
+	{"Intercom break in detected", BEWARD_DS_BREAK_IN},
+	{"Calling sip:", BEWARD_DS_CALL_START},
+	{"SIP talk started", BEWARD_DS_CALL_ANSWERED},
+	{"Opening door by DTMF command", BEWARD_DS_CALL_DOOR_OPENED},
+	{"SIP call done", BEWARD_DS_CALL_DONE},
+	{"All calls are done", BEWARD_DS_CALL_DONE},
+}
+
+// DS call states
+const (
+	dsCallRinging = iota
+	dsCallTalking
+	dsCallDone
+)
+
+// dsCallTimeouts max time in the state without messages, then the sweeper finalizes the call
+var dsCallTimeouts = map[int]time.Duration{
+	dsCallRinging: callStateTimeouts[CallRinging],
+	dsCallTalking: callStateTimeouts[CallConnected],
+}
+
+type dsCall struct {
+	data  *CallData
+	state int
+}
+
+// BewardDSHandler handles messages specific to Beward DS panels, SIP calls only
+type BewardDSHandler struct {
+	baseHandler
+	calls  map[string]*dsCall // key: domophone ip and beward callId
+	mu     sync.Mutex
+	finals sync.WaitGroup // final call events in progress
+}
+
+func init() {
+	Register(Vendor{
+		Name:  "beward_ds",
+		Unit:  "BewardDS",
+		Needs: needEvents | NeedFrs,
+		Syslog: func(deps *Deps, _ config.PanelConfig) syslog_custom.MessageHandler {
+			return NewBewardDSHandler(deps)
+		},
+	})
+}
+
+// NewBewardDSHandler creates a new BewardDSHandler
+func NewBewardDSHandler(deps *Deps) *BewardDSHandler {
+	return &BewardDSHandler{
+		baseHandler: newBaseHandler(deps, "beward_ds"),
+		calls:       make(map[string]*dsCall),
+	}
+}
+
+// HandleMessage processes Beward DS messages
+func (h *BewardDSHandler) HandleMessage(srcIP string, message *syslog_custom.SyslogMessage) {
+	// FIXME: load location from system or config
+	location, _ := time.LoadLocation("Europe/Moscow")
+	now := time.Now().In(location).Truncate(time.Second)
+
+	// filter
+	if h.FilterMessage(message.Message) {
+		return
+	}
+
+	h.logger.Debug("HandleMessage || Processing Beward DS message", "ip", srcIP, "host", message.HostName, "message", message.Message)
+
+	host := h.resolveHost(srcIP, message)
+	h.storeSyslog(host, message.Message)
+
+	msg := message.Message
+	event := 0
+	for _, m := range bewardDSMessages {
+		if strings.Contains(msg, m.pattern) {
+			event = m.event
+			break
+		}
+	}
+	if event == 0 && isTamperMessage(msg) {
+		event = BEWARD_DS_TAMPER
+	}
+
+	switch event {
+	case BEWARD_DS_MOTION_START:
+		h.HandleMotionDetection(&now, host, true)
+	case BEWARD_DS_MOTION_STOP:
+		h.HandleMotionDetection(&now, host, false)
+	case BEWARD_DS_OPEN_BY_RFID:
+		h.HandleOpenByRFID(&now, host, msg)
+	case BEWARD_DS_OPEN_BY_BUTTON:
+		h.HandleOpenByButton(&now, host, msg)
+	case BEWARD_DS_BREAK_IN:
+		h.HandleBreakIn(&now, host, msg)
+	case BEWARD_DS_TAMPER:
+		h.HandleTamper(&now, host, msg)
+	case BEWARD_DS_CALL_START, BEWARD_DS_CALL_ANSWERED, BEWARD_DS_CALL_DOOR_OPENED, BEWARD_DS_CALL_DONE:
+		h.HandleCallFlow(&now, host, msg, event)
+	}
+}
+
+// HandleOpenByRFID main or external reader
+func (h *BewardDSHandler) HandleOpenByRFID(timestamp *time.Time, host, message string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	door := DOOR_MAIN
+	if strings.Contains(message, "external") {
+		door = DOOR_SECONDARY
+	}
+
+	h.openByKey(ctx, DoorOpenEvent{Timestamp: timestamp, Host: host, Door: door}, utils.ExtractRFIDKey(message))
+}
+
+// HandleOpenByButton main or additional door exit button
+func (h *BewardDSHandler) HandleOpenByButton(timestamp *time.Time, host, message string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	door := DOOR_MAIN
+	if strings.Contains(message, "Additional") {
+		door = DOOR_SECONDARY
+	}
+
+	h.processDoorOpen(ctx, DoorOpenEvent{
+		Timestamp: timestamp,
+		Host:      host,
+		Door:      door,
+		Event:     Event.OpenByButton,
+	})
+}
+
+// HandleBreakIn door opened without open command
+func (h *BewardDSHandler) HandleBreakIn(timestamp *time.Time, host, message string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	h.processSecurityEvent(ctx, SecurityEvent{
+		Timestamp: timestamp,
+		Host:      host,
+		Door:      DOOR_MAIN,
+		Type:      SECURITY_BREAK_IN,
+		Message:   message,
+	})
+}
+
+// HandleTamper panel case opened
+func (h *BewardDSHandler) HandleTamper(timestamp *time.Time, host, message string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	h.processSecurityEvent(ctx, SecurityEvent{
+		Timestamp: timestamp,
+		Host:      host,
+		Door:      DOOR_MAIN,
+		Type:      SECURITY_TAMPER,
+		Message:   message,
+	})
+}
+
+// HandleCallFlow DS call state machine: ringing -> talking -> done, keyed by beward callId
+func (h *BewardDSHandler) HandleCallFlow(timestamp *time.Time, host, message string, event int) {
+	callID, err := extractCallID(message)
+	if err != nil {
+		h.logger.Warn("HandleCallFlow extractCallID", "host", host, "err", err)
+		return
+	}
+	key := host + "|" + strconv.Itoa(callID)
+
+	if event == BEWARD_DS_CALL_START {
+		h.HandleCallStart(timestamp, host, message, key, callID)
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	call, exists := h.calls[key]
+	if !exists {
+		h.logger.Debug("Call event for unknown call", "unit", h.unit, "host", host, "callID", callID)
+		return
+	}
+	call.data.StateTime = *timestamp
+
+	switch event {
+	case BEWARD_DS_CALL_ANSWERED:
+		if call.state == dsCallRinging {
+			call.state = dsCallTalking
+			call.data.answer(timestamp)
+		}
+	case BEWARD_DS_CALL_DOOR_OPENED:
+		call.data.openDoor(timestamp)
+	case BEWARD_DS_CALL_DONE:
+		call.state = dsCallDone
+		call.data.EndTime = timestamp
+		delete(h.calls, key)
+
+		h.logger.Info("Call ended",
+			"unit", h.unit,
+			"host", host,
+			"callID", callID,
+			"apartment", call.data.Apartment,
+			"answered", call.data.Answered,
+			"doorOpen", call.data.DoorOpened)
+
+		h.finalizeCall(call.data)
+	}
+}
+
+// HandleCallStart collect call data and get camshot, previous call with the same id is finished
+func (h *BewardDSHandler) HandleCallStart(timestamp *time.Time, host, message, key string, callID int) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	apartment, err := extractApartment(message)
+	if err != nil {
+		h.logger.Warn("Failed to extract apartment from call start", "host", host, "message", message)
+		return
+	}
+
+	callData, err := h.newCallData(ctx, timestamp, host, nil, apartment, CALL_TYPE_SIP)
+	if err != nil {
+		h.logger.Warn("Failed to collect call data", "unit", h.unit, "host", host, "apartment", apartment, "error", err)
+		return
+	}
+	callData.CallID = callID
+	callData.StateTime = *timestamp
+
+	h.mu.Lock()
+	if call, exists := h.calls[key]; exists {
+		h.logger.Warn("Call finished without end event", "unit", h.unit, "host", host, "callID", callID)
+		h.finalizeCall(call.data)
+	}
+	h.calls[key] = &dsCall{data: callData, state: dsCallRinging}
+	h.mu.Unlock()
+
+	h.logger.Info("Call started", "unit", h.unit, "host", host, "callID", callID, "apartment", apartment, "flatID", callData.FlatID)
+
+	go h.getCallScreenshots(callData)
+}
+
+// Start call sweeper, calls without end event are finished by state timeout
+func (h *BewardDSHandler) Start(ctx context.Context) error {
+	ticker := time.NewTicker(callSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			h.finals.Wait()
+			return nil
+		case now := <-ticker.C:
+			h.sweep(now)
+		}
+	}
+}
+
+// sweep finalize calls with state timeout passed
+func (h *BewardDSHandler) sweep(now time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for key, call := range h.calls {
+		if now.Sub(call.data.StateTime) <= dsCallTimeouts[call.state] {
+			continue
+		}
+
+		h.logger.Warn("Call finalized by timeout",
+			"unit", h.unit,
+			"host", call.data.DomophoneIP,
+			"callID", call.data.CallID,
+			"apartment", call.data.Apartment)
+
+		delete(h.calls, key)
+		if call.data.EndTime == nil {
+			endTime := call.data.StateTime
+			call.data.EndTime = &endTime
+		}
+		h.finalizeCall(call.data)
+	}
+}
+
+// finalizeCall plog NotAnswered or Answered call event, waited on shutdown
+func (h *BewardDSHandler) finalizeCall(callData *CallData) {
+	h.finals.Add(1)
+	go func() {
+		defer h.finals.Done()
+		h.prepareFinalCallEvent(callData)
+	}()
+}
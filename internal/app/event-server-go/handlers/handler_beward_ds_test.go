@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/events"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/repository/models"
+	"testing"
+	"time"
+)
+
+func TestBewardDSCallSweep(t *testing.T) {
+	const host = "192.168.1.20"
+	beward, store := newTestBewardHandler(t)
+	h := &BewardDSHandler{baseHandler: beward.baseHandler, calls: make(map[string]*dsCall)}
+
+	now := time.Now()
+	h.calls[host+"|9"] = &dsCall{state: dsCallRinging, data: &CallData{
+		CallID:           9,
+		Apartment:        12,
+		DomophoneIP:      host,
+		StartTime:        &now,
+		StateTime:        now,
+		Domophone:        &models.Domophone{HouseDomophoneID: 7},
+		Entrance:         &models.HouseEntrance{HouseEntranceID: 3, AddressHouseID: 5},
+		FlatID:           42,
+		imageUUID:        events.ImageUUIDStub,
+		screenshotsReady: true,
+	}}
+
+	// talking call is kept longer than ringing one
+	answered := now.Add(time.Minute)
+	h.HandleCallFlow(&answered, host, "[9] SIP talk started for apartment 12", BEWARD_DS_CALL_ANSWERED)
+	h.sweep(answered.Add(dsCallTimeouts[dsCallRinging] + time.Second))
+	if len(h.calls) != 1 {
+		t.Fatalf("talking call is finalized by ringing timeout")
+	}
+
+	h.sweep(answered.Add(dsCallTimeouts[dsCallTalking] + time.Second))
+	h.finals.Wait()
+	if len(h.calls) != 0 {
+		t.Errorf("active calls = %d after sweep, want 0", len(h.calls))
+	}
+	if got := store.count(); got != 1 {
+		t.Errorf("plog rows = %d, want 1", got)
+	}
+
+	// end message after the sweep makes no second event
+	late := answered.Add(time.Hour)
+	h.HandleCallFlow(&late, host, "[9] All calls are done for apartment 12", BEWARD_DS_CALL_DONE)
+	h.finals.Wait()
+	if got := store.count(); got != 1 {
+		t.Errorf("plog rows = %d after late end, want 1", got)
+	}
+}
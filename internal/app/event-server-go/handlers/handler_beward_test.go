@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"context"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/events"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/repository"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/repository/models"
+	storage2 "github.com/kulakoff/event-server-go/internal/app/event-server-go/storage"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakePlogStore counts plog rows, other methods are not used by the call flow
+type fakePlogStore struct {
+	storage2.EventStore
+	mu   sync.Mutex
+	rows []storage2.PlogRow
+}
+
+func (s *fakePlogStore) InsertPlog(ctx context.Context, rows []storage2.PlogRow) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rows = append(s.rows, rows...)
+	return nil
+}
+
+func (s *fakePlogStore) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.rows)
+}
+
+// fakeHouseholds flat without watchers
+type fakeHouseholds struct {
+	repository.HouseHoldRepository
+}
+
+func (fakeHouseholds) GetWatchersByFlatID(ctx context.Context, flatID int) ([]models.Watcher, error) {
+	return nil, nil
+}
+
+func newTestBewardHandler(t *testing.T) (*BewardHandler, *fakePlogStore) {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	store := &fakePlogStore{}
+	repo := &repository.PostgresRepository{Households: fakeHouseholds{}}
+
+	h := NewBewardHandler(&Deps{
+		Logger:     logger,
+		Clickhouse: store,
+		Repo:       repo,
+		Writer:     events.NewEventWriter(logger, store, nil, repo, nil, nil, nil),
+	})
+	return h, store
+}
+
+// startTestCall active call with camshot done, as after "CMS handset call started"
+func startTestCall(h *BewardHandler, host string, callID int, now time.Time) {
+	h.activeCalls.add(&CallData{
+		CallID:           callID,
+		Apartment:        12,
+		DomophoneIP:      host,
+		StartTime:        &now,
+		Domophone:        &models.Domophone{HouseDomophoneID: 7},
+		Entrance:         &models.HouseEntrance{HouseEntranceID: 3, AddressHouseID: 5},
+		FlatID:           42,
+		imageUUID:        events.ImageUUIDStub,
+		screenshotsReady: true,
+	}, now)
+}
+
+func TestBewardCallFinalEventOnce(t *testing.T) {
+	const host = "192.168.1.10"
+
+	t.Run("all calls done twice", func(t *testing.T) {
+		h, store := newTestBewardHandler(t)
+		now := time.Now()
+		startTestCall(h, host, 5, now)
+
+		h.HandleCallFlow(&now, host, "[5] All calls are done for apartment 12")
+		h.HandleCallFlow(&now, host, "[5] All calls are done for apartment 12")
+		h.finals.Wait()
+
+		if got := store.count(); got != 1 {
+			t.Errorf("plog rows = %d, want 1", got)
+		}
+	})
+
+	t.Run("all calls done after sweeper", func(t *testing.T) {
+		h, store := newTestBewardHandler(t)
+		now := time.Now()
+		startTestCall(h, host, 6, now)
+
+		h.HandleCallFlow(&now, host, "[6] SIP call done for apartment 12")
+		h.sweep(now.Add(callStateTimeouts[CallEnded] + time.Second))
+
+		late := now.Add(time.Minute)
+		h.HandleCallFlow(&late, host, "[6] All calls are done for apartment 12")
+		h.finals.Wait()
+
+		if got := store.count(); got != 1 {
+			t.Errorf("plog rows = %d, want 1", got)
+		}
+		if _, exists := h.activeCalls.get(host, 6); exists {
+			t.Error("late end message registered the finalized call again")
+		}
+	})
+}
@@ -0,0 +1,316 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/config"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/repository/models"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Hikvision ISAPI AccessControllerEvent codes, see draft/events_hikvision.md
+const (
+	HIKVISION_MAJOR_ALARM = 0x1
+	HIKVISION_MAJOR_EVENT = 0x5
+
+	HIKVISION_MINOR_HOST_TAMPER        = 0x404 // major alarm, panel case opened
+	HIKVISION_MINOR_CARD_READER_TAMPER = 0x406 // major alarm, card reader removed
+
+	HIKVISION_MINOR_LEGAL_CARD_PASS   = 0x01
+	HIKVISION_MINOR_DOOR_BUTTON_PRESS = 0x21
+	HIKVISION_MINOR_PASSWORD_PASS     = 0x70
+	HIKVISION_MINOR_CALL_START        = 0xa0
+	HIKVISION_MINOR_CALL_ANSWERED     = 0xa1
+	HIKVISION_MINOR_CALL_END          = 0xa2
+	HIKVISION_MINOR_CALL_DOOR_OPEN    = 0xa3
+
+	hikvisionEventAccessController = "AccessControllerEvent"
+
+	// hikvisionMaxBody notification with pictures size limit
+	hikvisionMaxBody = 10 << 20
+)
+
+// hikvisionAlert ISAPI EventNotificationAlert, XML or JSON
+type hikvisionAlert struct {
+	XMLName               xml.Name              `xml:"EventNotificationAlert" json:"-"`
+	IPAddress             string                `xml:"ipAddress" json:"ipAddress"`
+	MacAddress            string                `xml:"macAddress" json:"macAddress"`
+	DeviceID              string                `xml:"deviceID" json:"deviceID"` // device serial
+	DateTime              string                `xml:"dateTime" json:"dateTime"`
+	EventType             string                `xml:"eventType" json:"eventType"`
+	EventState            string                `xml:"eventState" json:"eventState"`
+	AccessControllerEvent *hikvisionAccessEvent `xml:"AccessControllerEvent" json:"AccessControllerEvent"`
+}
+
+type hikvisionAccessEvent struct {
+	DeviceName     string `xml:"deviceName" json:"deviceName"`
+	MajorEventType int    `xml:"majorEventType" json:"majorEventType"`
+	SubEventType   int    `xml:"subEventType" json:"subEventType"`
+	CardNo         string `xml:"cardNo" json:"cardNo"`
+	Password       string `xml:"password" json:"password"`
+	DoorNo         int    `xml:"doorNo" json:"doorNo"`         // 1 - main door
+	RoomNumber     string `xml:"roomNumber" json:"roomNumber"` // called apartment
+	SerialNo       int    `xml:"serialNo" json:"serialNo"`     // event serial number
+}
+
+// HikvisionHandler handles ISAPI HTTP notifications of Hikvision panels
+type HikvisionHandler struct {
+	baseHandler
+	secret string
+	calls  *apartmentCalls
+}
+
+func init() {
+	Register(Vendor{
+		Name:  "hikvision",
+		Unit:  "Hikvision",
+		Needs: needEvents,
+		HTTP: func(deps *Deps, panel config.PanelConfig) http.Handler {
+			return NewHikvisionHandler(deps, panel.Secret)
+		},
+	})
+}
+
+// NewHikvisionHandler creates a new HikvisionHandler, notifications are accepted with the panel secret only
+func NewHikvisionHandler(deps *Deps, secret string) *HikvisionHandler {
+	base := newBaseHandler(deps, "hikvision")
+	if secret == "" {
+		base.logger.Warn("Hikvision secret is not set, all notifications are rejected", "unit", base.unit)
+	}
+
+	return &HikvisionHandler{
+		baseHandler: base,
+		secret:      secret,
+		calls:       newApartmentCalls(),
+	}
+}
+
+// ServeHTTP receive EventNotificationAlert, the panel retries notifications not answered with 200
+func (h *HikvisionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodPut {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	srcIP, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		srcIP = r.RemoteAddr
+	}
+
+	if !validSecret(r, h.secret) {
+		h.logger.Warn("Hikvision notification unauthorized", "ip", srcIP)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	alerts, err := parseHikvisionNotification(r.Header.Get("Content-Type"), http.MaxBytesReader(w, r.Body, hikvisionMaxBody))
+	if err != nil {
+		h.logger.Warn("Failed to parse Hikvision notification", "ip", srcIP, "error", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	for _, alert := range alerts {
+		h.HandleAlert(srcIP, alert)
+	}
+}
+
+// HandleAlert process access controller events, other alerts are skipped
+func (h *HikvisionHandler) HandleAlert(srcIP string, alert *hikvisionAlert) {
+	// FIXME: load location from system or config
+	location, _ := time.LoadLocation("Europe/Moscow")
+	now := time.Now().In(location).Truncate(time.Second)
+
+	if alert.EventType != hikvisionEventAccessController || alert.AccessControllerEvent == nil {
+		return
+	}
+	event := alert.AccessControllerEvent
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	host, domophone, err := h.resolveDomophone(ctx, srcIP, alert)
+	if err != nil {
+		h.logger.Warn("Failed to get Hikvision domophone", "ip", srcIP, "deviceID", alert.DeviceID, "error", err)
+		return
+	}
+
+	message := fmt.Sprintf("%s major:0x%x minor:0x%x door:%d card:%s room:%s",
+		alert.EventType, event.MajorEventType, event.SubEventType, event.DoorNo, event.CardNo, event.RoomNumber)
+	h.logger.Debug("HandleAlert || Processing Hikvision event", "ip", srcIP, "ipAddress", alert.IPAddress, "host", host, "message", message)
+	h.storeSyslog(host, message)
+
+	// door 1 - main, door 2 - additional
+	door := DOOR_MAIN
+	if event.DoorNo > 1 {
+		door = event.DoorNo - 1
+	}
+
+	doorEvent := DoorOpenEvent{
+		Timestamp: &now,
+		Host:      host,
+		Domophone: domophone,
+		Door:      door,
+	}
+
+	switch {
+	case event.MajorEventType == HIKVISION_MAJOR_EVENT && event.SubEventType == HIKVISION_MINOR_LEGAL_CARD_PASS:
+		rfidKey := hikvisionRFIDKey(event.CardNo)
+		if rfidKey == "" {
+			h.logger.Warn("RFID key not found", "host", host, "cardNo", event.CardNo)
+			return
+		}
+		h.openByKey(ctx, doorEvent, rfidKey)
+
+	case event.MajorEventType == HIKVISION_MAJOR_EVENT && event.SubEventType == HIKVISION_MINOR_PASSWORD_PASS:
+		// panels without code in the event: the flat is unknown
+		if _, err := strconv.Atoi(event.Password); err != nil {
+			h.logger.Warn("Open code not found", "host", host, "password", event.Password)
+			return
+		}
+		h.openByCode(ctx, doorEvent, event.Password)
+
+	case event.MajorEventType == HIKVISION_MAJOR_EVENT && event.SubEventType == HIKVISION_MINOR_DOOR_BUTTON_PRESS:
+		doorEvent.Event = Event.OpenByButton
+		h.processDoorOpen(ctx, doorEvent)
+
+	case event.MajorEventType == HIKVISION_MAJOR_ALARM &&
+		(event.SubEventType == HIKVISION_MINOR_HOST_TAMPER || event.SubEventType == HIKVISION_MINOR_CARD_READER_TAMPER):
+		h.processSecurityEvent(ctx, SecurityEvent{
+			Timestamp: &now,
+			Host:      host,
+			Domophone: domophone,
+			Door:      door,
+			Type:      SECURITY_TAMPER,
+			Message:   message,
+		})
+
+	case event.MajorEventType == HIKVISION_MAJOR_EVENT && event.SubEventType == HIKVISION_MINOR_CALL_START:
+		if apartment, ok := h.apartment(host, event); ok {
+			h.startApartmentCall(h.calls, &now, host, domophone, apartment, CALL_TYPE_SIP)
+		}
+
+	case event.MajorEventType == HIKVISION_MAJOR_EVENT && event.SubEventType == HIKVISION_MINOR_CALL_ANSWERED:
+		if apartment, ok := h.apartment(host, event); ok {
+			h.updateApartmentCall(h.calls, host, apartment, func(callData *CallData) { callData.answer(&now) })
+		}
+
+	case event.MajorEventType == HIKVISION_MAJOR_EVENT && event.SubEventType == HIKVISION_MINOR_CALL_DOOR_OPEN:
+		if apartment, ok := h.apartment(host, event); ok {
+			h.updateApartmentCall(h.calls, host, apartment, func(callData *CallData) { callData.openDoor(&now) })
+		}
+
+	case event.MajorEventType == HIKVISION_MAJOR_EVENT && event.SubEventType == HIKVISION_MINOR_CALL_END:
+		if apartment, ok := h.apartment(host, event); ok {
+			h.endApartmentCall(h.calls, &now, host, apartment)
+		}
+	}
+}
+
+// resolveDomophone by the source address, then by device serial in sub_id behind NAT,
+// ipAddress of the alert is never trusted
+func (h *HikvisionHandler) resolveDomophone(ctx context.Context, srcIP string, alert *hikvisionAlert) (string, *models.Domophone, error) {
+	domophone, err := h.repo.Households.GetDomophone(ctx, "ip", srcIP)
+	if err == nil {
+		return srcIP, domophone, nil
+	}
+	if alert.DeviceID == "" {
+		return srcIP, nil, err
+	}
+
+	domophone, err = h.repo.Households.GetDomophone(ctx, "sub_id", alert.DeviceID)
+	if err != nil {
+		return srcIP, nil, err
+	}
+	if domophone.IP != nil && *domophone.IP != "" {
+		return *domophone.IP, domophone, nil
+	}
+	return srcIP, domophone, nil
+}
+
+func (h *HikvisionHandler) apartment(host string, event *hikvisionAccessEvent) (int, bool) {
+	apartment, err := strconv.Atoi(strings.TrimSpace(event.RoomNumber))
+	if err != nil {
+		h.logger.Warn("Failed to extract apartment from Hikvision call event", "host", host, "roomNumber", event.RoomNumber)
+		return 0, false
+	}
+	return apartment, true
+}
+
+// parseHikvisionNotification alerts from XML, JSON or multipart body, pictures are skipped
+func parseHikvisionNotification(contentType string, body io.Reader) ([]*hikvisionAlert, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		// panels without content type send XML
+		mediaType = "application/xml"
+	}
+
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		alert, err := decodeHikvisionAlert(mediaType, body)
+		if err != nil {
+			return nil, err
+		}
+		return []*hikvisionAlert{alert}, nil
+	}
+
+	var alerts []*hikvisionAlert
+	reader := multipart.NewReader(body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return alerts, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read multipart notification: %w", err)
+		}
+
+		partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		if strings.HasPrefix(partType, "image/") {
+			continue
+		}
+
+		alert, err := decodeHikvisionAlert(partType, part)
+		if err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, alert)
+	}
+}
+
+func decodeHikvisionAlert(mediaType string, body io.Reader) (*hikvisionAlert, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read notification: %w", err)
+	}
+
+	var alert hikvisionAlert
+	if strings.Contains(mediaType, "json") || (!strings.Contains(mediaType, "xml") && strings.HasPrefix(strings.TrimSpace(string(data)), "{")) {
+		err = json.Unmarshal(data, &alert)
+	} else {
+		err = xml.Unmarshal(data, &alert)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode EventNotificationAlert: %w", err)
+	}
+	return &alert, nil
+}
+
+// hikvisionRFIDKey card number is decimal, keys are stored as hex
+func hikvisionRFIDKey(cardNo string) string {
+	cardNo = strings.TrimSpace(cardNo)
+	if number, err := strconv.ParseUint(cardNo, 10, 64); err == nil {
+		return normalizeRFIDKey(strconv.FormatUint(number, 16))
+	}
+	return normalizeRFIDKey(cardNo)
+}
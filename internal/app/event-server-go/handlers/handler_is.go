@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"context"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/config"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/syslog_custom"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// IS (Intersvyaz) message fragments, see draft/events_is.md
+const (
+	IS_OPEN_BY_RFID        = "ACCESS GRANTED"
+	IS_OPEN_BY_CODE        = "Opening door by code"
+	IS_MAIN_BUTTON         = "Main door button pressed"
+	IS_ADDITIONAL_BUTTON   = "Additional door button pressed"
+	IS_CALL_SIP            = "Calling to"
+	IS_CALL_CMS            = "CMS handset call started"
+	IS_TALK_SIP            = "SIP talk started"
+	IS_TALK_CMS            = "CMS handset talk started"
+	IS_OPEN_BY_DTMF        = "Open main door by DTMF"
+	IS_OPEN_BY_CMS_HANDSET = "Open main door by CMS handset"
+	IS_CALL_END            = "All calls are done"
+	IS_MOTION_START        = "Motion detected"
+	IS_MOTION_STOP         = "Motion stopped"
+)
+
+var (
+	// "RFID 00000075BC01AD ACCESS GRANTED", "External RFID 00000075BC01AD ACCESS GRANTED"
+	isRFIDRegex = regexp.MustCompile(`(External )?RFID ([0-9A-Fa-f]+) ACCESS GRANTED`)
+
+	// "Opening door by code 12345, apartment 12"
+	isCodeRegex = regexp.MustCompile(`code (\d+)`)
+
+	// "Calling to 12 flat", "... for apartment 12"
+	isApartmentRegex = regexp.MustCompile(`(?:Calling to|apartment) (\d+)`)
+)
+
+// ISHandler handles messages specific to IS (Intersvyaz) panels
+type ISHandler struct {
+	baseHandler
+	calls *apartmentCalls
+}
+
+func init() {
+	Register(Vendor{
+		Name:  "is",
+		Unit:  "IS",
+		Needs: needEvents | NeedFrs,
+		Syslog: func(deps *Deps, _ config.PanelConfig) syslog_custom.MessageHandler {
+			return NewISHandler(deps)
+		},
+	})
+}
+
+// NewISHandler creates a new ISHandler
+func NewISHandler(deps *Deps) *ISHandler {
+	return &ISHandler{
+		baseHandler: newBaseHandler(deps, "is"),
+		calls:       newApartmentCalls(),
+	}
+}
+
+// HandleMessage processes IS-specific messages
+func (h *ISHandler) HandleMessage(srcIP string, message *syslog_custom.SyslogMessage) {
+	// FIXME: load location from system or config
+	location, _ := time.LoadLocation("Europe/Moscow")
+	now := time.Now().In(location).Truncate(time.Second)
+
+	// filter
+	if h.FilterMessage(message.Message) {
+		return
+	}
+
+	h.logger.Debug("HandleMessage || Processing IS message", "ip", srcIP, "host", message.HostName, "message", message.Message)
+
+	host := h.resolveHost(srcIP, message)
+	h.storeSyslog(host, message.Message)
+
+	msg := message.Message
+	switch {
+	case strings.Contains(msg, IS_MOTION_START):
+		h.HandleMotionDetection(&now, host, true)
+	case strings.Contains(msg, IS_MOTION_STOP):
+		h.HandleMotionDetection(&now, host, false)
+	case strings.Contains(msg, IS_OPEN_BY_RFID):
+		h.HandleOpenByRFID(&now, host, msg)
+	case strings.Contains(msg, IS_OPEN_BY_CODE):
+		h.HandleOpenByCode(&now, host, msg)
+	case strings.Contains(msg, IS_MAIN_BUTTON):
+		h.HandleOpenByButton(&now, host, DOOR_MAIN)
+	case strings.Contains(msg, IS_ADDITIONAL_BUTTON):
+		h.HandleOpenByButton(&now, host, DOOR_SECONDARY)
+	case strings.Contains(msg, IS_CALL_CMS):
+		if apartment, ok := h.apartment(host, msg); ok {
+			h.startApartmentCall(h.calls, &now, host, nil, apartment, CALL_TYPE_CMS)
+		}
+	case strings.Contains(msg, IS_CALL_SIP):
+		if apartment, ok := h.apartment(host, msg); ok {
+			h.startApartmentCall(h.calls, &now, host, nil, apartment, CALL_TYPE_SIP)
+		}
+	case strings.Contains(msg, IS_TALK_SIP), strings.Contains(msg, IS_TALK_CMS):
+		if apartment, ok := h.apartment(host, msg); ok {
+			h.updateApartmentCall(h.calls, host, apartment, func(callData *CallData) { callData.answer(&now) })
+		}
+	case strings.Contains(msg, IS_OPEN_BY_DTMF), strings.Contains(msg, IS_OPEN_BY_CMS_HANDSET):
+		if apartment, ok := h.apartment(host, msg); ok {
+			h.updateApartmentCall(h.calls, host, apartment, func(callData *CallData) { callData.openDoor(&now) })
+		}
+	case strings.Contains(msg, IS_CALL_END):
+		if apartment, ok := h.apartment(host, msg); ok {
+			h.endApartmentCall(h.calls, &now, host, apartment)
+		}
+	}
+}
+
+// HandleOpenByRFID main or external reader
+func (h *ISHandler) HandleOpenByRFID(timestamp *time.Time, host, msg string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	matches := isRFIDRegex.FindStringSubmatch(msg)
+	if matches == nil {
+		h.logger.Warn("RFID key not found", "host", host, "message", msg)
+		return
+	}
+
+	door := DOOR_MAIN
+	if matches[1] != "" {
+		door = DOOR_SECONDARY
+	}
+
+	h.openByKey(ctx, DoorOpenEvent{Timestamp: timestamp, Host: host, Door: door}, matches[2])
+}
+
+// HandleOpenByCode flat open code
+func (h *ISHandler) HandleOpenByCode(timestamp *time.Time, host, msg string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	matches := isCodeRegex.FindStringSubmatch(msg)
+	if matches == nil {
+		h.logger.Warn("Open code not found", "host", host, "message", msg)
+		return
+	}
+
+	h.openByCode(ctx, DoorOpenEvent{Timestamp: timestamp, Host: host, Door: DOOR_MAIN}, matches[1])
+}
+
+// HandleOpenByButton exit button
+func (h *ISHandler) HandleOpenByButton(timestamp *time.Time, host string, door int) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	h.processDoorOpen(ctx, DoorOpenEvent{
+		Timestamp: timestamp,
+		Host:      host,
+		Door:      door,
+		Event:     Event.OpenByButton,
+	})
+}
+
+func (h *ISHandler) apartment(host, msg string) (int, bool) {
+	matches := isApartmentRegex.FindStringSubmatch(msg)
+	if matches == nil {
+		h.logger.Warn("Failed to extract apartment from IS message", "host", host, "message", msg)
+		return 0, false
+	}
+
+	apartment, err := strconv.Atoi(matches[1])
+	return apartment, err == nil
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/config"
+	"net/http"
+	"time"
+)
+
+// Omny webhook event types, door open methods and call statuses, see draft/events_omny.md
+const (
+	OMNY_TYPE_DOOR_OPEN = "door_open"
+	OMNY_TYPE_CALL      = "call"
+
+	OMNY_METHOD_RFID = "rfid"
+	OMNY_METHOD_CODE = "code"
+	OMNY_METHOD_APP  = "app"
+
+	OMNY_STATUS_ANSWERED = "answered"
+	OMNY_STATUS_MISSED   = "missed"
+)
+
+type omnyPayload struct {
+	Serial    string `json:"serial"`
+	Type      string `json:"type"`
+	Method    string `json:"method"`
+	Status    string `json:"status"`
+	Timestamp int64  `json:"timestamp"`
+	Apartment int    `json:"apartment"`
+	Key       string `json:"key"`
+	Code      string `json:"code"`
+	Door      int    `json:"door"`
+}
+
+// OmnyHandler handles Omny cloud webhooks
+type OmnyHandler struct {
+	webhookHandler
+}
+
+func init() {
+	Register(Vendor{
+		Name:  "omny",
+		Unit:  "Omny",
+		Needs: needEvents,
+		HTTP: func(deps *Deps, panel config.PanelConfig) http.Handler {
+			return NewOmnyHandler(deps, panel.Secret)
+		},
+	})
+}
+
+// NewOmnyHandler creates a new OmnyHandler
+func NewOmnyHandler(deps *Deps, secret string) *OmnyHandler {
+	base := newBaseHandler(deps, "omny")
+	return &OmnyHandler{
+		webhookHandler: newWebhookHandler(base, secret, decodeOmny),
+	}
+}
+
+// decodeOmny one event per request
+func decodeOmny(body []byte) ([]WebhookEvent, error) {
+	var payload omnyPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("failed to parse Omny event: %w", err)
+	}
+
+	event := WebhookEvent{
+		DeviceID:  payload.Serial,
+		Type:      payload.Type,
+		RFID:      payload.Key,
+		Code:      payload.Code,
+		Apartment: payload.Apartment,
+		Door:      payload.Door,
+	}
+
+	if payload.Timestamp > 0 {
+		t := time.Unix(payload.Timestamp, 0)
+		event.Time = &t
+	}
+
+	switch {
+	case payload.Type == OMNY_TYPE_DOOR_OPEN && payload.Method == OMNY_METHOD_RFID:
+		event.Type = WEBHOOK_OPEN_BY_KEY
+	case payload.Type == OMNY_TYPE_DOOR_OPEN && payload.Method == OMNY_METHOD_CODE:
+		event.Type = WEBHOOK_OPEN_BY_CODE
+	case payload.Type == OMNY_TYPE_DOOR_OPEN && payload.Method == OMNY_METHOD_APP:
+		event.Type = WEBHOOK_OPEN_BY_APP
+	case payload.Type == OMNY_TYPE_CALL && payload.Status == OMNY_STATUS_ANSWERED:
+		event.Type = WEBHOOK_CALL_ANSWERED
+	case payload.Type == OMNY_TYPE_CALL && payload.Status == OMNY_STATUS_MISSED:
+		event.Type = WEBHOOK_CALL_MISSED
+	}
+
+	return []WebhookEvent{event}, nil
+}
@@ -1,52 +1,222 @@
 package handlers
 
 import (
-	"github.com/kulakoff/event-server-go/internal/app/event-server-go/repository"
-	storage2 "github.com/kulakoff/event-server-go/internal/app/event-server-go/storage"
+	"context"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/config"
 	"github.com/kulakoff/event-server-go/internal/app/event-server-go/syslog_custom"
-	"log/slog"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 )
 
-// QtechHandler handles messages specific to Beward panels
+// Qtech event codes, see draft/events_qtech.md
+const (
+	QTECH_EVENT_OPEN_BY_RFID    = 101
+	QTECH_EVENT_OPEN_BY_CODE    = 102
+	QTECH_EVENT_OPEN_BY_BUTTON  = 103
+	QTECH_EVENT_OPEN_BY_DTMF    = 400
+	QTECH_EVENT_OPEN_BY_HANDSET = 401
+	QTECH_EVENT_CALL_START      = 500
+	QTECH_EVENT_CALL_ANSWERED   = 501
+	QTECH_EVENT_CALL_END        = 502
+	QTECH_EVENT_MOTION_START    = 700
+	QTECH_EVENT_MOTION_STOP     = 701
+)
+
+// EVENT:<code>:<text>, <field>:<value>, ...
+var qtechEventRegex = regexp.MustCompile(`EVENT:(\d{3}):(.*)$`)
+
+// qtechEvent parsed Qtech syslog event
+type qtechEvent struct {
+	Code   int
+	Text   string
+	Fields map[string]string // lower case field names
+}
+
+// QtechHandler handles messages specific to Qtech panels
 type QtechHandler struct {
-	logger    *slog.Logger
-	spamWords []string
-	storage   *storage2.ClickhouseHttpClient
-	fsFiles   *storage2.MongoHandler
-	repo      *repository.PostgresRepository
+	baseHandler
+	calls *apartmentCalls
+}
+
+func init() {
+	Register(Vendor{
+		Name:  "qtech",
+		Unit:  "Qtech",
+		Needs: needEvents | NeedFrs,
+		Syslog: func(deps *Deps, _ config.PanelConfig) syslog_custom.MessageHandler {
+			return NewQtechHandler(deps)
+		},
+	})
 }
 
 // NewQtechHandler creates a new QtechHandler
-func NewQtechHandler(logger *slog.Logger, filters []string, storage *storage2.ClickhouseHttpClient, mongo *storage2.MongoHandler, repo *repository.PostgresRepository) *QtechHandler {
+func NewQtechHandler(deps *Deps) *QtechHandler {
 	return &QtechHandler{
-		logger:    logger,
-		spamWords: filters,
-		storage:   storage,
-		fsFiles:   mongo,
-		repo:      repo,
+		baseHandler: newBaseHandler(deps, "qtech"),
+		calls:       newApartmentCalls(),
 	}
 }
 
-// HandleMessage processes Beward-specific messages
+// HandleMessage processes Qtech-specific messages
 func (h *QtechHandler) HandleMessage(srcIP string, message *syslog_custom.SyslogMessage) {
+	// FIXME: load location from system or config
+	location, _ := time.LoadLocation("Europe/Moscow")
+	now := time.Now().In(location).Truncate(time.Second)
+
 	// filter
 	if h.FilterMessage(message.Message) {
-		// FIXME: remove DEBUG
-		h.logger.Debug("Skipping message", "srcIP", srcIP, "host", message.HostName, "message", message.Message)
 		return
 	}
 
-	h.logger.Info("Processing Qtech message", "srcIP", srcIP, "message", message.Message)
-	// Implement Qtech-specific message processing here
+	h.logger.Debug("HandleMessage || Processing Qtech message", "ip", srcIP, "host", message.HostName, "message", message.Message)
+
+	host := h.resolveHost(srcIP, message)
+	h.storeSyslog(host, message.Message)
+
+	event, ok := parseQtechEvent(message.Message)
+	if !ok {
+		return
+	}
+
+	switch event.Code {
+	case QTECH_EVENT_MOTION_START:
+		h.HandleMotionDetection(&now, host, true)
+	case QTECH_EVENT_MOTION_STOP:
+		h.HandleMotionDetection(&now, host, false)
+	case QTECH_EVENT_OPEN_BY_RFID:
+		h.HandleOpenByRFID(&now, host, event)
+	case QTECH_EVENT_OPEN_BY_CODE:
+		h.HandleOpenByCode(&now, host, event)
+	case QTECH_EVENT_OPEN_BY_BUTTON:
+		h.HandleOpenByButton(&now, host, event)
+	case QTECH_EVENT_CALL_START:
+		h.HandleCallStart(&now, host, event)
+	case QTECH_EVENT_CALL_ANSWERED:
+		h.HandleCallUpdate(host, event, func(callData *CallData) { callData.answer(&now) })
+	case QTECH_EVENT_OPEN_BY_DTMF, QTECH_EVENT_OPEN_BY_HANDSET:
+		h.HandleCallUpdate(host, event, func(callData *CallData) { callData.openDoor(&now) })
+	case QTECH_EVENT_CALL_END:
+		h.HandleCallEnd(&now, host, event)
+	default:
+		h.logger.Debug("Qtech event not processed", "host", host, "code", event.Code, "text", event.Text)
+	}
+}
+
+// HandleOpenByRFID main or external reader
+func (h *QtechHandler) HandleOpenByRFID(timestamp *time.Time, host string, event *qtechEvent) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	door := DOOR_MAIN
+	if d, err := strconv.Atoi(event.Fields["door"]); err == nil {
+		door = d
+	} else if strings.Contains(strings.ToLower(event.Text), "external") {
+		door = DOOR_SECONDARY
+	}
+
+	h.logger.Debug("Open by RFID", "host", host, "door", door, "rfid", event.Fields["rfid key"])
+
+	h.openByKey(ctx, DoorOpenEvent{Timestamp: timestamp, Host: host, Door: door}, event.Fields["rfid key"])
+}
+
+// HandleOpenByCode personal flat code
+func (h *QtechHandler) HandleOpenByCode(timestamp *time.Time, host string, event *qtechEvent) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	code := event.Fields["code"]
+	if _, err := strconv.Atoi(code); err != nil {
+		h.logger.Warn("Invalid code in Qtech message", "host", host, "text", event.Text)
+		return
+	}
+
+	h.logger.Debug("Open by code", "host", host, "code", code)
+
+	h.openByCode(ctx, DoorOpenEvent{Timestamp: timestamp, Host: host, Door: DOOR_MAIN}, code)
 }
 
-// FilterMessage skip not informational message
-func (h *QtechHandler) FilterMessage(message string) bool {
-	for _, word := range h.spamWords {
-		if strings.Contains(message, word) {
-			return true
+// HandleOpenByButton exit button, entrance event without flat
+func (h *QtechHandler) HandleOpenByButton(timestamp *time.Time, host string, event *qtechEvent) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	door := DOOR_MAIN
+	if d, err := strconv.Atoi(event.Fields["door"]); err == nil {
+		door = d
+	}
+
+	h.logger.Debug("Open by button", "host", host, "door", door)
+
+	h.processDoorOpen(ctx, DoorOpenEvent{
+		Timestamp: timestamp,
+		Host:      host,
+		Door:      door,
+		Event:     Event.OpenByButton,
+	})
+}
+
+// HandleCallStart SIP or analog call to apartment
+func (h *QtechHandler) HandleCallStart(timestamp *time.Time, host string, event *qtechEvent) {
+	apartment, err := strconv.Atoi(event.Fields["apartment"])
+	if err != nil {
+		h.logger.Warn("Failed to extract apartment from call start", "host", host, "text", event.Text)
+		return
+	}
+
+	callType := CALL_TYPE_SIP
+	if strings.EqualFold(event.Fields["type"], "analog") {
+		callType = CALL_TYPE_CMS
+	}
+
+	h.startApartmentCall(h.calls, timestamp, host, nil, apartment, callType)
+}
+
+// HandleCallUpdate call answered or door opened by DTMF or handset
+func (h *QtechHandler) HandleCallUpdate(host string, event *qtechEvent, update func(callData *CallData)) {
+	apartment, err := strconv.Atoi(event.Fields["apartment"])
+	if err != nil {
+		h.logger.Warn("Failed to extract apartment from call event", "host", host, "text", event.Text)
+		return
+	}
+
+	h.updateApartmentCall(h.calls, host, apartment, update)
+}
+
+// HandleCallEnd make final call event
+func (h *QtechHandler) HandleCallEnd(timestamp *time.Time, host string, event *qtechEvent) {
+	apartment, err := strconv.Atoi(event.Fields["apartment"])
+	if err != nil {
+		h.logger.Warn("Failed to extract apartment from call end", "host", host, "text", event.Text)
+		return
+	}
+
+	h.endApartmentCall(h.calls, timestamp, host, apartment)
+}
+
+// parseQtechEvent "EVENT:101:Open Door By Card, RFID Key:0600C9F5C9, door:0"
+func parseQtechEvent(message string) (*qtechEvent, bool) {
+	matches := qtechEventRegex.FindStringSubmatch(message)
+	if matches == nil {
+		return nil, false
+	}
+
+	code, _ := strconv.Atoi(matches[1])
+	event := &qtechEvent{
+		Code:   code,
+		Fields: make(map[string]string),
+	}
+
+	parts := strings.Split(matches[2], ",")
+	event.Text = strings.TrimSpace(parts[0])
+	for _, part := range parts[1:] {
+		name, value, ok := strings.Cut(part, ":")
+		if !ok {
+			continue
 		}
+		event.Fields[strings.ToLower(strings.TrimSpace(name))] = strings.TrimSpace(value)
 	}
-	return false
+
+	return event, true
 }
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"context"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/config"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/syslog_custom"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rubetek message prefixes, see draft/events_rubetek.md
+const (
+	RUBETEK_OPEN_BY_RFID   = "Open door by RFID"
+	RUBETEK_OPEN_BY_CODE   = "Open door by code"
+	RUBETEK_OPEN_BY_BUTTON = "Open door by button"
+	RUBETEK_OPEN_BY_DTMF   = "Open door by DTMF"
+	RUBETEK_CALL_START     = "Call start"
+	RUBETEK_CALL_ANSWERED  = "Call answered"
+	RUBETEK_CALL_END       = "Call end"
+	RUBETEK_MOTION_START   = "Motion detected"
+	RUBETEK_MOTION_STOP    = "Motion ended"
+)
+
+var (
+	// "Open door by RFID 0600C9F5C9, door 0", "Open door by code 12345, door 0"
+	rubetekOpenRegex = regexp.MustCompile(`^Open door by (?:RFID|code) (\w+)`)
+	rubetekDoorRegex = regexp.MustCompile(`door (\d+)`)
+
+	// "Call start, apartment 12"
+	rubetekApartmentRegex = regexp.MustCompile(`apartment (\d+)`)
+)
+
+// RubetekHandler handles messages specific to Rubetek panels
+type RubetekHandler struct {
+	baseHandler
+	calls *apartmentCalls
+}
+
+func init() {
+	Register(Vendor{
+		Name:  "rubetek",
+		Unit:  "Rubetek",
+		Needs: needEvents | NeedFrs,
+		Syslog: func(deps *Deps, _ config.PanelConfig) syslog_custom.MessageHandler {
+			return NewRubetekHandler(deps)
+		},
+	})
+}
+
+// NewRubetekHandler creates a new RubetekHandler
+func NewRubetekHandler(deps *Deps) *RubetekHandler {
+	return &RubetekHandler{
+		baseHandler: newBaseHandler(deps, "rubetek"),
+		calls:       newApartmentCalls(),
+	}
+}
+
+// HandleMessage processes Rubetek-specific messages
+func (h *RubetekHandler) HandleMessage(srcIP string, message *syslog_custom.SyslogMessage) {
+	// FIXME: load location from system or config
+	location, _ := time.LoadLocation("Europe/Moscow")
+	now := time.Now().In(location).Truncate(time.Second)
+
+	// filter
+	if h.FilterMessage(message.Message) {
+		return
+	}
+
+	h.logger.Debug("HandleMessage || Processing Rubetek message", "ip", srcIP, "host", message.HostName, "message", message.Message)
+
+	host := h.resolveHost(srcIP, message)
+	h.storeSyslog(host, message.Message)
+
+	msg := strings.TrimSpace(message.Message)
+	switch {
+	case strings.HasPrefix(msg, RUBETEK_MOTION_START):
+		h.HandleMotionDetection(&now, host, true)
+	case strings.HasPrefix(msg, RUBETEK_MOTION_STOP):
+		h.HandleMotionDetection(&now, host, false)
+	case strings.HasPrefix(msg, RUBETEK_OPEN_BY_RFID),
+		strings.HasPrefix(msg, RUBETEK_OPEN_BY_CODE),
+		strings.HasPrefix(msg, RUBETEK_OPEN_BY_BUTTON):
+		h.HandleOpenDoor(&now, host, msg)
+	case strings.HasPrefix(msg, RUBETEK_CALL_START):
+		if apartment, ok := h.apartment(host, msg); ok {
+			h.startApartmentCall(h.calls, &now, host, nil, apartment, CALL_TYPE_SIP)
+		}
+	case strings.HasPrefix(msg, RUBETEK_CALL_ANSWERED):
+		if apartment, ok := h.apartment(host, msg); ok {
+			h.updateApartmentCall(h.calls, host, apartment, func(callData *CallData) { callData.answer(&now) })
+		}
+	case strings.HasPrefix(msg, RUBETEK_OPEN_BY_DTMF):
+		if apartment, ok := h.apartment(host, msg); ok {
+			h.updateApartmentCall(h.calls, host, apartment, func(callData *CallData) { callData.openDoor(&now) })
+		}
+	case strings.HasPrefix(msg, RUBETEK_CALL_END):
+		if apartment, ok := h.apartment(host, msg); ok {
+			h.endApartmentCall(h.calls, &now, host, apartment)
+		}
+	}
+}
+
+// HandleOpenDoor RFID key, code or exit button
+func (h *RubetekHandler) HandleOpenDoor(timestamp *time.Time, host, msg string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	event := DoorOpenEvent{
+		Timestamp: timestamp,
+		Host:      host,
+		Door:      DOOR_MAIN,
+	}
+	if matches := rubetekDoorRegex.FindStringSubmatch(msg); matches != nil {
+		event.Door, _ = strconv.Atoi(matches[1])
+	}
+
+	if strings.HasPrefix(msg, RUBETEK_OPEN_BY_BUTTON) {
+		event.Event = Event.OpenByButton
+		h.processDoorOpen(ctx, event)
+		return
+	}
+
+	matches := rubetekOpenRegex.FindStringSubmatch(msg)
+	if matches == nil {
+		h.logger.Warn("Failed to parse Rubetek door open message", "host", host, "message", msg)
+		return
+	}
+
+	if strings.HasPrefix(msg, RUBETEK_OPEN_BY_RFID) {
+		h.openByKey(ctx, event, matches[1])
+	} else {
+		h.openByCode(ctx, event, matches[1])
+	}
+}
+
+func (h *RubetekHandler) apartment(host, msg string) (int, bool) {
+	matches := rubetekApartmentRegex.FindStringSubmatch(msg)
+	if matches == nil {
+		h.logger.Warn("Failed to extract apartment from Rubetek message", "host", host, "message", msg)
+		return 0, false
+	}
+
+	apartment, err := strconv.Atoi(matches[1])
+	return apartment, err == nil
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/config"
+	"net/http"
+	"time"
+)
+
+// Sputnik Cloud webhook events and door open methods, see draft/events_sputnik.md
+const (
+	SPUTNIK_EVENT_DOOR_OPENED   = "intercom.door_opened"
+	SPUTNIK_EVENT_CALL_FINISHED = "intercom.call_finished"
+
+	SPUTNIK_METHOD_KEY  = "key"
+	SPUTNIK_METHOD_CODE = "code"
+	SPUTNIK_METHOD_APP  = "app"
+)
+
+type sputnikPayload struct {
+	DeviceID string `json:"device_id"`
+	Event    string `json:"event"`
+	DateTime string `json:"datetime"`
+	Data     struct {
+		Method   string `json:"method"`
+		Key      string `json:"key"`
+		Code     string `json:"code"`
+		Flat     int    `json:"flat"`
+		Door     int    `json:"door"`
+		Answered bool   `json:"answered"`
+	} `json:"data"`
+}
+
+// SputnikHandler handles Sputnik Cloud webhooks
+type SputnikHandler struct {
+	webhookHandler
+}
+
+func init() {
+	Register(Vendor{
+		Name:  "sputnik_cloud",
+		Unit:  "Sputnik",
+		Needs: needEvents,
+		HTTP: func(deps *Deps, panel config.PanelConfig) http.Handler {
+			return NewSputnikHandler(deps, panel.Secret)
+		},
+	})
+}
+
+// NewSputnikHandler creates a new SputnikHandler
+func NewSputnikHandler(deps *Deps, secret string) *SputnikHandler {
+	base := newBaseHandler(deps, "sputnik")
+	return &SputnikHandler{
+		webhookHandler: newWebhookHandler(base, secret, decodeSputnik),
+	}
+}
+
+// decodeSputnik single event object or array of events
+func decodeSputnik(body []byte) ([]WebhookEvent, error) {
+	var payloads []sputnikPayload
+	if body = bytes.TrimSpace(body); len(body) > 0 && body[0] == '[' {
+		if err := json.Unmarshal(body, &payloads); err != nil {
+			return nil, fmt.Errorf("failed to parse Sputnik events: %w", err)
+		}
+	} else {
+		var payload sputnikPayload
+		if err := json.Unmarshal(body, &payload); err != nil {
+			return nil, fmt.Errorf("failed to parse Sputnik event: %w", err)
+		}
+		payloads = append(payloads, payload)
+	}
+
+	events := make([]WebhookEvent, 0, len(payloads))
+	for _, payload := range payloads {
+		event := WebhookEvent{
+			DeviceID:  payload.DeviceID,
+			RFID:      payload.Data.Key,
+			Code:      payload.Data.Code,
+			Apartment: payload.Data.Flat,
+			Door:      payload.Data.Door,
+		}
+
+		if t, err := time.Parse(time.RFC3339, payload.DateTime); err == nil {
+			t = t.Truncate(time.Second)
+			event.Time = &t
+		}
+
+		switch payload.Event {
+		case SPUTNIK_EVENT_DOOR_OPENED:
+			switch payload.Data.Method {
+			case SPUTNIK_METHOD_KEY:
+				event.Type = WEBHOOK_OPEN_BY_KEY
+			case SPUTNIK_METHOD_CODE:
+				event.Type = WEBHOOK_OPEN_BY_CODE
+			case SPUTNIK_METHOD_APP:
+				event.Type = WEBHOOK_OPEN_BY_APP
+			}
+		case SPUTNIK_EVENT_CALL_FINISHED:
+			event.Type = WEBHOOK_CALL_MISSED
+			if payload.Data.Answered {
+				event.Type = WEBHOOK_CALL_ANSWERED
+			}
+		}
+
+		if event.Type == "" {
+			event.Type = payload.Event
+		}
+		events = append(events, event)
+	}
+
+	return events, nil
+}
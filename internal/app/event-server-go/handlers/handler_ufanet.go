@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"context"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/config"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/syslog_custom"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Ufanet message tags (syslog APP-NAME) and actions, see draft/events_ufanet.md
+const (
+	UFANET_TAG_DOOR   = "door"
+	UFANET_TAG_CALL   = "call"
+	UFANET_TAG_MOTION = "motion"
+
+	UFANET_DOOR_KEY    = "key"
+	UFANET_DOOR_CODE   = "code"
+	UFANET_DOOR_BUTTON = "button"
+
+	UFANET_CALL_START  = "start"
+	UFANET_CALL_ANSWER = "answer"
+	UFANET_CALL_DTMF   = "dtmf"
+	UFANET_CALL_END    = "end"
+
+	UFANET_MOTION_START = "start"
+	UFANET_MOTION_STOP  = "stop"
+)
+
+// key=value fields
+var ufanetFieldRegex = regexp.MustCompile(`(\w+)=(\S+)`)
+
+// UfanetHandler handles messages specific to Ufanet panels
+type UfanetHandler struct {
+	baseHandler
+	calls *apartmentCalls
+}
+
+func init() {
+	// unit name selects Ufanet message format, see syslog_custom.ParseMessage
+	Register(Vendor{
+		Name:  "ufanet",
+		Unit:  "Ufanet",
+		Needs: needEvents | NeedFrs,
+		Syslog: func(deps *Deps, _ config.PanelConfig) syslog_custom.MessageHandler {
+			return NewUfanetHandler(deps)
+		},
+	})
+}
+
+// NewUfanetHandler creates a new UfanetHandler
+func NewUfanetHandler(deps *Deps) *UfanetHandler {
+	return &UfanetHandler{
+		baseHandler: newBaseHandler(deps, "ufanet"),
+		calls:       newApartmentCalls(),
+	}
+}
+
+// HandleMessage processes Ufanet-specific messages
+func (h *UfanetHandler) HandleMessage(srcIP string, message *syslog_custom.SyslogMessage) {
+	// FIXME: load location from system or config
+	location, _ := time.LoadLocation("Europe/Moscow")
+	now := time.Now().In(location).Truncate(time.Second)
+
+	// filter
+	if h.FilterMessage(message.Message) {
+		return
+	}
+
+	h.logger.Debug("HandleMessage || Processing Ufanet message", "ip", srcIP, "host", message.HostName, "message", message.Message)
+
+	host := h.resolveHost(srcIP, message)
+	h.storeSyslog(host, message.AppName+": "+message.Message)
+
+	// "<action> key=value ..."
+	action, rest, _ := strings.Cut(strings.TrimSpace(message.Message), " ")
+	fields := make(map[string]string)
+	for _, match := range ufanetFieldRegex.FindAllStringSubmatch(rest, -1) {
+		fields[match[1]] = match[2]
+	}
+
+	switch strings.ToLower(message.AppName) {
+	case UFANET_TAG_MOTION:
+		h.HandleMotionDetection(&now, host, action == UFANET_MOTION_START)
+	case UFANET_TAG_DOOR:
+		h.HandleOpenDoor(&now, host, action, fields)
+	case UFANET_TAG_CALL:
+		h.HandleCall(&now, host, action, fields)
+	}
+}
+
+// HandleOpenDoor RFID key, code or exit button
+func (h *UfanetHandler) HandleOpenDoor(timestamp *time.Time, host, action string, fields map[string]string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// relay 1 - main door, relay 2 - additional door
+	event := DoorOpenEvent{
+		Timestamp: timestamp,
+		Host:      host,
+		Door:      DOOR_MAIN,
+	}
+	if relay, err := strconv.Atoi(fields["relay"]); err == nil && relay > 1 {
+		event.Door = relay - 1
+	}
+
+	switch action {
+	case UFANET_DOOR_KEY:
+		h.openByKey(ctx, event, fields["key"])
+	case UFANET_DOOR_CODE:
+		h.openByCode(ctx, event, fields["code"])
+	case UFANET_DOOR_BUTTON:
+		event.Event = Event.OpenByButton
+		h.processDoorOpen(ctx, event)
+	default:
+		h.logger.Debug("Ufanet door action not processed", "host", host, "action", action)
+	}
+}
+
+// HandleCall call flow by flat number
+func (h *UfanetHandler) HandleCall(timestamp *time.Time, host, action string, fields map[string]string) {
+	apartment, err := strconv.Atoi(fields["flat"])
+	if err != nil {
+		h.logger.Warn("Failed to extract apartment from Ufanet call", "host", host, "action", action, "fields", fields)
+		return
+	}
+
+	switch action {
+	case UFANET_CALL_START:
+		h.startApartmentCall(h.calls, timestamp, host, nil, apartment, CALL_TYPE_SIP)
+	case UFANET_CALL_ANSWER:
+		h.updateApartmentCall(h.calls, host, apartment, func(callData *CallData) { callData.answer(timestamp) })
+	case UFANET_CALL_DTMF:
+		h.updateApartmentCall(h.calls, host, apartment, func(callData *CallData) { callData.openDoor(timestamp) })
+	case UFANET_CALL_END:
+		h.endApartmentCall(h.calls, timestamp, host, apartment)
+	}
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/config"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/events"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/repository"
+	storage2 "github.com/kulakoff/event-server-go/internal/app/event-server-go/storage"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/syslog_custom"
+	"github.com/redis/go-redis/v9"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// Dependency handler dependencies declared on registration
+type Dependency uint
+
+const (
+	NeedClickhouse Dependency = 1 << iota
+	NeedMongo
+	NeedRepo
+	NeedRedis
+	NeedFrs
+
+	// needEvents plog events with camshots
+	needEvents = NeedClickhouse | NeedMongo | NeedRepo | NeedRedis
+)
+
+// Deps shared dependency container for panel handlers
+type Deps struct {
+	Logger      *slog.Logger
+	Clickhouse  storage2.EventStore
+	Mongo       *storage2.MongoHandler
+	Repo        *repository.PostgresRepository
+	Redis       *redis.Client
+	RbtApi      *config.RbtApi
+	FrsApi      *config.FrsApi
+	SpamFilters *config.SpamFilters
+	Usage       *UsageUpdater // key and flat usage, updates are skipped if nil
+	Writer      *events.EventWriter
+}
+
+// Check error with missing dependencies
+func (d *Deps) Check(needs Dependency) error {
+	var missing []string
+	if needs&NeedClickhouse != 0 && d.Clickhouse == nil {
+		missing = append(missing, "clickhouse")
+	}
+	if needs&NeedMongo != 0 && d.Mongo == nil {
+		missing = append(missing, "mongo")
+	}
+	if needs&NeedRepo != 0 && d.Repo == nil {
+		missing = append(missing, "postgres")
+	}
+	if needs&NeedRedis != 0 && d.Redis == nil {
+		missing = append(missing, "redis")
+	}
+	if needs&NeedFrs != 0 && d.FrsApi == nil {
+		missing = append(missing, "frs")
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("missing dependencies: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// Vendor panel handler registered under HwConfig json name, one of Syslog or HTTP is set
+type Vendor struct {
+	Name   string // HwConfig json name
+	Unit   string // server unit
+	Needs  Dependency
+	Syslog func(deps *Deps, panel config.PanelConfig) syslog_custom.MessageHandler
+	HTTP   func(deps *Deps, panel config.PanelConfig) http.Handler
+}
+
+var vendors = make(map[string]Vendor)
+
+// Register vendor handler, called from handler init
+func Register(vendor Vendor) {
+	if _, exists := vendors[vendor.Name]; exists {
+		panic("handlers: vendor registered twice: " + vendor.Name)
+	}
+	if (vendor.Syslog == nil) == (vendor.HTTP == nil) {
+		panic("handlers: vendor needs one of syslog or http constructor: " + vendor.Name)
+	}
+	vendors[vendor.Name] = vendor
+}
+
+// LookupVendor registered vendor by HwConfig json name
+func LookupVendor(name string) (Vendor, bool) {
+	vendor, ok := vendors[name]
+	return vendor, ok
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/repository/models"
+	storage2 "github.com/kulakoff/event-server-go/internal/app/event-server-go/storage"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/utils"
+	"strings"
+	"time"
+)
+
+// security event types, also houses_watchers.event_type of the push subscription
+const (
+	SECURITY_BREAK_IN = "break_in" // door opened without open command
+	SECURITY_TAMPER   = "tamper"   // panel case opened
+)
+
+var securityTitles = map[string]string{
+	SECURITY_BREAK_IN: "Взлом двери",
+	SECURITY_TAMPER:   "Вскрытие домофона",
+}
+
+var (
+	// tamperMessages panel case open fragments, lower case: "Tamper alarm", "Case open detected"
+	tamperMessages = []string{"tamper", "case open", "case is open"}
+
+	// tamperRestoreMessages case closed back, not an alarm: "Tamper restored", "Case closed"
+	tamperRestoreMessages = []string{"restore", "closed", "normal"}
+)
+
+// SecurityEvent panel alarm, stored to security table apart from plog
+type SecurityEvent struct {
+	Timestamp *time.Time
+	Host      string            // domophone IP
+	Domophone *models.Domophone // optional, resolved by Host if nil
+	Door      int               // domophone output: DOOR_MAIN, DOOR_SECONDARY
+	Type      string            // SECURITY_BREAK_IN, SECURITY_TAMPER
+	Message   string
+}
+
+// processSecurityEvent save security event and send push to the house watchers
+func (h *baseHandler) processSecurityEvent(ctx context.Context, event SecurityEvent) {
+	domophone := event.Domophone
+	if domophone == nil {
+		var err error
+		domophone, err = h.repo.Households.GetDomophone(ctx, "ip", event.Host)
+		if err != nil {
+			h.logger.Warn("Failed to get domophone", "host", event.Host, "error", err)
+			return
+		}
+	}
+
+	securityEvent := storage2.SecurityEvent{
+		Date:        event.Timestamp.Unix(),
+		EventUUID:   uuid.New().String(),
+		Type:        event.Type,
+		DomophoneID: domophone.HouseDomophoneID,
+		Ip:          event.Host,
+		Unit:        h.unit,
+		Msg:         event.Message,
+	}
+
+	// the alarm is saved without entrance if the domophone is not linked
+	entrance, err := h.repo.Households.GetEntrance(ctx, domophone.HouseDomophoneID, event.Door)
+	if err != nil {
+		h.logger.Warn("Failed to get entrance", "host", event.Host, "door", event.Door, "error", err)
+	} else {
+		securityEvent.EntranceID = entrance.HouseEntranceID
+		securityEvent.HouseID = entrance.AddressHouseID
+	}
+
+	h.logger.Info("Security event", "unit", h.unit, "host", event.Host, "type", event.Type, "domophoneID", domophone.HouseDomophoneID)
+
+	if err := h.storage.InsertSecurity(ctx, []storage2.SecurityEvent{securityEvent}); err != nil {
+		h.logger.Warn("Failed to insert security event", "unit", h.unit, "error", err)
+	}
+
+	if entrance != nil {
+		h.notifySecurityWatchers(ctx, entrance, event.Type)
+	}
+}
+
+// isTamperMessage panel case opened, restore messages are skipped
+func isTamperMessage(message string) bool {
+	message = strings.ToLower(message)
+	for _, restore := range tamperRestoreMessages {
+		if strings.Contains(message, restore) {
+			return false
+		}
+	}
+	for _, tamper := range tamperMessages {
+		if strings.Contains(message, tamper) {
+			return true
+		}
+	}
+	return false
+}
+
+// notifySecurityWatchers send push to watchers of the house flats subscribed to the security event type
+func (h *baseHandler) notifySecurityWatchers(ctx context.Context, entrance *models.HouseEntrance, eventType string) {
+	watchers, err := h.repo.Households.GetWatchersByHouseID(ctx, entrance.AddressHouseID, eventType)
+	if err != nil {
+		h.logger.Warn("Failed to get house watchers", "houseID", entrance.AddressHouseID, "error", err)
+		return
+	}
+	if len(watchers) == 0 {
+		return
+	}
+
+	house, err := h.repo.Households.GetHouseByEntranceID(ctx, entrance.HouseEntranceID)
+	if err != nil {
+		h.logger.Warn("Failed to get house", "error", err)
+	}
+
+	msgTitle := securityTitles[eventType]
+	msgBody := fmt.Sprintf("Адрес: %s\nПодъезд: %s", house.HouseFull, entrance.Entrance)
+	for _, watcher := range watchers {
+		device, err := h.repo.Households.GetMobileDeviceByID(ctx, watcher.DeviceID)
+		if err != nil {
+			h.logger.Warn("Failed to get watcher device", "deviceID", watcher.DeviceID, "error", err)
+			continue
+		}
+
+		go utils.SendPush("", msgTitle, msgBody, device.PushToken, device.PushTokenType, device.Platform)
+	}
+}
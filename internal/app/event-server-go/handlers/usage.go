@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/config"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/repository"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/utils"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	usageFlushInterval = 10 * time.Second
+	usageFlushTimeout  = 5 * time.Second
+
+	rbtOpenDoorPath = "/actions/openDoor"
+)
+
+// usageKey door openings with the same event and detail are coalesced
+type usageKey struct {
+	event  int
+	detail string // rfid, code or face id
+}
+
+type usageMark struct {
+	time    int64 // last opening
+	host    string
+	flatIDs []int
+}
+
+// UsageUpdater batched RFID last_seen and flat last_opened updates,
+// flushed to Postgres or to RBT internal API if rbtApi.openDoor is set
+type UsageUpdater struct {
+	logger  *slog.Logger
+	repo    *repository.PostgresRepository
+	openURL string // RBT openDoor action, Postgres updates if empty
+
+	mu    sync.Mutex
+	marks map[usageKey]*usageMark
+}
+
+func NewUsageUpdater(logger *slog.Logger, repo *repository.PostgresRepository, rbtApi *config.RbtApi) *UsageUpdater {
+	u := &UsageUpdater{
+		logger: logger,
+		repo:   repo,
+		marks:  make(map[usageKey]*usageMark),
+	}
+	if rbtApi != nil && rbtApi.OpenDoor {
+		u.openURL = rbtApi.Internal + rbtOpenDoorPath
+	}
+	return u
+}
+
+// Used mark door opening, nil updater skips the update
+func (u *UsageUpdater) Used(event int, detail, host string, flatIDs []int, timestamp time.Time) {
+	if u == nil {
+		return
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	key := usageKey{event, detail}
+	mark, exists := u.marks[key]
+	if !exists {
+		mark = &usageMark{}
+		u.marks[key] = mark
+	}
+	if timestamp.Unix() >= mark.time {
+		mark.time = timestamp.Unix()
+		mark.host = host
+	}
+	for _, flatID := range flatIDs {
+		if !slices.Contains(mark.flatIDs, flatID) {
+			mark.flatIDs = append(mark.flatIDs, flatID)
+		}
+	}
+}
+
+// Start flush marks every usageFlushInterval and on shutdown
+func (u *UsageUpdater) Start(ctx context.Context) error {
+	ticker := time.NewTicker(usageFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			u.flush()
+			return nil
+		case <-ticker.C:
+			u.flush()
+		}
+	}
+}
+
+func (u *UsageUpdater) flush() {
+	u.mu.Lock()
+	marks := u.marks
+	u.marks = make(map[usageKey]*usageMark)
+	u.mu.Unlock()
+
+	if len(marks) == 0 {
+		return
+	}
+
+	if u.openURL != "" {
+		u.flushToRBT(marks)
+		return
+	}
+	u.flushToPostgres(marks)
+}
+
+func (u *UsageUpdater) flushToPostgres(marks map[usageKey]*usageMark) {
+	ctx, cancel := context.WithTimeout(context.Background(), usageFlushTimeout)
+	defer cancel()
+
+	lastSeen := make(map[string]int64)
+	lastOpened := make(map[int]int64)
+	for key, mark := range marks {
+		if key.event == Event.OpenByKey && key.detail != "" {
+			lastSeen[key.detail] = max(lastSeen[key.detail], mark.time)
+		}
+		for _, flatID := range mark.flatIDs {
+			lastOpened[flatID] = max(lastOpened[flatID], mark.time)
+		}
+	}
+
+	if err := u.repo.Households.UpdateRFIDsLastSeen(ctx, lastSeen); err != nil {
+		u.logger.Warn("Failed to update RFIDs last seen", "count", len(lastSeen), "error", err)
+	}
+	if err := u.repo.Households.UpdateFlatsLastOpened(ctx, lastOpened); err != nil {
+		u.logger.Warn("Failed to update flats last opened", "count", len(lastOpened), "error", err)
+	}
+}
+
+// flushToRBT one openDoor action per coalesced opening
+func (u *UsageUpdater) flushToRBT(marks map[usageKey]*usageMark) {
+	headers := map[string]string{
+		"Content-Type": "application/json",
+	}
+
+	for key, mark := range marks {
+		payload := OpenDoorMsg{
+			Date:   strconv.FormatInt(mark.time, 10),
+			IP:     mark.host,
+			Event:  key.event,
+			Detail: key.detail,
+		}
+
+		_, status, err := utils.SendPostRequest(u.openURL, headers, payload)
+		if err == nil && status >= http.StatusBadRequest {
+			err = fmt.Errorf("non-OK HTTP status: %d", status)
+		}
+		if err != nil {
+			u.logger.Warn("Failed to send openDoor to RBT", "event", key.event, "detail", key.detail, "error", err)
+		}
+	}
+}
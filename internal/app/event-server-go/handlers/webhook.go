@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/repository/models"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// webhook event types, WebhookEvent.Type
+const (
+	WEBHOOK_OPEN_BY_KEY   = "open_by_key"
+	WEBHOOK_OPEN_BY_CODE  = "open_by_code"
+	WEBHOOK_OPEN_BY_APP   = "open_by_app"
+	WEBHOOK_CALL_ANSWERED = "call_answered"
+	WEBHOOK_CALL_MISSED   = "call_missed"
+
+	// webhookMaxBody webhook payload size limit
+	webhookMaxBody = 1 << 20
+
+	webhookSecretHeader = "X-Webhook-Secret"
+)
+
+// WebhookEvent cloud panel event decoded from vendor payload
+type WebhookEvent struct {
+	DeviceID  string     // houses_domophones.sub_id
+	Type      string     // WEBHOOK_OPEN_BY_KEY, ...
+	Time      *time.Time // event time, receive time if not set
+	RFID      string
+	Code      string
+	Apartment int
+	Door      int // domophone output
+}
+
+// webhookDecoder vendor payload to events
+type webhookDecoder func(body []byte) ([]WebhookEvent, error)
+
+// webhookHandler cloud webhook receiver: shared secret check, payload decoding and plog events
+type webhookHandler struct {
+	baseHandler
+	secret string
+	decode webhookDecoder
+}
+
+func newWebhookHandler(base baseHandler, secret string, decode webhookDecoder) webhookHandler {
+	if secret == "" {
+		base.logger.Warn("Webhook secret is not set, all requests are rejected", "unit", base.unit)
+	}
+
+	return webhookHandler{
+		baseHandler: base,
+		secret:      secret,
+		decode:      decode,
+	}
+}
+
+func (h *webhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !h.authorized(r) {
+		h.logger.Warn("Webhook unauthorized", "unit", h.unit, "ip", r.RemoteAddr)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, webhookMaxBody))
+	if err != nil {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	events, err := h.decode(body)
+	if err != nil {
+		h.logger.Warn("Failed to decode webhook payload", "unit", h.unit, "error", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+
+	for _, event := range events {
+		h.HandleEvent(event)
+	}
+}
+
+// authorized shared secret of the panel config
+func (h *webhookHandler) authorized(r *http.Request) bool {
+	return validSecret(r, h.secret)
+}
+
+// validSecret shared secret from "Authorization: Bearer", X-Webhook-Secret header or "secret" query parameter,
+// requests are rejected if the secret is not set
+func validSecret(r *http.Request, secret string) bool {
+	if secret == "" {
+		return false
+	}
+
+	value := r.Header.Get(webhookSecretHeader)
+	if value == "" {
+		value, _ = strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	}
+	if value == "" {
+		value = r.URL.Query().Get("secret")
+	}
+
+	return subtle.ConstantTimeCompare([]byte(value), []byte(secret)) == 1
+}
+
+// HandleEvent resolve domophone by device id and make plog event
+func (h *webhookHandler) HandleEvent(event WebhookEvent) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	timestamp := event.Time
+	if timestamp == nil {
+		// FIXME: load location from system or config
+		location, _ := time.LoadLocation("Europe/Moscow")
+		now := time.Now().In(location).Truncate(time.Second)
+		timestamp = &now
+	}
+
+	if event.DeviceID == "" {
+		h.logger.Warn("Webhook event without device id", "unit", h.unit, "type", event.Type)
+		return
+	}
+
+	domophone, err := h.repo.Households.GetDomophone(ctx, "sub_id", event.DeviceID)
+	if err != nil {
+		h.logger.Warn("Failed to get webhook domophone", "unit", h.unit, "deviceID", event.DeviceID, "error", err)
+		return
+	}
+
+	host := event.DeviceID
+	if domophone.IP != nil && *domophone.IP != "" {
+		host = *domophone.IP
+	}
+
+	message := fmt.Sprintf("%s device:%s apartment:%d door:%d rfid:%s", event.Type, event.DeviceID, event.Apartment, event.Door, event.RFID)
+	h.logger.Debug("HandleEvent || Processing webhook event", "unit", h.unit, "host", host, "message", message)
+	h.storeSyslog(host, message)
+
+	doorEvent := DoorOpenEvent{
+		Timestamp: timestamp,
+		Host:      host,
+		Domophone: domophone,
+		Door:      event.Door,
+	}
+
+	switch event.Type {
+	case WEBHOOK_OPEN_BY_KEY:
+		h.openByKey(ctx, doorEvent, event.RFID)
+		return
+
+	case WEBHOOK_OPEN_BY_CODE:
+		h.openByCode(ctx, doorEvent, event.Code)
+		return
+
+	case WEBHOOK_OPEN_BY_APP:
+		doorEvent.Event = Event.OpenByApp
+		if event.Apartment > 0 {
+			if flat, ok := h.apartmentFlat(ctx, domophone, event.Apartment); ok {
+				doorEvent.Flats = []models.Flat{flat}
+			}
+		}
+
+	case WEBHOOK_CALL_ANSWERED, WEBHOOK_CALL_MISSED:
+		callData, err := h.newCallData(ctx, timestamp, host, domophone, event.Apartment, CALL_TYPE_SIP)
+		if err != nil {
+			h.logger.Warn("Failed to collect call data", "unit", h.unit, "deviceID", event.DeviceID, "apartment", event.Apartment, "error", err)
+			return
+		}
+		if callData.FlatID == 0 {
+			h.logger.Warn("Call without flat, event skipped", "unit", h.unit, "deviceID", event.DeviceID, "apartment", event.Apartment)
+			return
+		}
+		callData.Answered = event.Type == WEBHOOK_CALL_ANSWERED
+		callData.EndTime = timestamp
+
+		// cloud reports finished calls only
+		go func() {
+			h.getCallScreenshots(callData)
+			h.prepareFinalCallEvent(callData)
+		}()
+		return
+
+	default:
+		h.logger.Debug("Webhook event not processed", "unit", h.unit, "type", event.Type)
+		return
+	}
+
+	h.processDoorOpen(ctx, doorEvent)
+}
+
+func (h *webhookHandler) apartmentFlat(ctx context.Context, domophone *models.Domophone, apartment int) (models.Flat, bool) {
+	flatID, err := h.repo.Households.GetFlatIDByApartment(ctx, apartment, domophone.HouseDomophoneID)
+	if err != nil {
+		h.logger.Warn("Failed to get flatID", "unit", h.unit, "apartment", apartment, "error", err)
+		return models.Flat{}, false
+	}
+
+	flat, err := h.repo.Households.GetFlatByID(ctx, flatID)
+	if err != nil {
+		h.logger.Warn("Failed to get flat", "flatID", flatID, "error", err)
+		return models.Flat{}, false
+	}
+	return flat, true
+}
@@ -0,0 +1,81 @@
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/config"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const (
+	readTimeout     = 30 * time.Second
+	shutdownTimeout = 5 * time.Second
+)
+
+// Server HTTP listener for panels reporting events by HTTP notifications or webhooks
+type Server struct {
+	port   int
+	unit   string
+	logger *slog.Logger
+	mux    *http.ServeMux
+}
+
+// New handler is mounted on panel api endpoint, "/" by default
+func New(panel config.PanelConfig, unit string, logger *slog.Logger, handler http.Handler) *Server {
+	s := &Server{
+		port:   panel.Port,
+		unit:   unit,
+		logger: logger,
+		mux:    http.NewServeMux(),
+	}
+	s.Handle(panel.APIEndpoint, handler)
+	return s
+}
+
+// Handle mount one more panel handler, for panels sharing the port
+func (s *Server) Handle(endpoint string, handler http.Handler) {
+	if endpoint == "" {
+		endpoint = "/"
+	}
+	s.mux.Handle(endpoint, handler)
+	s.logger.Debug("HTTP handler mounted", "unit", s.unit, "port", s.port, "endpoint", endpoint)
+}
+
+// Start runs the listener and blocks until ctx is canceled
+func (s *Server) Start(ctx context.Context) error {
+	server := &http.Server{
+		Addr:              fmt.Sprintf(":%d", s.port),
+		Handler:           s.mux,
+		ReadHeaderTimeout: readTimeout,
+		ReadTimeout:       readTimeout,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.ListenAndServe()
+	}()
+
+	s.logger.Info("HTTP server running", "unit", s.unit, "port", s.port)
+
+	select {
+	case err := <-errCh:
+		s.logger.Error("Error starting HTTP listener", "unit", s.unit, "error", err)
+		return err
+	case <-ctx.Done():
+		s.logger.Info("🛑 Shutting down HTTP server", "unit", s.unit)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("failed to shutdown HTTP server: %w", err)
+	}
+	if err := <-errCh; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
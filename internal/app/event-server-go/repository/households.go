@@ -15,6 +15,8 @@ import (
 
 type HouseHoldRepository interface {
 	UpdateRFIDLastSeen(ctx context.Context, rfid string) error
+	UpdateRFIDsLastSeen(ctx context.Context, lastSeen map[string]int64) error
+	UpdateFlatsLastOpened(ctx context.Context, lastOpened map[int]int64) error
 	GetFlatByRFID(ctx context.Context, rfid string) (int, error)
 	GetDomophoneIDByIP(ctx context.Context, ip string) (int, error)
 	GetEntrance(ctx context.Context, domophoneId, output int) (*models.HouseEntrance, error)
@@ -24,7 +26,9 @@ type HouseHoldRepository interface {
 	GetFlatsByFaceIdFrs(ctx context.Context, faceId string, entranceId string) ([]int, error)
 	GetFlatByID(ctx context.Context, flatID int) (models.Flat, error)
 	GetFlatIDByApartment(ctx context.Context, apartment int, domophoneId int) (int, error)
+	GetDomophoneFlatIDs(ctx context.Context, domophoneID int, flatIDs []int) ([]int, error)
 	GetWatchersByFlatID(ctx context.Context, flatID int) ([]models.Watcher, error)
+	GetWatchersByHouseID(ctx context.Context, houseID int, eventType string) ([]models.Watcher, error)
 	GetRFID(ctx context.Context, rfid string) ([]models.RFID, error)
 	GetSubscriberIDByFlatIDandPhone(ctx context.Context, flatID int, phone string) (int, error)
 	FlatIDsByDomophoneIDAndPhone(ctx context.Context, domophoneID int, phone string) ([]int, error)
@@ -64,6 +68,62 @@ func (r *HouseholdRepositoryImpl) UpdateRFIDLastSeen(ctx context.Context, rfid s
 	return nil
 }
 
+// UpdateRFIDsLastSeen update last usage of the keys in one query
+func (r *HouseholdRepositoryImpl) UpdateRFIDsLastSeen(ctx context.Context, lastSeen map[string]int64) error {
+	if len(lastSeen) == 0 {
+		return nil
+	}
+
+	rfids := make([]string, 0, len(lastSeen))
+	times := make([]int64, 0, len(lastSeen))
+	for rfid, timestamp := range lastSeen {
+		rfids = append(rfids, rfid)
+		times = append(times, timestamp)
+	}
+
+	query := `
+		UPDATE houses_rfids r
+		SET last_seen = u.last_seen
+		FROM unnest($1::text[], $2::bigint[]) AS u(rfid, last_seen)
+		WHERE r.rfid = u.rfid AND (r.last_seen IS NULL OR r.last_seen < u.last_seen)`
+
+	result, err := r.db.Exec(ctx, query, rfids, times)
+	if err != nil {
+		return fmt.Errorf("failed to update last_seen for %d RFIDs: %w", len(rfids), err)
+	}
+
+	r.logger.Debug("Updated RFIDs last_seen", "count", len(rfids), "rowsAffected", result.RowsAffected())
+	return nil
+}
+
+// UpdateFlatsLastOpened update flats last door opening in one query
+func (r *HouseholdRepositoryImpl) UpdateFlatsLastOpened(ctx context.Context, lastOpened map[int]int64) error {
+	if len(lastOpened) == 0 {
+		return nil
+	}
+
+	flatIDs := make([]int, 0, len(lastOpened))
+	times := make([]int64, 0, len(lastOpened))
+	for flatID, timestamp := range lastOpened {
+		flatIDs = append(flatIDs, flatID)
+		times = append(times, timestamp)
+	}
+
+	query := `
+		UPDATE houses_flats f
+		SET last_opened = u.last_opened
+		FROM unnest($1::int[], $2::bigint[]) AS u(house_flat_id, last_opened)
+		WHERE f.house_flat_id = u.house_flat_id AND (f.last_opened IS NULL OR f.last_opened < u.last_opened)`
+
+	result, err := r.db.Exec(ctx, query, flatIDs, times)
+	if err != nil {
+		return fmt.Errorf("failed to update last_opened for %d flats: %w", len(flatIDs), err)
+	}
+
+	r.logger.Debug("Updated flats last_opened", "count", len(flatIDs), "rowsAffected", result.RowsAffected())
+	return nil
+}
+
 func (r *HouseholdRepositoryImpl) GetFlatByRFID(ctx context.Context, rfid string) (int, error) {
 	// TODO implement me
 	return 0, nil
@@ -192,9 +252,18 @@ func (r *HouseholdRepositoryImpl) GetDomophone(ctx context.Context, by string, p
             WHERE ip = $1
         `
 		queryParam = param
+	case "sub_id":
+		// device serial or cloud device id
+		query = `
+            SELECT house_domophone_id, enabled, model, server, url, credentials, dtmf, first_time, nat, 
+                   locks_are_open, ip, sub_id, name, comments, display, video
+            FROM houses_domophones 
+            WHERE sub_id = $1
+        `
+		queryParam = param
 	default:
 		r.logger.Error("Invalid search type", "by", by)
-		return nil, fmt.Errorf("invalid search type: %s; must be 'id' or 'ip'", by)
+		return nil, fmt.Errorf("invalid search type: %s; must be 'id', 'ip' or 'sub_id'", by)
 	}
 
 	var domophone models.Domophone
@@ -518,6 +587,47 @@ func (r *HouseholdRepositoryImpl) GetFlatIDByApartment(ctx context.Context, apar
 	return flatID, nil
 }
 
+// GetDomophoneFlatIDs flats of the list served by the domophone entrances
+func (r *HouseholdRepositoryImpl) GetDomophoneFlatIDs(ctx context.Context, domophoneID int, flatIDs []int) ([]int, error) {
+	if len(flatIDs) == 0 {
+		return nil, nil
+	}
+
+	query := `
+		SELECT DISTINCT
+			hef.house_flat_id
+		FROM
+			houses_entrances_flats hef
+			JOIN houses_entrances he ON he.house_entrance_id = hef.house_entrance_id
+		WHERE
+			he.house_domophone_id = $1
+			AND hef.house_flat_id = ANY($2)
+		ORDER BY
+			hef.house_flat_id
+	`
+
+	rows, err := r.db.Query(ctx, query, domophoneID, flatIDs)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var served []int
+	for rows.Next() {
+		var flatID int
+		if err := rows.Scan(&flatID); err != nil {
+			return nil, fmt.Errorf("scan failed: %w", err)
+		}
+		served = append(served, flatID)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to scan houses_entrances_flats: %w", err)
+	}
+
+	return served, nil
+}
+
 // TODO: implement method
 func (r *HouseholdRepositoryImpl) GetWatchersByFlatID(ctx context.Context, flatID int) ([]models.Watcher, error) {
 	query := `
@@ -573,6 +683,51 @@ func (r *HouseholdRepositoryImpl) GetWatchersByFlatID(ctx context.Context, flatI
 	return watchers, nil
 }
 
+// GetWatchersByHouseID watchers of the house flats subscribed to the event type
+func (r *HouseholdRepositoryImpl) GetWatchersByHouseID(ctx context.Context, houseID int, eventType string) ([]models.Watcher, error) {
+	query := `
+		SELECT
+			hw.house_watcher_id,
+			hw.subscriber_device_id,
+			hw.house_flat_id,
+			hw.event_type,
+			hw.event_detail,
+			hw.comments
+		FROM houses_watchers hw
+		JOIN houses_flats hf ON hf.house_flat_id = hw.house_flat_id
+		WHERE hf.address_house_id = $1 AND hw.event_type = $2
+		ORDER BY hw.house_watcher_id
+	`
+
+	rows, err := r.db.Query(ctx, query, houseID, eventType)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	var watchers []models.Watcher
+	for rows.Next() {
+		var watcher models.Watcher
+		if err := rows.Scan(
+			&watcher.WatcherID,
+			&watcher.DeviceID,
+			&watcher.FlatID,
+			&watcher.EventType,
+			&watcher.EventDetail,
+			&watcher.Comments,
+		); err != nil {
+			return nil, fmt.Errorf("scan failed: %w", err)
+		}
+		watchers = append(watchers, watcher)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to scan house_watchers: %w", err)
+	}
+
+	return watchers, nil
+}
+
 func (r *HouseholdRepositoryImpl) GetDeviceByID(ctx context.Context, deviceID int) {
 
 }
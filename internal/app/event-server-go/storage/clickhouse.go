@@ -2,6 +2,7 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/ClickHouse/clickhouse-go/v2"
 	"github.com/kulakoff/event-server-go/internal/app/event-server-go/config"
@@ -10,9 +11,11 @@ import (
 	"time"
 )
 
+// ClikhouseHandler native protocol client, rows are sent as columnar batches
 type ClikhouseHandler struct {
 	logger     *slog.Logger
 	clickhouse clickhouse.Conn
+	batcher    *clickhouseBatcher
 }
 
 type SyslogStorageMessage struct {
@@ -25,7 +28,6 @@ type SyslogStorageMessage struct {
 
 func NewClickhouse(logger *slog.Logger, config *config.ClickhouseConfig) (*ClikhouseHandler, error) {
 	dsn := config.Host + ":" + strconv.Itoa(config.Port)
-	fmt.Println(dsn)
 	conn, err := clickhouse.Open(&clickhouse.Options{
 		Addr: []string{dsn},
 		Auth: clickhouse.Auth{
@@ -43,36 +45,165 @@ func NewClickhouse(logger *slog.Logger, config *config.ClickhouseConfig) (*Clikh
 		return nil, fmt.Errorf("failed ping clickhouse dsn %s: %w ", dsn, err)
 	}
 
+	c := &ClikhouseHandler{logger: logger, clickhouse: conn}
+	c.batcher, err = newClickhouseBatcher(logger, config.Batch, c.insertBatch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to init Clickhouse batches: %w", err)
+	}
+
 	logger.Info("Connected to Clickhouse")
-	return &ClikhouseHandler{logger: logger, clickhouse: conn}, nil
+	return c, nil
 }
 
-func (c *ClikhouseHandler) SendLog(message SyslogStorageMessage) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
-	defer cancel()
+// Start send batched inserts until ctx is done, the rest is flushed or spooled on return
+func (c *ClikhouseHandler) Start(ctx context.Context) error {
+	c.batcher.run(ctx)
+	return nil
+}
 
-	err := c.clickhouse.Exec(ctx, "INSERT INTO syslog (date, ip, sub_id, unit, msg) VALUES (?, ?, ? ,? ,?)",
-		message.Date, message.Ip, message.SubId, message.Unit, message.Msg)
+// Migrate apply embedded schema migrations
+func (c *ClikhouseHandler) Migrate(ctx context.Context) error {
+	return migrate(ctx, c.logger, c)
+}
+
+func (c *ClikhouseHandler) exec(ctx context.Context, query string) error {
+	return c.clickhouse.Exec(ctx, query)
+}
+
+func (c *ClikhouseHandler) appliedVersions(ctx context.Context) (map[int]bool, error) {
+	rows, err := c.clickhouse.Query(ctx, "SELECT version FROM "+migrationsTable)
 	if err != nil {
-		c.logger.Error("Failed to insert into ClickHouse", "error", err)
-		return
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := make(map[int]bool)
+	for rows.Next() {
+		var version uint32
+		if err := rows.Scan(&version); err != nil {
+			return nil, fmt.Errorf("failed to scan migration version: %w", err)
+		}
+		versions[int(version)] = true
 	}
+	return versions, rows.Err()
+}
+
+// InsertSyslog queue syslog rows
+func (c *ClikhouseHandler) InsertSyslog(ctx context.Context, rows []SyslogStorageMessage) error {
+	return queueRows(c.batcher, syslogTable, rows)
+}
+
+// InsertPlog queue plog rows
+func (c *ClikhouseHandler) InsertPlog(ctx context.Context, rows []PlogRow) error {
+	return queueRows(c.batcher, plogTable, rows)
+}
 
-	c.logger.Debug("Message inserted")
+// InsertSecurity queue security rows
+func (c *ClikhouseHandler) InsertSecurity(ctx context.Context, rows []SecurityEvent) error {
+	return queueRows(c.batcher, securityTable, rows)
 }
 
-func (c *ClikhouseHandler) InsertPlog(plogData string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+// insertBatch decode batch rows and send them as one native batch, errRejected if data is invalid
+func (c *ClikhouseHandler) insertBatch(ctx context.Context, table string, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Second*30)
 	defer cancel()
 
-	query := "INSERT INTO plog FORMAT JSONEachRow"
+	var (
+		query  string
+		values [][]any
+		err    error
+	)
+	switch table {
+	case syslogTable:
+		query = "INSERT INTO syslog (date, ip, sub_id, unit, msg)"
+		values, err = syslogValues(body)
+	case plogTable:
+		query = "INSERT INTO plog (date, event_uuid, hidden, image_uuid, flat_id, domophone, event, opened, face, rfid, code, phones, preview, call_info)"
+		values, err = plogValues(body)
+	case securityTable:
+		query = "INSERT INTO security (date, event_uuid, type, domophone_id, entrance_id, house_id, ip, unit, msg)"
+		values, err = securityValues(body)
+	default:
+		err = fmt.Errorf("unknown table %q", table)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %w", errRejected, err)
+	}
 
-	err := c.clickhouse.Exec(ctx, query, plogData)
+	batch, err := c.clickhouse.PrepareBatch(ctx, query)
 	if err != nil {
-		c.logger.Error("Failed to insert plog into Clickhouse", "error", err)
-		return err
+		return c.batchError(err)
+	}
+	defer batch.Abort()
+
+	for _, row := range values {
+		if err := batch.Append(row...); err != nil {
+			return fmt.Errorf("%w: %w", errRejected, err)
+		}
 	}
 
-	c.logger.Debug("Plog data inserted success")
+	if err := batch.Send(); err != nil {
+		return c.batchError(err)
+	}
 	return nil
 }
+
+// batchError server exceptions are bad data or schema, other errors are retried
+func (c *ClikhouseHandler) batchError(err error) error {
+	var exception *clickhouse.Exception
+	if errors.As(err, &exception) {
+		return fmt.Errorf("%w: %w", errRejected, err)
+	}
+	return err
+}
+
+func syslogValues(body []byte) ([][]any, error) {
+	rows, err := decodeRows[SyslogStorageMessage](body)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([][]any, 0, len(rows))
+	for _, row := range rows {
+		date, err := strconv.ParseUint(row.Date, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid syslog date %q: %w", row.Date, err)
+		}
+		values = append(values, []any{uint32(date), row.Ip, row.SubId, row.Unit, row.Msg})
+	}
+	return values, nil
+}
+
+func plogValues(body []byte) ([][]any, error) {
+	rows, err := decodeRows[PlogRow](body)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([][]any, 0, len(rows))
+	for _, row := range rows {
+		values = append(values, []any{
+			uint32(row.Date), row.EventUUID, int8(row.Hidden), row.ImageUUID, uint32(row.FlatID),
+			string(row.Domophone), uint8(row.Event), uint8(row.Opened), string(row.Face),
+			row.RFID, row.Code, string(row.Phones), uint8(row.Preview), string(row.CallInfo),
+		})
+	}
+	return values, nil
+}
+
+func securityValues(body []byte) ([][]any, error) {
+	rows, err := decodeRows[SecurityEvent](body)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([][]any, 0, len(rows))
+	for _, row := range rows {
+		values = append(values, []any{
+			uint32(row.Date), row.EventUUID, row.Type,
+			uint32(row.DomophoneID), uint32(row.EntranceID), uint32(row.HouseID),
+			row.Ip, row.Unit, row.Msg,
+		})
+	}
+	return values, nil
+}
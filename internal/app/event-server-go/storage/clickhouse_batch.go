@@ -0,0 +1,384 @@
+package storage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/config"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultBatchSize     = 1000
+	defaultFlushInterval = time.Second
+	defaultMaxRetries    = 3
+	defaultSpoolDir      = "spool"
+
+	retryBackoff = 500 * time.Millisecond
+
+	spoolExt    = ".jsonl.gz"
+	rejectedDir = "rejected" // rows refused by Clickhouse, kept for manual inspection
+)
+
+// errRejected Clickhouse refused the batch, retry or replay will not help
+var errRejected = errors.New("batch rejected by Clickhouse")
+
+// clickhouseBatcher per table row buffers, flushed as one gzip JSONEachRow insert by size or interval.
+// Batches are spooled to disk while Clickhouse is unreachable and replayed in order after recovery,
+// new batches go to the spool too until it is empty to keep the order
+type clickhouseBatcher struct {
+	logger     *slog.Logger
+	send       func(ctx context.Context, table string, body []byte) error
+	size       int
+	interval   time.Duration
+	maxRetries int
+	dir        string
+
+	mu     sync.Mutex
+	tables map[string][]string
+	full   chan struct{}
+
+	// flush goroutine only
+	seq     int64 // last spool file number
+	spooled int   // files waiting for replay
+}
+
+func newClickhouseBatcher(
+	logger *slog.Logger,
+	cfg *config.ClickhouseBatchConfig,
+	send func(ctx context.Context, table string, body []byte) error,
+) (*clickhouseBatcher, error) {
+	if cfg == nil {
+		cfg = &config.ClickhouseBatchConfig{}
+	}
+
+	b := &clickhouseBatcher{
+		logger:     logger,
+		send:       send,
+		size:       cfg.Size,
+		interval:   time.Duration(cfg.FlushIntervalMs) * time.Millisecond,
+		maxRetries: cfg.MaxRetries,
+		dir:        cfg.SpoolDir,
+		tables:     make(map[string][]string),
+		full:       make(chan struct{}, 1),
+	}
+	if b.size <= 0 {
+		b.size = defaultBatchSize
+	}
+	if b.interval <= 0 {
+		b.interval = defaultFlushInterval
+	}
+	if b.maxRetries <= 0 {
+		b.maxRetries = defaultMaxRetries
+	}
+	if b.dir == "" {
+		b.dir = defaultSpoolDir
+	}
+
+	if err := os.MkdirAll(filepath.Join(b.dir, rejectedDir), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create spool dir: %w", err)
+	}
+
+	// batches left from the previous run
+	files, err := b.spoolFiles()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range files {
+		if seq, _, ok := parseSpoolName(name); ok {
+			b.seq = max(b.seq, seq)
+		}
+	}
+	b.spooled = len(files)
+	if b.spooled > 0 {
+		logger.Warn("Clickhouse spool is not empty, replay on start", "dir", b.dir, "files", b.spooled)
+	}
+
+	return b, nil
+}
+
+// add queue row, flush is signaled when the table batch is full
+func (b *clickhouseBatcher) add(table, row string) {
+	b.mu.Lock()
+	b.tables[table] = append(b.tables[table], row)
+	full := len(b.tables[table]) >= b.size
+	b.mu.Unlock()
+
+	if full {
+		select {
+		case b.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// run flush batches until ctx is done, then flush the rest
+func (b *clickhouseBatcher) run(ctx context.Context) {
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			b.replay()
+			b.flush()
+			if b.spooled > 0 {
+				b.logger.Warn("Clickhouse batches left in spool", "dir", b.dir, "files", b.spooled)
+			}
+			return
+		case <-ticker.C:
+			b.replay()
+			b.flush()
+		case <-b.full:
+			b.flush()
+		}
+	}
+}
+
+func (b *clickhouseBatcher) flush() {
+	b.mu.Lock()
+	tables := b.tables
+	b.tables = make(map[string][]string)
+	b.mu.Unlock()
+
+	names := make([]string, 0, len(tables))
+	for table := range tables {
+		names = append(names, table)
+	}
+	sort.Strings(names)
+
+	for _, table := range names {
+		rows := tables[table]
+		body, err := gzipRows(rows)
+		if err != nil {
+			b.logger.Error("Failed to compress Clickhouse batch", "table", table, "rows", len(rows), "error", err)
+			continue
+		}
+
+		// keep the order behind spooled batches
+		if b.spooled > 0 {
+			b.spool(table, body)
+			continue
+		}
+
+		err = b.sendWithRetry(table, body)
+		switch {
+		case err == nil:
+		case errors.Is(err, errRejected):
+			b.logger.Warn("Clickhouse rejected batch, isolating bad rows", "table", table, "rows", len(rows), "error", err)
+			b.isolate(table, rows)
+		default:
+			b.logger.Warn("Clickhouse is unreachable, batch spooled", "table", table, "rows", len(rows), "error", err)
+			b.spool(table, body)
+		}
+	}
+}
+
+// isolate send halves of a rejected batch until the bad rows are found, only they are moved to rejected.
+// A half failing with other error is spooled with the rest of the batch
+func (b *clickhouseBatcher) isolate(table string, rows []string) {
+	if len(rows) == 1 {
+		body, err := gzipRows(rows)
+		if err != nil {
+			b.logger.Error("Failed to compress Clickhouse row", "table", table, "error", err)
+			return
+		}
+		b.logger.Error("Clickhouse rejected row", "table", table, "row", rows[0])
+		b.reject(table, body)
+		return
+	}
+
+	unreachable := false
+	mid := len(rows) / 2
+	for _, half := range [][]string{rows[:mid], rows[mid:]} {
+		body, err := gzipRows(half)
+		if err != nil {
+			b.logger.Error("Failed to compress Clickhouse batch", "table", table, "rows", len(half), "error", err)
+			continue
+		}
+		if unreachable {
+			b.spool(table, body)
+			continue
+		}
+
+		err = b.sendWithRetry(table, body)
+		switch {
+		case err == nil:
+		case errors.Is(err, errRejected):
+			b.isolate(table, half)
+		default:
+			b.logger.Warn("Clickhouse is unreachable, batch spooled", "table", table, "rows", len(half), "error", err)
+			b.spool(table, body)
+			unreachable = true
+		}
+	}
+}
+
+func (b *clickhouseBatcher) sendWithRetry(table string, body []byte) error {
+	backoff := retryBackoff
+	var err error
+	for attempt := 1; attempt <= b.maxRetries; attempt++ {
+		if err = b.send(context.Background(), table, body); err == nil || errors.Is(err, errRejected) {
+			return err
+		}
+		if attempt < b.maxRetries {
+			b.logger.Debug("Clickhouse insert failed, retry", "table", table, "attempt", attempt, "error", err)
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+	return err
+}
+
+// replay spooled batches in order, stops on the first failed one
+func (b *clickhouseBatcher) replay() {
+	if b.spooled == 0 {
+		return
+	}
+
+	files, err := b.spoolFiles()
+	if err != nil {
+		b.logger.Error("Failed to read spool", "dir", b.dir, "error", err)
+		return
+	}
+	b.spooled = len(files)
+
+	replayed := 0
+	for _, name := range files {
+		_, table, ok := parseSpoolName(name)
+		if !ok {
+			continue
+		}
+
+		path := filepath.Join(b.dir, name)
+		body, err := os.ReadFile(path)
+		if err != nil {
+			b.logger.Error("Failed to read spool file", "file", path, "error", err)
+			return
+		}
+
+		err = b.send(context.Background(), table, body)
+		if errors.Is(err, errRejected) {
+			b.logger.Warn("Clickhouse rejected spooled batch, isolating bad rows", "file", path, "error", err)
+			rows, err := gunzipRows(body)
+			if err != nil {
+				b.logger.Error("Failed to decompress spooled batch", "file", path, "error", err)
+				b.reject(table, body)
+			} else {
+				// halves failing to send are spooled after the current files
+				b.isolate(table, rows)
+			}
+		} else if err != nil {
+			b.logger.Debug("Clickhouse is unreachable, spool replay postponed", "files", len(files)-replayed, "error", err)
+			b.spooled -= replayed
+			return
+		}
+
+		if err := os.Remove(path); err != nil {
+			b.logger.Error("Failed to remove spool file", "file", path, "error", err)
+			return
+		}
+		replayed++
+	}
+
+	b.spooled -= replayed
+	b.logger.Info("Clickhouse spool replayed", "files", replayed)
+}
+
+// spool write batch to temp file and rename, replay never sees a partial batch
+func (b *clickhouseBatcher) spool(table string, body []byte) {
+	b.seq = max(b.seq+1, time.Now().UnixNano())
+	path := filepath.Join(b.dir, fmt.Sprintf("%020d_%s%s", b.seq, table, spoolExt))
+
+	if err := writeFileAtomic(path, body); err != nil {
+		b.logger.Error("Failed to spool Clickhouse batch, data lost", "table", table, "error", err)
+		return
+	}
+	b.spooled++
+}
+
+func (b *clickhouseBatcher) reject(table string, body []byte) {
+	b.seq = max(b.seq+1, time.Now().UnixNano())
+	path := filepath.Join(b.dir, rejectedDir, fmt.Sprintf("%020d_%s%s", b.seq, table, spoolExt))
+
+	if err := writeFileAtomic(path, body); err != nil {
+		b.logger.Error("Failed to save rejected Clickhouse batch", "table", table, "error", err)
+	}
+}
+
+// spoolFiles batch file names, oldest first
+func (b *clickhouseBatcher) spoolFiles() ([]string, error) {
+	entries, err := os.ReadDir(b.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spool dir: %w", err)
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && strings.HasSuffix(entry.Name(), spoolExt) {
+			files = append(files, entry.Name())
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// parseSpoolName "<seq>_<table>.jsonl.gz"
+func parseSpoolName(name string) (int64, string, bool) {
+	seqStr, table, ok := strings.Cut(strings.TrimSuffix(name, spoolExt), "_")
+	if !ok || table == "" {
+		return 0, "", false
+	}
+	seq, err := strconv.ParseInt(seqStr, 10, 64)
+	if err != nil {
+		return 0, "", false
+	}
+	return seq, table, true
+}
+
+func gzipRows(rows []string) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	for _, row := range rows {
+		zw.Write([]byte(row))
+		zw.Write([]byte{'\n'})
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func gunzipRows(body []byte) ([]string, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n"), nil
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,267 @@
+package storage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/config"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseSpoolName(t *testing.T) {
+	tests := []struct {
+		name      string
+		file      string
+		wantSeq   int64
+		wantTable string
+		wantOk    bool
+	}{
+		{name: "valid", file: "00000000000000000042_plog.jsonl.gz", wantSeq: 42, wantTable: "plog", wantOk: true},
+		{name: "table with underscore", file: "00000000000000000001_call_info.jsonl.gz", wantSeq: 1, wantTable: "call_info", wantOk: true},
+		{name: "max seq", file: "09223372036854775807_syslog.jsonl.gz", wantSeq: 1<<63 - 1, wantTable: "syslog", wantOk: true},
+		{name: "seq overflow", file: "09223372036854775808_syslog.jsonl.gz"},
+		{name: "no table", file: "00000000000000000001_.jsonl.gz"},
+		{name: "no separator", file: "00000000000000000001.jsonl.gz"},
+		{name: "not a number", file: "abc_plog.jsonl.gz"},
+		{name: "empty", file: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seq, table, ok := parseSpoolName(tt.file)
+			if ok != tt.wantOk || seq != tt.wantSeq || table != tt.wantTable {
+				t.Errorf("parseSpoolName(%q) = %d, %q, %v, want %d, %q, %v",
+					tt.file, seq, table, ok, tt.wantSeq, tt.wantTable, tt.wantOk)
+			}
+		})
+	}
+}
+
+// fakeClickhouse records inserted batches, fails while err is set, rejects batches with the bad row
+type fakeClickhouse struct {
+	err     error
+	bad     string
+	batches []string // "table: rows"
+}
+
+func (f *fakeClickhouse) send(ctx context.Context, table string, body []byte) error {
+	if f.err != nil {
+		return f.err
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	rows, err := io.ReadAll(zr)
+	if err != nil {
+		return err
+	}
+	if f.bad != "" && slices.Contains(strings.Split(string(rows), "\n"), f.bad) {
+		return fmt.Errorf("%w: Cannot parse input", errRejected)
+	}
+	f.batches = append(f.batches, table+": "+strings.TrimSpace(strings.ReplaceAll(string(rows), "\n", " ")))
+	return nil
+}
+
+func newTestBatcher(t *testing.T, dir string, ch *fakeClickhouse) *clickhouseBatcher {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	b, err := newClickhouseBatcher(logger, &config.ClickhouseBatchConfig{Size: 100, MaxRetries: 1, SpoolDir: dir}, ch.send)
+	if err != nil {
+		t.Fatalf("newClickhouseBatcher() error = %v", err)
+	}
+	return b
+}
+
+func TestClickhouseBatcherSpoolOrder(t *testing.T) {
+	dir := t.TempDir()
+	ch := &fakeClickhouse{err: errors.New("connection refused")}
+	b := newTestBatcher(t, dir, ch)
+
+	// Clickhouse is down, every flush is spooled
+	for i := 1; i <= 3; i++ {
+		b.add("syslog", fmt.Sprintf("s%d", i))
+		b.add("plog", fmt.Sprintf("p%d", i))
+		b.flush()
+	}
+	if b.spooled != 6 {
+		t.Fatalf("spooled = %d, want 6", b.spooled)
+	}
+
+	// still down, replay keeps everything
+	b.replay()
+	if b.spooled != 6 || len(ch.batches) != 0 {
+		t.Fatalf("spooled = %d, sent = %d, want 6 and 0", b.spooled, len(ch.batches))
+	}
+
+	// back online, a new batch waits behind the spool
+	ch.err = nil
+	b.add("syslog", "s4")
+	b.flush()
+	if len(ch.batches) != 0 {
+		t.Fatalf("batch sent before spool replay: %v", ch.batches)
+	}
+
+	b.replay()
+	want := []string{
+		"plog: p1", "syslog: s1",
+		"plog: p2", "syslog: s2",
+		"plog: p3", "syslog: s3",
+		"syslog: s4",
+	}
+	if !reflect.DeepEqual(ch.batches, want) {
+		t.Errorf("batches = %q, want %q", ch.batches, want)
+	}
+	if b.spooled != 0 {
+		t.Errorf("spooled = %d, want 0", b.spooled)
+	}
+
+	// spool is empty, the next batch is sent directly
+	b.add("plog", "p5")
+	b.flush()
+	if got := ch.batches[len(ch.batches)-1]; got != "plog: p5" {
+		t.Errorf("last batch = %q, want %q", got, "plog: p5")
+	}
+}
+
+func TestClickhouseBatcherSpoolRestart(t *testing.T) {
+	dir := t.TempDir()
+	ch := &fakeClickhouse{err: errors.New("connection refused")}
+
+	b := newTestBatcher(t, dir, ch)
+	b.add("plog", "p1")
+	b.flush()
+	b.add("plog", "p2")
+	b.flush()
+
+	// partial write of the previous run and unrelated files are ignored
+	os.WriteFile(filepath.Join(dir, "00000000000000000001_plog.jsonl.gz.tmp"), []byte("x"), 0o644)
+	os.WriteFile(filepath.Join(dir, "README"), []byte("x"), 0o644)
+
+	// the restarted batcher continues the sequence after the spooled files
+	ch.err = nil
+	b = newTestBatcher(t, dir, ch)
+	if b.spooled != 2 {
+		t.Fatalf("spooled after restart = %d, want 2", b.spooled)
+	}
+	b.add("plog", "p3")
+	b.flush()
+	b.replay()
+
+	want := []string{"plog: p1", "plog: p2", "plog: p3"}
+	if !reflect.DeepEqual(ch.batches, want) {
+		t.Errorf("batches = %q, want %q", ch.batches, want)
+	}
+}
+
+func TestClickhouseBatcherRejected(t *testing.T) {
+	dir := t.TempDir()
+	ch := &fakeClickhouse{err: fmt.Errorf("%w: Cannot parse input", errRejected)}
+	b := newTestBatcher(t, dir, ch)
+
+	b.add("plog", "bad")
+	b.flush()
+	if b.spooled != 0 {
+		t.Errorf("spooled = %d, want 0, rejected batch must not block the spool", b.spooled)
+	}
+
+	rejected, err := os.ReadDir(filepath.Join(dir, rejectedDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rejected) != 1 || !strings.HasSuffix(rejected[0].Name(), "_plog"+spoolExt) {
+		t.Errorf("rejected files = %v, want one plog batch", rejected)
+	}
+}
+
+// rejectedRows rows of the rejected files, oldest first
+func rejectedRows(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(filepath.Join(dir, rejectedDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rows []string
+	for _, entry := range entries {
+		body, err := os.ReadFile(filepath.Join(dir, rejectedDir, entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		fileRows, err := gunzipRows(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows = append(rows, fileRows...)
+	}
+	return rows
+}
+
+func TestClickhouseBatcherRejectedRow(t *testing.T) {
+	tests := []struct {
+		name   string
+		spool  bool // batch is spooled first and rejected on replay
+		rows   []string
+		wantOk []string
+	}{
+		{
+			name:   "flush",
+			rows:   []string{"p1", "p2", "bad", "p4", "p5"},
+			wantOk: []string{"p1", "p2", "p4", "p5"},
+		},
+		{
+			name:   "replay",
+			spool:  true,
+			rows:   []string{"p1", "p2", "p3", "p4", "p5", "p6", "bad"},
+			wantOk: []string{"p1", "p2", "p3", "p4", "p5", "p6"},
+		},
+		{
+			name:   "first row",
+			rows:   []string{"bad", "p2"},
+			wantOk: []string{"p2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			ch := &fakeClickhouse{bad: "bad"}
+			if tt.spool {
+				ch.err = errors.New("connection refused")
+			}
+			b := newTestBatcher(t, dir, ch)
+
+			for _, row := range tt.rows {
+				b.add("plog", row)
+			}
+			b.flush()
+			if tt.spool {
+				ch.err = nil
+				b.replay()
+			}
+
+			var sent []string
+			for _, batch := range ch.batches {
+				sent = append(sent, strings.Fields(strings.TrimPrefix(batch, "plog: "))...)
+			}
+			if !reflect.DeepEqual(sent, tt.wantOk) {
+				t.Errorf("sent rows = %q, want %q", sent, tt.wantOk)
+			}
+			if got := rejectedRows(t, dir); !reflect.DeepEqual(got, []string{"bad"}) {
+				t.Errorf("rejected rows = %q, want [bad]", got)
+			}
+			if b.spooled != 0 {
+				t.Errorf("spooled = %d, want 0", b.spooled)
+			}
+		})
+	}
+}
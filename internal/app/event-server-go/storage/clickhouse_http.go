@@ -5,9 +5,11 @@ import (
 	"context"
 	"fmt"
 	"github.com/kulakoff/event-server-go/internal/app/event-server-go/config"
+	"io"
 	"log/slog"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 )
 
@@ -15,11 +17,12 @@ type ClickhouseHttpClient struct {
 	logger     *slog.Logger
 	httpClient *http.Client
 	config     *config.ClickhouseConfig
+	batcher    *clickhouseBatcher
 }
 
 func NewClickhouseHttpClient(logger *slog.Logger, config *config.ClickhouseConfig) (*ClickhouseHttpClient, error) {
 	client := &http.Client{
-		Timeout: time.Second * 5,
+		Timeout: time.Second * 30,
 	}
 
 	chClient := &ClickhouseHttpClient{
@@ -28,6 +31,12 @@ func NewClickhouseHttpClient(logger *slog.Logger, config *config.ClickhouseConfi
 		config:     config,
 	}
 
+	batcher, err := newClickhouseBatcher(logger, config.Batch, chClient.insertBatch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to init Clickhouse batches: %w", err)
+	}
+	chClient.batcher = batcher
+
 	if err := chClient.Ping(); err != nil {
 		return nil, fmt.Errorf("failed to ping Clickhouse: %w", err)
 	}
@@ -37,34 +46,63 @@ func NewClickhouseHttpClient(logger *slog.Logger, config *config.ClickhouseConfi
 	return chClient, nil
 }
 
+// Start send batched inserts until ctx is done, the rest is flushed or spooled on return
+func (c *ClickhouseHttpClient) Start(ctx context.Context) error {
+	c.batcher.run(ctx)
+	return nil
+}
+
+// Insert queue JSONEachRow row, it is sent with the table batch by Start
 func (c *ClickhouseHttpClient) Insert(table, data string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
-	defer cancel()
+	c.batcher.add(table, data)
+	return nil
+}
+
+// InsertSyslog queue syslog rows
+func (c *ClickhouseHttpClient) InsertSyslog(ctx context.Context, rows []SyslogStorageMessage) error {
+	return queueRows(c.batcher, syslogTable, rows)
+}
+
+// InsertPlog queue plog rows
+func (c *ClickhouseHttpClient) InsertPlog(ctx context.Context, rows []PlogRow) error {
+	return queueRows(c.batcher, plogTable, rows)
+}
+
+// InsertSecurity queue security rows
+func (c *ClickhouseHttpClient) InsertSecurity(ctx context.Context, rows []SecurityEvent) error {
+	return queueRows(c.batcher, securityTable, rows)
+}
 
+// insertBatch send gzip JSONEachRow rows, errRejected if Clickhouse refused the data
+func (c *ClickhouseHttpClient) insertBatch(ctx context.Context, table string, body []byte) error {
 	clickhouseUrl := fmt.Sprintf("http://%s:%d", c.config.Host, c.config.Port)
 	query := fmt.Sprintf("INSERT INTO %s.%s FORMAT JSONEachRow", c.config.Database, table)
-	queryUrl := fmt.Sprintf("%s/?async_insert=1&wait_for_async_insert=0&query=%s", clickhouseUrl, url.QueryEscape(query))
-	req, err := http.NewRequestWithContext(ctx, "POST", queryUrl, bytes.NewBuffer([]byte(data)))
+	queryUrl := fmt.Sprintf("%s/?query=%s", clickhouseUrl, url.QueryEscape(query))
+	req, err := http.NewRequestWithContext(ctx, "POST", queryUrl, bytes.NewReader(body))
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.SetBasicAuth(c.config.Username, c.config.Password)
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		c.logger.Error("Failed to send request to Clickhouse", "error", err)
-		return err
+		return fmt.Errorf("failed to send request to Clickhouse: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		c.logger.Error("Clickhouse returned non-OK status", "status", resp.StatusCode)
-		return fmt.Errorf("non-OK HTTP status: %s", resp.Status)
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		if resp.StatusCode >= http.StatusBadRequest && resp.StatusCode < http.StatusInternalServerError &&
+			resp.StatusCode != http.StatusUnauthorized && resp.StatusCode != http.StatusForbidden &&
+			resp.StatusCode != http.StatusTooManyRequests {
+			return fmt.Errorf("%w: %s: %s", errRejected, resp.Status, bytes.TrimSpace(msg))
+		}
+		return fmt.Errorf("non-OK HTTP status: %s: %s", resp.Status, bytes.TrimSpace(msg))
 	}
 
-	//c.logger.Debug("Data inserted success to Clickhouse", "table", table)
 	return nil
 }
 
@@ -98,3 +136,91 @@ func (c *ClickhouseHttpClient) Ping() error {
 	c.logger.Info("Ping to Clickhouse successful")
 	return nil
 }
+
+// Select run query with {name:Type} parameters, returns JSONEachRow rows
+func (c *ClickhouseHttpClient) Select(ctx context.Context, query string, params map[string]string) ([]byte, error) {
+	clickhouseUrl := fmt.Sprintf("http://%s:%d", c.config.Host, c.config.Port)
+	values := url.Values{}
+	values.Set("database", c.config.Database)
+	values.Set("query", query+" FORMAT JSONEachRow")
+	for name, value := range params {
+		values.Set("param_"+name, value)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", clickhouseUrl+"/?"+values.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.SetBasicAuth(c.config.Username, c.config.Password)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request to Clickhouse: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Clickhouse response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("non-OK HTTP status: %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+
+	return body, nil
+}
+
+// Migrate apply embedded schema migrations
+func (c *ClickhouseHttpClient) Migrate(ctx context.Context) error {
+	return migrate(ctx, c.logger, c)
+}
+
+func (c *ClickhouseHttpClient) exec(ctx context.Context, query string) error {
+	return c.command(ctx, query, nil)
+}
+
+// command run query without result with {name:Type} parameters
+func (c *ClickhouseHttpClient) command(ctx context.Context, query string, params map[string]string) error {
+	clickhouseUrl := fmt.Sprintf("http://%s:%d", c.config.Host, c.config.Port)
+	values := url.Values{}
+	values.Set("database", c.config.Database)
+	for name, value := range params {
+		values.Set("param_"+name, value)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", clickhouseUrl+"/?"+values.Encode(), strings.NewReader(query))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.SetBasicAuth(c.config.Username, c.config.Password)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request to Clickhouse: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("non-OK HTTP status: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+func (c *ClickhouseHttpClient) appliedVersions(ctx context.Context) (map[int]bool, error) {
+	rows, err := selectRows[struct {
+		Version int `json:"version"`
+	}](ctx, c, "SELECT version FROM "+migrationsTable, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make(map[int]bool)
+	for _, row := range rows {
+		versions[row.Version] = true
+	}
+	return versions, nil
+}
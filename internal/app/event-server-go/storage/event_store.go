@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/config"
+	"io"
+	"log/slog"
+)
+
+const (
+	syslogTable = "syslog"
+	plogTable   = "plog"
+
+	ProtocolHTTP   = "http"
+	ProtocolNative = "native"
+)
+
+// EventStore Clickhouse events tables, rows are queued and inserted in batches by Start
+type EventStore interface {
+	InsertSyslog(ctx context.Context, rows []SyslogStorageMessage) error
+	InsertPlog(ctx context.Context, rows []PlogRow) error
+	InsertSecurity(ctx context.Context, rows []SecurityEvent) error
+	SecurityEvents(ctx context.Context, filter SecurityFilter) ([]SecurityEvent, error)
+	Start(ctx context.Context) error
+	Migrate(ctx context.Context) error
+}
+
+// PlogRow plog table row, object columns are JSON encoded
+type PlogRow struct {
+	Date      int64           `json:"date"`
+	EventUUID string          `json:"event_uuid"`
+	Hidden    int             `json:"hidden"`
+	ImageUUID string          `json:"image_uuid"`
+	FlatID    int             `json:"flat_id"`
+	Domophone json.RawMessage `json:"domophone"`
+	Event     int             `json:"event"`
+	Opened    int             `json:"opened"`
+	Face      json.RawMessage `json:"face"`
+	RFID      string          `json:"rfid"`
+	Code      string          `json:"code"`
+	Phones    json.RawMessage `json:"phones"`
+	Preview   int             `json:"preview"`
+	CallInfo  json.RawMessage `json:"call_info,omitempty"`
+}
+
+// NewEventStore HTTP JSONEachRow or native columnar inserts by clickhouse.protocol
+func NewEventStore(logger *slog.Logger, config *config.ClickhouseConfig) (EventStore, error) {
+	// typed nil clients are not returned as non-nil EventStore
+	switch config.Protocol {
+	case "", ProtocolHTTP:
+		client, err := NewClickhouseHttpClient(logger, config)
+		if err != nil {
+			return nil, err
+		}
+		return client, nil
+	case ProtocolNative:
+		client, err := NewClickhouse(logger, config)
+		if err != nil {
+			return nil, err
+		}
+		return client, nil
+	default:
+		return nil, fmt.Errorf("unknown Clickhouse protocol %q", config.Protocol)
+	}
+}
+
+// queueRows add JSON encoded rows to the table batch
+func queueRows[T any](batcher *clickhouseBatcher, table string, rows []T) error {
+	var errs []error
+	for _, row := range rows {
+		data, err := json.Marshal(row)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to marshal %s row: %w", table, err))
+			continue
+		}
+		batcher.add(table, string(data))
+	}
+	return errors.Join(errs...)
+}
+
+// decodeRows gzip JSONEachRow batch body to rows
+func decodeRows[T any](body []byte) ([]T, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read gzip batch: %w", err)
+	}
+	defer zr.Close()
+
+	var rows []T
+	decoder := json.NewDecoder(zr)
+	for {
+		var row T
+		if err := decoder.Decode(&row); err == io.EOF {
+			return rows, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to decode batch row: %w", err)
+		}
+		rows = append(rows, row)
+	}
+}
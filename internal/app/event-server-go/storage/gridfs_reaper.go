@@ -0,0 +1,146 @@
+package storage
+
+import (
+	"context"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/config"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"log/slog"
+	"time"
+)
+
+const (
+	defaultReaperInterval   = time.Hour
+	defaultReaperBatchSize  = 500
+	defaultReaperMaxBatches = 100
+
+	reaperBatchTimeout = time.Minute
+)
+
+// GridFSReaper delete camshots after metadata.expire in bounded batches, also from images.dir.
+// S3 camshots are expired by bucket lifecycle rules
+type GridFSReaper struct {
+	logger     *slog.Logger
+	files      *MongoHandler
+	images     *FSImageStore // optional
+	interval   time.Duration
+	batchSize  int
+	maxBatches int
+	dryRun     bool
+}
+
+func NewGridFSReaper(logger *slog.Logger, files *MongoHandler, images *FSImageStore, cfg *config.ReaperConfig) *GridFSReaper {
+	r := &GridFSReaper{
+		logger:     logger,
+		files:      files,
+		images:     images,
+		interval:   time.Duration(cfg.IntervalMin) * time.Minute,
+		batchSize:  cfg.BatchSize,
+		maxBatches: cfg.MaxBatches,
+		dryRun:     cfg.DryRun,
+	}
+	if r.interval <= 0 {
+		r.interval = defaultReaperInterval
+	}
+	if r.batchSize <= 0 {
+		r.batchSize = defaultReaperBatchSize
+	}
+	if r.maxBatches <= 0 {
+		r.maxBatches = defaultReaperMaxBatches
+	}
+	return r
+}
+
+// Start run on start and every interval until ctx is done
+func (r *GridFSReaper) Start(ctx context.Context) error {
+	if err := r.files.EnsureExpireIndex(ctx); err != nil {
+		r.logger.Warn("GridFS reaper", "error", err)
+	}
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		r.run(ctx)
+		r.runFS(ctx)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// run delete up to maxBatches batches of expired files, report only in dry run
+func (r *GridFSReaper) run(ctx context.Context) {
+	started := time.Now()
+
+	if r.dryRun {
+		files, bytes, err := r.files.ExpiredStats(ctx, started)
+		if err != nil {
+			r.logger.Warn("GridFS reaper", "error", err)
+			return
+		}
+		r.logger.Info("GridFS reaper dry run", "files", files, "bytes", bytes, "duration", time.Since(started))
+		return
+	}
+
+	var files, bytes int64
+	for batch := 0; batch < r.maxBatches && ctx.Err() == nil; batch++ {
+		n, size, err := r.reapBatch(ctx, started)
+		if err != nil {
+			r.logger.Warn("GridFS reaper", "error", err)
+			break
+		}
+		files += n
+		bytes += size
+		if n < int64(r.batchSize) {
+			break
+		}
+	}
+
+	if files > 0 {
+		r.logger.Info("GridFS reaper reclaimed", "files", files, "bytes", bytes, "duration", time.Since(started))
+	}
+}
+
+// runFS delete up to maxBatches * batchSize expired images.dir files, report only in dry run
+func (r *GridFSReaper) runFS(ctx context.Context) {
+	if r.images == nil {
+		return
+	}
+	started := time.Now()
+
+	files, bytes, err := r.images.ReapExpired(ctx, started, r.batchSize*r.maxBatches, r.dryRun)
+	if err != nil {
+		r.logger.Warn("FS images reaper", "error", err)
+	}
+
+	if r.dryRun {
+		r.logger.Info("FS images reaper dry run", "files", files, "bytes", bytes, "duration", time.Since(started))
+	} else if files > 0 {
+		r.logger.Info("FS images reaper reclaimed", "files", files, "bytes", bytes, "duration", time.Since(started))
+	}
+}
+
+func (r *GridFSReaper) reapBatch(ctx context.Context, before time.Time) (int64, int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, reaperBatchTimeout)
+	defer cancel()
+
+	expired, err := r.files.ExpiredFiles(ctx, before, r.batchSize)
+	if err != nil || len(expired) == 0 {
+		return 0, 0, err
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(expired))
+	var bytes int64
+	for _, file := range expired {
+		ids = append(ids, file.ID)
+		bytes += file.Length
+	}
+
+	if err := r.files.DeleteFiles(ctx, ids); err != nil {
+		return 0, 0, err
+	}
+	return int64(len(ids)), bytes, nil
+}
@@ -0,0 +1,217 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PlogFilter plog history query, zero fields are not filtered
+type PlogFilter struct {
+	FlatID        int
+	DomophoneID   int
+	EntranceID    int
+	HouseID       int
+	Events        []int
+	RFID          string
+	Code          string
+	From          time.Time
+	To            time.Time
+	IncludeHidden bool
+	Limit         int
+	Offset        int
+}
+
+// SyslogFilter syslog history query, zero fields are not filtered
+type SyslogFilter struct {
+	Ip     string
+	Unit   string
+	Text   string // case insensitive msg substring
+	From   time.Time
+	To     time.Time
+	Limit  int
+	Offset int
+}
+
+// plogSelectRow plog row as selected, object columns are JSON strings
+type plogSelectRow struct {
+	Date      int64  `json:"date"`
+	EventUUID string `json:"event_uuid"`
+	Hidden    int    `json:"hidden"`
+	ImageUUID string `json:"image_uuid"`
+	FlatID    int    `json:"flat_id"`
+	Domophone string `json:"domophone"`
+	Event     int    `json:"event"`
+	Opened    int    `json:"opened"`
+	Face      string `json:"face"`
+	RFID      string `json:"rfid"`
+	Code      string `json:"code"`
+	Phones    string `json:"phones"`
+	Preview   int    `json:"preview"`
+	CallInfo  string `json:"call_info"`
+}
+
+// PlogEvents plog rows by filter, newest first
+func (c *ClickhouseHttpClient) PlogEvents(ctx context.Context, filter PlogFilter) ([]PlogRow, error) {
+	where := []string{"date >= {from:UInt32}", "date < {to:UInt32}"}
+	params := map[string]string{
+		"from":   strconv.FormatInt(filter.From.Unix(), 10),
+		"to":     strconv.FormatInt(filter.To.Unix(), 10),
+		"limit":  strconv.Itoa(filter.Limit),
+		"offset": strconv.Itoa(filter.Offset),
+	}
+
+	if !filter.IncludeHidden {
+		where = append(where, "hidden = 0")
+	}
+	if filter.FlatID != 0 {
+		where = append(where, "flat_id = {flat_id:UInt32}")
+		params["flat_id"] = strconv.Itoa(filter.FlatID)
+	}
+	if filter.DomophoneID != 0 {
+		where = append(where, "JSONExtractUInt(domophone, 'domophone_id') = {domophone_id:UInt32}")
+		params["domophone_id"] = strconv.Itoa(filter.DomophoneID)
+	}
+	if filter.EntranceID != 0 {
+		where = append(where, "JSONExtractUInt(domophone, 'entrance_id') = {entrance_id:UInt32}")
+		params["entrance_id"] = strconv.Itoa(filter.EntranceID)
+	}
+	if filter.HouseID != 0 {
+		where = append(where, "JSONExtractUInt(domophone, 'house_id') = {house_id:UInt32}")
+		params["house_id"] = strconv.Itoa(filter.HouseID)
+	}
+	if len(filter.Events) > 0 {
+		events := make([]string, 0, len(filter.Events))
+		for _, event := range filter.Events {
+			events = append(events, strconv.Itoa(event))
+		}
+		where = append(where, "event IN {events:Array(UInt8)}")
+		params["events"] = "[" + strings.Join(events, ",") + "]"
+	}
+	if filter.RFID != "" {
+		where = append(where, "rfid = {rfid:String}")
+		params["rfid"] = filter.RFID
+	}
+	if filter.Code != "" {
+		where = append(where, "code = {code:String}")
+		params["code"] = filter.Code
+	}
+
+	query := "SELECT date, toString(event_uuid) AS event_uuid, hidden, toString(image_uuid) AS image_uuid, flat_id," +
+		" domophone, event, opened, face, rfid, code, phones, preview, call_info" +
+		" FROM " + plogTable +
+		" WHERE " + strings.Join(where, " AND ") +
+		" ORDER BY date DESC, event_uuid LIMIT {limit:UInt32} OFFSET {offset:UInt32}"
+
+	rows, err := selectRows[plogSelectRow](ctx, c, query, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select plog events: %w", err)
+	}
+
+	events := make([]PlogRow, 0, len(rows))
+	for _, row := range rows {
+		events = append(events, PlogRow{
+			Date:      row.Date,
+			EventUUID: row.EventUUID,
+			Hidden:    row.Hidden,
+			ImageUUID: row.ImageUUID,
+			FlatID:    row.FlatID,
+			Domophone: rawJSON(row.Domophone),
+			Event:     row.Event,
+			Opened:    row.Opened,
+			Face:      rawJSON(row.Face),
+			RFID:      row.RFID,
+			Code:      row.Code,
+			Phones:    rawJSON(row.Phones),
+			Preview:   row.Preview,
+			CallInfo:  rawJSON(row.CallInfo),
+		})
+	}
+	return events, nil
+}
+
+// SetPlogHidden hide or show plog event, the update is applied by Clickhouse asynchronously
+func (c *ClickhouseHttpClient) SetPlogHidden(ctx context.Context, eventUUID string, hidden bool) error {
+	value := "0"
+	if hidden {
+		value = "1"
+	}
+
+	query := "ALTER TABLE " + plogTable + " UPDATE hidden = {hidden:Int8} WHERE event_uuid = {event_uuid:UUID}"
+	if err := c.command(ctx, query, map[string]string{"hidden": value, "event_uuid": eventUUID}); err != nil {
+		return fmt.Errorf("failed to update plog hidden: %w", err)
+	}
+	return nil
+}
+
+// SyslogMessages syslog rows by filter, newest first
+func (c *ClickhouseHttpClient) SyslogMessages(ctx context.Context, filter SyslogFilter) ([]SyslogStorageMessage, error) {
+	where := []string{"date >= {from:UInt32}", "date < {to:UInt32}"}
+	params := map[string]string{
+		"from":   strconv.FormatInt(filter.From.Unix(), 10),
+		"to":     strconv.FormatInt(filter.To.Unix(), 10),
+		"limit":  strconv.Itoa(filter.Limit),
+		"offset": strconv.Itoa(filter.Offset),
+	}
+
+	if filter.Ip != "" {
+		where = append(where, "ip = {ip:String}")
+		params["ip"] = filter.Ip
+	}
+	if filter.Unit != "" {
+		where = append(where, "unit = {unit:String}")
+		params["unit"] = filter.Unit
+	}
+	if filter.Text != "" {
+		where = append(where, "positionCaseInsensitiveUTF8(msg, {text:String}) > 0")
+		params["text"] = filter.Text
+	}
+
+	query := "SELECT toString(date) AS date, ip, sub_id, unit, msg" +
+		" FROM " + syslogTable +
+		" WHERE " + strings.Join(where, " AND ") +
+		" ORDER BY date DESC LIMIT {limit:UInt32} OFFSET {offset:UInt32}"
+
+	messages, err := selectRows[SyslogStorageMessage](ctx, c, query, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select syslog messages: %w", err)
+	}
+	return messages, nil
+}
+
+// selectRows run Select and decode JSONEachRow rows
+func selectRows[T any](ctx context.Context, c *ClickhouseHttpClient, query string, params map[string]string) ([]T, error) {
+	body, err := c.Select(ctx, query, params)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []T
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var row T
+		if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+			return nil, fmt.Errorf("failed to parse row: %w", err)
+		}
+		rows = append(rows, row)
+	}
+	return rows, scanner.Err()
+}
+
+// rawJSON JSON string column value, nil if empty or invalid
+func rawJSON(value string) json.RawMessage {
+	if value == "" || !json.Valid([]byte(value)) {
+		return nil
+	}
+	return json.RawMessage(value)
+}
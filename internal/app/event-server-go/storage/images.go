@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/config"
+	"log/slog"
+	"time"
+)
+
+const (
+	ImagesGridFS = "gridfs"
+	ImagesFS     = "fs"
+	ImagesS3     = "s3"
+
+	camshotFilename = "camshot"
+)
+
+// ImageMeta camshot content type and expiry
+type ImageMeta struct {
+	ContentType string
+	Expire      time.Time // zero if the image does not expire
+}
+
+// ImageStore camshot blobs by 24 hex ObjectId, plog image_uuid is utils.ToGUIDv4 of the id
+type ImageStore interface {
+	SaveImage(ctx context.Context, image []byte, meta ImageMeta) (string, error)
+	OpenImage(ctx context.Context, id string) (*File, error)
+}
+
+// ImageStores write to one backend, read from all of them: images moved between backends keep their id
+type ImageStores struct {
+	primary ImageStore
+	stores  []ImageStore  // primary first
+	fs      *FSImageStore // nil without images.dir
+}
+
+// NewImageStore backend by images.backend, GridFS is used without images config
+func NewImageStore(logger *slog.Logger, cfg *config.ImagesConfig, mongo *MongoHandler) (*ImageStores, error) {
+	if cfg == nil {
+		cfg = &config.ImagesConfig{}
+	}
+
+	named := map[string]ImageStore{}
+	if mongo != nil {
+		named[ImagesGridFS] = mongo
+	}
+	var fsStore *FSImageStore
+	if cfg.Dir != "" {
+		var err error
+		fsStore, err = NewFSImageStore(cfg.Dir)
+		if err != nil {
+			return nil, err
+		}
+		named[ImagesFS] = fsStore
+	}
+	if cfg.S3 != nil && cfg.S3.Bucket != "" {
+		named[ImagesS3] = NewS3ImageStore(cfg.S3)
+	}
+
+	backend := cfg.Backend
+	if backend == "" {
+		backend = ImagesGridFS
+	}
+	primary, ok := named[backend]
+	if !ok {
+		return nil, fmt.Errorf("images backend %q is not configured", backend)
+	}
+
+	s := &ImageStores{primary: primary, stores: []ImageStore{primary}, fs: fsStore}
+	for _, name := range []string{ImagesGridFS, ImagesFS, ImagesS3} {
+		if store, ok := named[name]; ok && name != backend {
+			s.stores = append(s.stores, store)
+		}
+	}
+
+	logger.Info("Images storage", "backend", backend, "readers", len(s.stores))
+	return s, nil
+}
+
+func (s *ImageStores) SaveImage(ctx context.Context, image []byte, meta ImageMeta) (string, error) {
+	return s.primary.SaveImage(ctx, image, meta)
+}
+
+// FS images.dir backend, nil if not configured
+func (s *ImageStores) FS() *FSImageStore {
+	return s.fs
+}
+
+// OpenImage first backend having the image
+func (s *ImageStores) OpenImage(ctx context.Context, id string) (*File, error) {
+	var errs []error
+	for _, store := range s.stores {
+		file, err := store.OpenImage(ctx, id)
+		if err == nil {
+			return file, nil
+		}
+		if !errors.Is(err, ErrFileNotFound) {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return nil, ErrFileNotFound
+}
+
+// SaveImage GridFS "camshot" file, expire is unix time in metadata
+func (m *MongoHandler) SaveImage(ctx context.Context, image []byte, meta ImageMeta) (string, error) {
+	metadata := map[string]interface{}{
+		"contentType": meta.ContentType,
+	}
+	if !meta.Expire.IsZero() {
+		metadata["expire"] = int32(meta.Expire.Unix())
+	}
+	return m.SaveFile(camshotFilename, metadata, image)
+}
+
+func (m *MongoHandler) OpenImage(ctx context.Context, id string) (*File, error) {
+	return m.OpenFile(ctx, id)
+}
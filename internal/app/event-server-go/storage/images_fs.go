@@ -0,0 +1,163 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// fsImageMeta "<id>.json" next to the image
+type fsImageMeta struct {
+	ContentType string `json:"content_type"`
+	Expire      int64  `json:"expire,omitempty"`
+	UploadDate  int64  `json:"upload_date"`
+}
+
+// FSImageStore images in "<dir>/<id[22:24]>/<id[20:22]>/<id>", sharded by the ObjectId counter bytes
+type FSImageStore struct {
+	dir string
+}
+
+func NewFSImageStore(dir string) (*FSImageStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create images dir: %w", err)
+	}
+	return &FSImageStore{dir: dir}, nil
+}
+
+func (s *FSImageStore) SaveImage(ctx context.Context, image []byte, meta ImageMeta) (string, error) {
+	id := primitive.NewObjectID().Hex()
+	path := s.path(id)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", fmt.Errorf("failed to create image dir: %w", err)
+	}
+
+	data, err := json.Marshal(fsImageMeta{
+		ContentType: meta.ContentType,
+		Expire:      unixOrZero(meta.Expire),
+		UploadDate:  time.Now().Unix(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal image meta: %w", err)
+	}
+
+	// meta first, an image without meta is never visible
+	if err := writeFileAtomic(path+".json", data); err != nil {
+		return "", fmt.Errorf("failed to write image meta: %w", err)
+	}
+	if err := writeFileAtomic(path, image); err != nil {
+		os.Remove(path + ".json")
+		return "", fmt.Errorf("failed to write image: %w", err)
+	}
+	return id, nil
+}
+
+func (s *FSImageStore) OpenImage(ctx context.Context, id string) (*File, error) {
+	// ObjectId hex only, no path traversal
+	if _, err := primitive.ObjectIDFromHex(id); err != nil {
+		return nil, ErrFileNotFound
+	}
+	path := s.path(id)
+
+	data, err := os.ReadFile(path + ".json")
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrFileNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read image meta: %w", err)
+	}
+
+	var meta fsImageMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("failed to decode image meta: %w", err)
+	}
+
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrFileNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to open image: %w", err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to stat image: %w", err)
+	}
+
+	file := &File{
+		ID:          id,
+		Length:      info.Size(),
+		UploadDate:  time.Unix(meta.UploadDate, 0),
+		ContentType: meta.ContentType,
+		Reader:      f,
+	}
+	if meta.Expire != 0 {
+		file.Expire = time.Unix(meta.Expire, 0)
+	}
+	return file, nil
+}
+
+// ReapExpired delete up to limit images with meta expire before the time, dry run only counts them.
+// Shard dirs are walked and every "<id>.json" is read, the image is removed before its meta
+func (s *FSImageStore) ReapExpired(ctx context.Context, before time.Time, limit int, dryRun bool) (int64, int64, error) {
+	var files, bytes int64
+	err := filepath.WalkDir(s.dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if entry.IsDir() || !strings.HasSuffix(path, ".json") {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read image meta: %w", err)
+		}
+		var meta fsImageMeta
+		if err := json.Unmarshal(data, &meta); err != nil || meta.Expire == 0 || meta.Expire >= before.Unix() {
+			return nil
+		}
+
+		image := strings.TrimSuffix(path, ".json")
+		if info, err := os.Stat(image); err == nil {
+			bytes += info.Size()
+		}
+		files++
+
+		if !dryRun {
+			if err := os.Remove(image); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return fmt.Errorf("failed to delete image: %w", err)
+			}
+			if err := os.Remove(path); err != nil {
+				return fmt.Errorf("failed to delete image meta: %w", err)
+			}
+		}
+
+		if !dryRun && files >= int64(limit) {
+			return fs.SkipAll
+		}
+		return nil
+	})
+	return files, bytes, err
+}
+
+func (s *FSImageStore) path(id string) string {
+	return filepath.Join(s.dir, id[22:24], id[20:22], id)
+}
+
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
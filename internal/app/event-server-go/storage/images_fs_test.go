@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFSImageStoreReapExpired(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	s, err := NewFSImageStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	save := func(expire time.Time) string {
+		t.Helper()
+		id, err := s.SaveImage(ctx, []byte("jpeg"), ImageMeta{ContentType: "image/jpeg", Expire: expire})
+		if err != nil {
+			t.Fatalf("SaveImage() error = %v", err)
+		}
+		return id
+	}
+	expired := []string{save(now.Add(-2 * time.Hour)), save(now.Add(-time.Hour))}
+	kept := []string{save(now.Add(time.Hour)), save(time.Time{})}
+
+	// dry run only counts
+	files, bytes, err := s.ReapExpired(ctx, now, 100, true)
+	if err != nil || files != 2 || bytes != 8 {
+		t.Fatalf("ReapExpired() dry run = %d, %d, %v, want 2, 8, nil", files, bytes, err)
+	}
+	for _, id := range expired {
+		file, err := s.OpenImage(ctx, id)
+		if err != nil {
+			t.Errorf("OpenImage(%s) after dry run error = %v", id, err)
+			continue
+		}
+		file.Reader.Close()
+	}
+
+	// limit stops the walk
+	if files, _, err := s.ReapExpired(ctx, now, 1, false); err != nil || files != 1 {
+		t.Fatalf("ReapExpired() with limit 1 = %d, %v, want 1, nil", files, err)
+	}
+	if files, _, err := s.ReapExpired(ctx, now, 100, false); err != nil || files != 1 {
+		t.Fatalf("ReapExpired() = %d, %v, want 1, nil", files, err)
+	}
+
+	for _, id := range expired {
+		if _, err := s.OpenImage(ctx, id); !errors.Is(err, ErrFileNotFound) {
+			t.Errorf("OpenImage(%s) of expired image error = %v, want ErrFileNotFound", id, err)
+		}
+	}
+	for _, id := range kept {
+		file, err := s.OpenImage(ctx, id)
+		if err != nil {
+			t.Errorf("OpenImage(%s) of kept image error = %v", id, err)
+			continue
+		}
+		file.Reader.Close()
+	}
+}
@@ -0,0 +1,208 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/config"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultS3Region = "us-east-1"
+	s3Timeout       = 30 * time.Second
+
+	s3MetaExpire = "X-Amz-Meta-Expire"
+)
+
+// S3ImageStore images in S3 compatible bucket (MinIO), path-style requests signed with AWS Signature V4
+type S3ImageStore struct {
+	httpClient *http.Client
+	endpoint   string
+	region     string
+	bucket     string
+	prefix     string
+	accessKey  string
+	secretKey  string
+}
+
+func NewS3ImageStore(cfg *config.S3Config) *S3ImageStore {
+	region := cfg.Region
+	if region == "" {
+		region = defaultS3Region
+	}
+	return &S3ImageStore{
+		httpClient: &http.Client{Timeout: s3Timeout},
+		endpoint:   strings.TrimSuffix(cfg.Endpoint, "/"),
+		region:     region,
+		bucket:     cfg.Bucket,
+		prefix:     cfg.Prefix,
+		accessKey:  cfg.AccessKey,
+		secretKey:  cfg.SecretKey,
+	}
+}
+
+func (s *S3ImageStore) SaveImage(ctx context.Context, image []byte, meta ImageMeta) (string, error) {
+	id := primitive.NewObjectID().Hex()
+
+	header := http.Header{}
+	header.Set("Content-Type", meta.ContentType)
+	if !meta.Expire.IsZero() {
+		header.Set(s3MetaExpire, strconv.FormatInt(meta.Expire.Unix(), 10))
+	}
+
+	req, err := s.newRequest(ctx, http.MethodPut, id, image, header)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to put image to S3: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("S3 put non-OK HTTP status: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return id, nil
+}
+
+func (s *S3ImageStore) OpenImage(ctx context.Context, id string) (*File, error) {
+	if _, err := primitive.ObjectIDFromHex(id); err != nil {
+		return nil, ErrFileNotFound
+	}
+
+	req, err := s.newRequest(ctx, http.MethodGet, id, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get image from S3: %w", err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, ErrFileNotFound
+	default:
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		return nil, fmt.Errorf("S3 get non-OK HTTP status: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+
+	file := &File{
+		ID:          id,
+		Length:      resp.ContentLength,
+		ContentType: resp.Header.Get("Content-Type"),
+		Reader:      resp.Body,
+	}
+	if modified, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		file.UploadDate = modified
+	}
+	if expire, err := strconv.ParseInt(resp.Header.Get(s3MetaExpire), 10, 64); err == nil && expire != 0 {
+		file.Expire = time.Unix(expire, 0)
+	}
+	return file, nil
+}
+
+// newRequest object request with the headers, signed with AWS Signature V4
+func (s *S3ImageStore) newRequest(ctx context.Context, method, id string, body []byte, header http.Header) (*http.Request, error) {
+	path := "/" + s.bucket + "/" + s.prefix + id
+	req, err := http.NewRequestWithContext(ctx, method, s.endpoint+s3Escape(path), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 request: %w", err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	s.sign(req, sha256Hex(body), time.Now().UTC())
+	return req, nil
+}
+
+// sign set Authorization of AWS Signature V4, host and every request header are signed:
+// S3 rejects x-amz-* headers missing from SignedHeaders
+func (s *S3ImageStore) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		s3Escape(req.URL.Path),
+		"",
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), date)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, scope, signedHeaders, signature))
+}
+
+// s3Escape URI encode path, only unreserved characters and "/" are kept
+func s3Escape(path string) string {
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '.' || c == '_' || c == '~' || c == '/' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"log/slog"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const migrationsTable = "schema_migrations"
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migration one "<version>_<name>.sql" file, statements are separated by ";"
+type migration struct {
+	version    int
+	name       string
+	statements []string
+}
+
+// migrator Clickhouse connection of the migrations
+type migrator interface {
+	exec(ctx context.Context, query string) error
+	appliedVersions(ctx context.Context) (map[int]bool, error)
+}
+
+// migrate apply not applied migrations in version order and record them to schema_migrations
+func migrate(ctx context.Context, logger *slog.Logger, m migrator) error {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return err
+	}
+
+	query := "CREATE TABLE IF NOT EXISTS " + migrationsTable +
+		" (`version` UInt32, `name` String, `applied_at` DateTime DEFAULT now())" +
+		" ENGINE = MergeTree ORDER BY version"
+	if err := m.exec(ctx, query); err != nil {
+		return fmt.Errorf("failed to create %s: %w", migrationsTable, err)
+	}
+
+	applied, err := m.appliedVersions(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get applied migrations: %w", err)
+	}
+
+	count := 0
+	for _, mg := range migrations {
+		if applied[mg.version] {
+			continue
+		}
+
+		logger.Info("Applying Clickhouse migration", "version", mg.version, "name", mg.name)
+		for _, statement := range mg.statements {
+			if err := m.exec(ctx, statement); err != nil {
+				return fmt.Errorf("migration %d %s failed: %w", mg.version, mg.name, err)
+			}
+		}
+
+		query := fmt.Sprintf("INSERT INTO %s (version, name) VALUES (%d, '%s')", migrationsTable, mg.version, mg.name)
+		if err := m.exec(ctx, query); err != nil {
+			return fmt.Errorf("failed to record migration %d: %w", mg.version, err)
+		}
+		count++
+	}
+
+	logger.Info("Clickhouse schema is up to date", "applied", count, "version", migrations[len(migrations)-1].version)
+	return nil
+}
+
+func loadMigrations() ([]migration, error) {
+	entries, err := migrationFiles.ReadDir("migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	var migrations []migration
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), ".sql")
+		versionStr, _, ok := strings.Cut(name, "_")
+		if !ok {
+			return nil, fmt.Errorf("invalid migration name %q", entry.Name())
+		}
+		version, err := strconv.Atoi(versionStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version %q: %w", entry.Name(), err)
+		}
+
+		data, err := migrationFiles.ReadFile(path.Join("migrations", entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %q: %w", entry.Name(), err)
+		}
+
+		migrations = append(migrations, migration{
+			version:    version,
+			name:       name,
+			statements: splitStatements(string(data)),
+		})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].version < migrations[j].version })
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].version == migrations[i-1].version {
+			return nil, fmt.Errorf("duplicate migration version %d", migrations[i].version)
+		}
+	}
+	return migrations, nil
+}
+
+// splitStatements one statement per Clickhouse request, "--" comment lines are dropped
+func splitStatements(sql string) []string {
+	var lines []string
+	for _, line := range strings.Split(sql, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "--") {
+			lines = append(lines, line)
+		}
+	}
+
+	var statements []string
+	for _, statement := range strings.Split(strings.Join(lines, "\n"), ";") {
+		if statement = strings.TrimSpace(statement); statement != "" {
+			statements = append(statements, statement)
+		}
+	}
+	return statements
+}
@@ -0,0 +1,16 @@
+-- raw panel syslog messages
+CREATE TABLE IF NOT EXISTS syslog
+(
+    `date`   UInt32,
+    `ip`     String, -- IPv4 or IPv6, dual-stack listeners
+    `sub_id` String,
+    `unit`   String,
+    `msg`    String,
+    INDEX syslog_ip ip TYPE set(100) GRANULARITY 1024,
+    INDEX syslog_unit unit TYPE set(100) GRANULARITY 1024
+)
+ENGINE = MergeTree
+PARTITION BY toYYYYMMDD(FROM_UNIXTIME(date))
+ORDER BY date
+TTL FROM_UNIXTIME(date) + toIntervalDay(31)
+SETTINGS index_granularity = 1024;
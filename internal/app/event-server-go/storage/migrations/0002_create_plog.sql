@@ -0,0 +1,26 @@
+-- door open and call events, domophone, face and phones are JSON objects
+CREATE TABLE IF NOT EXISTS plog
+(
+    `date`       UInt32,
+    `event_uuid` UUID,
+    `hidden`     Int8,
+    `image_uuid` UUID,
+    `flat_id`    UInt32,
+    `domophone`  String,
+    `event`      UInt8,
+    `opened`     UInt8,
+    `face`       String,
+    `rfid`       String,
+    `code`       String,
+    `phones`     String,
+    `preview`    UInt8,
+    INDEX plog_date date TYPE set(100) GRANULARITY 1024,
+    INDEX plog_event_uuid event_uuid TYPE set(100) GRANULARITY 1024,
+    INDEX plog_hidden hidden TYPE set(100) GRANULARITY 1024,
+    INDEX plog_flat_id flat_id TYPE set(100) GRANULARITY 1024
+)
+ENGINE = MergeTree
+PARTITION BY toYYYYMMDD(FROM_UNIXTIME(date))
+ORDER BY date
+TTL FROM_UNIXTIME(date) + toIntervalMonth(6)
+SETTINGS index_granularity = 1024;
@@ -0,0 +1,2 @@
+-- final call event details: call_id, apartment, answered, duration ...
+ALTER TABLE plog ADD COLUMN IF NOT EXISTS `call_info` String DEFAULT '';
@@ -0,0 +1,17 @@
+-- panel break in and tamper alarms
+CREATE TABLE IF NOT EXISTS security
+(
+    `date`         UInt32,
+    `event_uuid`   UUID,
+    `type`         String,
+    `domophone_id` UInt32,
+    `entrance_id`  UInt32,
+    `house_id`     UInt32,
+    `ip`           String,
+    `unit`         String,
+    `msg`          String
+)
+ENGINE = MergeTree
+PARTITION BY toYYYYMM(FROM_UNIXTIME(date))
+ORDER BY (domophone_id, date)
+TTL FROM_UNIXTIME(date) + toIntervalMonth(12);
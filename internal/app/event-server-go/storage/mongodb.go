@@ -2,16 +2,32 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/kulakoff/event-server-go/internal/app/event-server-go/config"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/gridfs"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"io"
 	"log/slog"
 	"time"
 )
 
+// ErrFileNotFound no GridFS file with the id
+var ErrFileNotFound = errors.New("file not found")
+
+// File GridFS file with metadata, Reader must be closed
+type File struct {
+	ID          string
+	Length      int64
+	UploadDate  time.Time
+	ContentType string    // metadata.contentType, empty if not set
+	Expire      time.Time // metadata.expire, zero if not set
+	Reader      io.ReadCloser
+}
+
 type MongoHandler struct {
 	logger *slog.Logger
 	client *mongo.Client
@@ -64,3 +80,143 @@ func (m *MongoHandler) SaveFile(filename string, metadata map[string]interface{}
 
 	return fileIdHex, nil
 }
+
+// OpenFile open GridFS file by ObjectId hex
+func (m *MongoHandler) OpenFile(ctx context.Context, fileID string) (*File, error) {
+	oid, err := primitive.ObjectIDFromHex(fileID)
+	if err != nil {
+		return nil, ErrFileNotFound
+	}
+
+	bucket, err := gridfs.NewBucket(m.db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GRIDFS bucket: %w", err)
+	}
+
+	cursor, err := bucket.FindContext(ctx, bson.M{"_id": oid})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find file: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	if !cursor.Next(ctx) {
+		if err := cursor.Err(); err != nil {
+			return nil, fmt.Errorf("failed to find file: %w", err)
+		}
+		return nil, ErrFileNotFound
+	}
+
+	var doc struct {
+		Length     int64     `bson:"length"`
+		UploadDate time.Time `bson:"uploadDate"`
+		Metadata   bson.M    `bson:"metadata"`
+	}
+	if err := cursor.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to decode file: %w", err)
+	}
+
+	stream, err := bucket.OpenDownloadStream(oid)
+	if errors.Is(err, gridfs.ErrFileNotFound) {
+		return nil, ErrFileNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to open download stream: %w", err)
+	}
+
+	file := &File{
+		ID:         fileID,
+		Length:     doc.Length,
+		UploadDate: doc.UploadDate,
+		Reader:     stream,
+	}
+	if contentType, ok := doc.Metadata["contentType"].(string); ok {
+		file.ContentType = contentType
+	}
+	if expire := metadataUnix(doc.Metadata["expire"]); expire != 0 {
+		file.Expire = time.Unix(expire, 0)
+	}
+	return file, nil
+}
+
+// metadataUnix unix time saved as int32, int64 or double
+func metadataUnix(value any) int64 {
+	switch v := value.(type) {
+	case int32:
+		return int64(v)
+	case int64:
+		return v
+	case float64:
+		return int64(v)
+	case primitive.DateTime:
+		return v.Time().Unix()
+	}
+	return 0
+}
+
+// ExpiredFile GridFS file after metadata.expire
+type ExpiredFile struct {
+	ID     primitive.ObjectID `bson:"_id"`
+	Length int64              `bson:"length"`
+}
+
+// EnsureExpireIndex fs.files index of the expired files lookup
+func (m *MongoHandler) EnsureExpireIndex(ctx context.Context) error {
+	_, err := m.db.Collection("fs.files").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "metadata.expire", Value: 1}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create metadata.expire index: %w", err)
+	}
+	return nil
+}
+
+// ExpiredFiles up to limit files expired before the time
+func (m *MongoHandler) ExpiredFiles(ctx context.Context, before time.Time, limit int) ([]ExpiredFile, error) {
+	findOptions := options.Find().
+		SetProjection(bson.M{"_id": 1, "length": 1}).
+		SetSort(bson.M{"metadata.expire": 1}).
+		SetLimit(int64(limit))
+	cursor, err := m.db.Collection("fs.files").Find(ctx, bson.M{"metadata.expire": bson.M{"$lt": before.Unix()}}, findOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find expired files: %w", err)
+	}
+
+	var files []ExpiredFile
+	if err := cursor.All(ctx, &files); err != nil {
+		return nil, fmt.Errorf("failed to decode expired files: %w", err)
+	}
+	return files, nil
+}
+
+// ExpiredStats count and total length of files expired before the time
+func (m *MongoHandler) ExpiredStats(ctx context.Context, before time.Time) (int64, int64, error) {
+	cursor, err := m.db.Collection("fs.files").Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"metadata.expire": bson.M{"$lt": before.Unix()}}}},
+		{{Key: "$group", Value: bson.M{"_id": nil, "files": bson.M{"$sum": 1}, "bytes": bson.M{"$sum": "$length"}}}},
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to count expired files: %w", err)
+	}
+
+	var stats []struct {
+		Files int64 `bson:"files"`
+		Bytes int64 `bson:"bytes"`
+	}
+	if err := cursor.All(ctx, &stats); err != nil {
+		return 0, 0, fmt.Errorf("failed to decode expired files stats: %w", err)
+	}
+	if len(stats) == 0 {
+		return 0, 0, nil
+	}
+	return stats[0].Files, stats[0].Bytes, nil
+}
+
+// DeleteFiles delete chunks, then files, a file left without chunks is deleted by the next call
+func (m *MongoHandler) DeleteFiles(ctx context.Context, ids []primitive.ObjectID) error {
+	if _, err := m.db.Collection("fs.chunks").DeleteMany(ctx, bson.M{"files_id": bson.M{"$in": ids}}); err != nil {
+		return fmt.Errorf("failed to delete chunks: %w", err)
+	}
+	if _, err := m.db.Collection("fs.files").DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}}); err != nil {
+		return fmt.Errorf("failed to delete files: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const securityTable = "security"
+
+// SecurityEvent panel security alert: break in, tamper
+type SecurityEvent struct {
+	Date        int64  `json:"date"`
+	EventUUID   string `json:"event_uuid"`
+	Type        string `json:"type"`
+	DomophoneID int    `json:"domophone_id"`
+	EntranceID  int    `json:"entrance_id"`
+	HouseID     int    `json:"house_id"`
+	Ip          string `json:"ip"`
+	Unit        string `json:"unit"`
+	Msg         string `json:"msg"`
+}
+
+// SecurityFilter security events query, zero fields are not filtered
+type SecurityFilter struct {
+	DomophoneID int
+	Type        string
+	From        time.Time
+	To          time.Time
+	Limit       int
+	Offset      int
+}
+
+const securitySelect = "SELECT date, toString(event_uuid) AS event_uuid, type, domophone_id, entrance_id, house_id, ip, unit, msg" +
+	" FROM " + securityTable
+
+// SecurityEvents security events by filter, newest first
+func (c *ClickhouseHttpClient) SecurityEvents(ctx context.Context, filter SecurityFilter) ([]SecurityEvent, error) {
+	where := []string{"date >= {from:UInt32}", "date < {to:UInt32}"}
+	params := map[string]string{
+		"from":   strconv.FormatInt(filter.From.Unix(), 10),
+		"to":     strconv.FormatInt(filter.To.Unix(), 10),
+		"limit":  strconv.Itoa(filter.Limit),
+		"offset": strconv.Itoa(filter.Offset),
+	}
+
+	if filter.DomophoneID != 0 {
+		where = append(where, "domophone_id = {domophone_id:UInt32}")
+		params["domophone_id"] = strconv.Itoa(filter.DomophoneID)
+	}
+	if filter.Type != "" {
+		where = append(where, "type = {type:String}")
+		params["type"] = filter.Type
+	}
+
+	query := securitySelect +
+		" WHERE " + strings.Join(where, " AND ") +
+		" ORDER BY date DESC, event_uuid LIMIT {limit:UInt32} OFFSET {offset:UInt32}"
+
+	events, err := selectRows[SecurityEvent](ctx, c, query, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select security events: %w", err)
+	}
+	return events, nil
+}
+
+// SecurityEvents security events by filter, newest first
+func (c *ClikhouseHandler) SecurityEvents(ctx context.Context, filter SecurityFilter) ([]SecurityEvent, error) {
+	where := []string{"date >= ?", "date < ?"}
+	args := []any{uint32(filter.From.Unix()), uint32(filter.To.Unix())}
+
+	if filter.DomophoneID != 0 {
+		where = append(where, "domophone_id = ?")
+		args = append(args, uint32(filter.DomophoneID))
+	}
+	if filter.Type != "" {
+		where = append(where, "type = ?")
+		args = append(args, filter.Type)
+	}
+
+	query := securitySelect +
+		" WHERE " + strings.Join(where, " AND ") +
+		" ORDER BY date DESC, event_uuid LIMIT ? OFFSET ?"
+	args = append(args, uint32(filter.Limit), uint32(filter.Offset))
+
+	rows, err := c.clickhouse.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select security events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []SecurityEvent
+	for rows.Next() {
+		var date, domophoneID, entranceID, houseID uint32
+		var event SecurityEvent
+		if err := rows.Scan(&date, &event.EventUUID, &event.Type, &domophoneID, &entranceID, &houseID,
+			&event.Ip, &event.Unit, &event.Msg); err != nil {
+			return nil, fmt.Errorf("failed to scan security event: %w", err)
+		}
+		event.Date = int64(date)
+		event.DomophoneID = int(domophoneID)
+		event.EntranceID = int(entranceID)
+		event.HouseID = int(houseID)
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to select security events: %w", err)
+	}
+	return events, nil
+}
@@ -0,0 +1,341 @@
+package syslog_custom
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// message formats, SyslogMessage.Format
+const (
+	FormatRFC5424 = "rfc5424"
+	FormatBSD     = "BSD" // RFC 3164
+	FormatRubetek = "rubetek"
+	FormatUfanet  = "ufanet"
+)
+
+const (
+	nilValue = "-"
+
+	// bsdFutureTolerance max clock skew before a BSD timestamp is moved to the previous year
+	bsdFutureTolerance = 24 * time.Hour
+)
+
+var (
+	// <PRI>VERSION TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA [MSG]
+	rfc5424Regex = regexp.MustCompile(`^<(\d{1,3})>(\d{1,2}) (\S+) (\S{1,255}) (\S{1,48}) (\S{1,128}) (\S{1,32}) ?(.*)$`)
+
+	// <PRI>Mmm dd hh:mm:ss [HOSTNAME] [TAG[PID]:] MSG
+	bsdRegex = regexp.MustCompile(`^<(\d{1,3})>([A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2})(?:\.\d+)?(?:\s+(.*))?$`)
+
+	// TAG[PID]: in BSD message
+	bsdTagRegex = regexp.MustCompile(`^([^\s\[\]:]{1,48})(?:\[([^\]\s]{1,128})\])?:$`)
+
+	// Rubetek: <PRI>TIMESTAMP HOSTNAME APP-NAME MSG
+	rubetekRegex = regexp.MustCompile(`^<(\d{1,3})>(\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(?:\.\d+)?(?:Z|[+-]\d{2}:\d{2})) (\S+) (\S+) ?(.*)$`)
+
+	// Ufanet: [<PRI>]... TAG: MSG
+	ufanetRegex = regexp.MustCompile(`^(?:<(\d{1,3})>)?(.*?)\s*([^\s:]+): (.*)$`)
+)
+
+// Facility syslog facility from priority
+func (m *SyslogMessage) Facility() int {
+	return m.Priority / 8
+}
+
+// Severity syslog severity from priority
+func (m *SyslogMessage) Severity() int {
+	return m.Priority % 8
+}
+
+// ParseMessage parse RFC 5424, RFC 3164 (BSD) and vendor specific messages
+func (s *SyslogServer) ParseMessage(rawMessage string) (*SyslogMessage, error) {
+	return ParseMessage(rawMessage, s.unit, time.Now())
+}
+
+// ParseMessage parse raw syslog message received at "received" by the "unit" panel server.
+// The receive time is used for messages without timestamp and for BSD year inference.
+func ParseMessage(rawMessage, unit string, received time.Time) (*SyslogMessage, error) {
+	rawMessage = strings.TrimSpace(rawMessage)
+
+	if matches := rfc5424Regex.FindStringSubmatch(rawMessage); matches != nil {
+		if message, err := parseRFC5424(matches, received); err == nil {
+			return message, nil
+		}
+	}
+
+	if matches := rubetekRegex.FindStringSubmatch(rawMessage); matches != nil {
+		if message, err := parseRubetek(matches); err == nil {
+			return message, nil
+		}
+	}
+
+	if matches := bsdRegex.FindStringSubmatch(rawMessage); matches != nil {
+		if message, err := parseBSD(matches, received); err == nil {
+			return message, nil
+		}
+	}
+
+	if strings.EqualFold(unit, FormatUfanet) {
+		if matches := ufanetRegex.FindStringSubmatch(rawMessage); matches != nil {
+			return parseUfanet(matches, received), nil
+		}
+	}
+
+	return nil, fmt.Errorf("ParseMessage, unsupported message format: %s", rawMessage)
+}
+
+func parseRFC5424(matches []string, received time.Time) (*SyslogMessage, error) {
+	priority, err := parsePriority(matches[1])
+	if err != nil {
+		return nil, err
+	}
+
+	version, _ := strconv.Atoi(matches[2])
+
+	timestamp := received
+	if matches[3] != nilValue {
+		timestamp, err = time.Parse(time.RFC3339Nano, matches[3])
+		if err != nil {
+			return nil, fmt.Errorf("invalid timestamp: %w", err)
+		}
+	}
+
+	structuredData, message, err := splitStructuredData(matches[8])
+	if err != nil {
+		return nil, err
+	}
+
+	sdParams, err := parseStructuredData(structuredData)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SyslogMessage{
+		Format:         FormatRFC5424,
+		Priority:       priority,
+		Version:        version,
+		Timestamp:      timestamp,
+		HostName:       nilToEmpty(matches[4]),
+		AppName:        nilToEmpty(matches[5]),
+		ProcID:         nilToEmpty(matches[6]),
+		MsgID:          nilToEmpty(matches[7]),
+		StructuredData: nilToEmpty(structuredData),
+		SDParams:       sdParams,
+		Message:        strings.TrimPrefix(message, "\ufeff"), // UTF-8 BOM
+	}, nil
+}
+
+func parseRubetek(matches []string) (*SyslogMessage, error) {
+	priority, err := parsePriority(matches[1])
+	if err != nil {
+		return nil, err
+	}
+
+	timestamp, err := time.Parse(time.RFC3339Nano, matches[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid timestamp: %w", err)
+	}
+
+	return &SyslogMessage{
+		Format:    FormatRubetek,
+		Priority:  priority,
+		Timestamp: timestamp,
+		HostName:  matches[3],
+		AppName:   matches[4],
+		Message:   matches[5],
+	}, nil
+}
+
+func parseBSD(matches []string, received time.Time) (*SyslogMessage, error) {
+	priority, err := parsePriority(matches[1])
+	if err != nil {
+		return nil, err
+	}
+
+	timestamp, err := parseBSDTimestamp(matches[2], received)
+	if err != nil {
+		return nil, err
+	}
+
+	message := &SyslogMessage{
+		Format:    FormatBSD,
+		Priority:  priority,
+		Timestamp: timestamp,
+	}
+
+	// [HOSTNAME] [TAG[PID]:] MSG
+	rest := matches[3]
+	first, tail, _ := strings.Cut(rest, " ")
+	if tag := bsdTagRegex.FindStringSubmatch(first); tag != nil {
+		// no hostname
+		message.AppName, message.ProcID = tag[1], tag[2]
+		message.Message = strings.TrimSpace(tail)
+		return message, nil
+	}
+
+	message.HostName = first
+	second, msg, _ := strings.Cut(tail, " ")
+	if tag := bsdTagRegex.FindStringSubmatch(second); tag != nil {
+		message.AppName, message.ProcID = tag[1], tag[2]
+		message.Message = strings.TrimSpace(msg)
+	} else {
+		message.Message = strings.TrimSpace(tail)
+	}
+
+	return message, nil
+}
+
+func parseUfanet(matches []string, received time.Time) *SyslogMessage {
+	// missing priority is user.notice
+	priority := 13
+	if matches[1] != "" {
+		if p, err := parsePriority(matches[1]); err == nil {
+			priority = p
+		}
+	}
+
+	return &SyslogMessage{
+		Format:    FormatUfanet,
+		Priority:  priority,
+		Timestamp: received,
+		AppName:   matches[3],
+		Message:   matches[4],
+	}
+}
+
+// parseBSDTimestamp "Mmm dd hh:mm:ss" has no year and zone: use the local zone and the
+// receive year, messages too far in the future belong to the previous year (sent on Dec 31, received on Jan 1)
+func parseBSDTimestamp(value string, received time.Time) (time.Time, error) {
+	local := received.In(time.Local)
+
+	timestamp, err := time.ParseInLocation("Jan _2 15:04:05 2006", value+" "+strconv.Itoa(local.Year()), time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid BSD timestamp: %w", err)
+	}
+
+	if timestamp.Sub(local) > bsdFutureTolerance {
+		timestamp = timestamp.AddDate(-1, 0, 0)
+	}
+
+	return timestamp, nil
+}
+
+func parsePriority(value string) (int, error) {
+	priority, err := strconv.Atoi(value)
+	if err != nil || priority > 191 {
+		return 0, fmt.Errorf("invalid priority: %s", value)
+	}
+	return priority, nil
+}
+
+// splitStructuredData split "STRUCTURED-DATA [MSG]" part of RFC 5424 message
+func splitStructuredData(value string) (string, string, error) {
+	if value == nilValue {
+		return nilValue, "", nil
+	}
+	if strings.HasPrefix(value, nilValue+" ") {
+		return nilValue, value[len(nilValue)+1:], nil
+	}
+
+	if !strings.HasPrefix(value, "[") {
+		return "", "", fmt.Errorf("invalid structured data")
+	}
+
+	inQuotes := false
+	escaped := false
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case escaped:
+			escaped = false
+		case c == '\\' && inQuotes:
+			escaped = true
+		case c == '"':
+			inQuotes = !inQuotes
+		case c == ']' && !inQuotes:
+			// next element or end of structured data
+			if i+1 == len(value) {
+				return value, "", nil
+			}
+			if value[i+1] == ' ' {
+				return value[:i+1], value[i+2:], nil
+			}
+		}
+	}
+
+	return "", "", fmt.Errorf("unterminated structured data")
+}
+
+// parseStructuredData parse SD-ELEMENTs: [id param="value" ...][id2 ...]
+func parseStructuredData(value string) (map[string]map[string]string, error) {
+	if value == nilValue || value == "" {
+		return nil, nil
+	}
+
+	elements := make(map[string]map[string]string)
+	for len(value) > 0 {
+		if value[0] != '[' {
+			return nil, fmt.Errorf("invalid structured data element")
+		}
+		value = value[1:]
+
+		// SD-ID
+		end := strings.IndexAny(value, " ]")
+		if end <= 0 {
+			return nil, fmt.Errorf("invalid structured data id")
+		}
+		params := make(map[string]string)
+		elements[value[:end]] = params
+		value = value[end:]
+
+		// SD-PARAMs
+		for len(value) > 0 && value[0] == ' ' {
+			value = value[1:]
+
+			eq := strings.Index(value, "=\"")
+			if eq <= 0 {
+				return nil, fmt.Errorf("invalid structured data param")
+			}
+			name := value[:eq]
+			value = value[eq+2:]
+
+			var param strings.Builder
+			closed := false
+			for i := 0; i < len(value); i++ {
+				c := value[i]
+				if c == '\\' && i+1 < len(value) && strings.IndexByte(`"\]`, value[i+1]) != -1 {
+					param.WriteByte(value[i+1])
+					i++
+					continue
+				}
+				if c == '"' {
+					value = value[i+1:]
+					closed = true
+					break
+				}
+				param.WriteByte(c)
+			}
+			if !closed {
+				return nil, fmt.Errorf("unterminated structured data param %s", name)
+			}
+			params[name] = param.String()
+		}
+
+		if len(value) == 0 || value[0] != ']' {
+			return nil, fmt.Errorf("unterminated structured data element")
+		}
+		value = value[1:]
+	}
+
+	return elements, nil
+}
+
+func nilToEmpty(value string) string {
+	if value == nilValue {
+		return ""
+	}
+	return value
+}
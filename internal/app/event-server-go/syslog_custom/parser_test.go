@@ -0,0 +1,332 @@
+package syslog_custom
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseMessage(t *testing.T) {
+	received := time.Date(2024, time.March, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		raw     string
+		unit    string
+		want    *SyslogMessage
+		wantErr bool
+	}{
+		{
+			name: "rfc5424 full",
+			raw:  `<165>1 2024-03-10T11:59:58.123Z panel app 42 ID47 [exampleSDID@32473 iut="3" eventSource="Application"] Opening door by RFID 00000075BC01AD, apartment 0`,
+			want: &SyslogMessage{
+				Format:         FormatRFC5424,
+				Priority:       165,
+				Version:        1,
+				Timestamp:      time.Date(2024, time.March, 10, 11, 59, 58, 123000000, time.UTC),
+				HostName:       "panel",
+				AppName:        "app",
+				ProcID:         "42",
+				MsgID:          "ID47",
+				StructuredData: `[exampleSDID@32473 iut="3" eventSource="Application"]`,
+				SDParams:       map[string]map[string]string{"exampleSDID@32473": {"iut": "3", "eventSource": "Application"}},
+				Message:        "Opening door by RFID 00000075BC01AD, apartment 0",
+			},
+		},
+		{
+			name: "rfc5424 nil values and BOM",
+			raw:  "<13>1 - - - - - - \ufeffhello",
+			want: &SyslogMessage{
+				Format:    FormatRFC5424,
+				Priority:  13,
+				Version:   1,
+				Timestamp: received,
+				Message:   "hello",
+			},
+		},
+		{
+			name: "rfc5424 without message",
+			raw:  "<13>1 2024-03-10T11:00:00+03:00 host app - - -",
+			want: &SyslogMessage{
+				Format:    FormatRFC5424,
+				Priority:  13,
+				Version:   1,
+				Timestamp: time.Date(2024, time.March, 10, 8, 0, 0, 0, time.UTC),
+				HostName:  "host",
+				AppName:   "app",
+			},
+		},
+		{
+			name: "rfc5424 escaped structured data and several elements",
+			raw:  `<13>1 - host app - - [a x="q\"uo\]te\\"][b] msg`,
+			want: &SyslogMessage{
+				Format:         FormatRFC5424,
+				Priority:       13,
+				Version:        1,
+				Timestamp:      received,
+				HostName:       "host",
+				AppName:        "app",
+				StructuredData: `[a x="q\"uo\]te\\"][b]`,
+				SDParams:       map[string]map[string]string{"a": {"x": `q"uo]te\`}, "b": {}},
+				Message:        "msg",
+			},
+		},
+		{
+			name:    "rfc5424 unterminated structured data",
+			raw:     `<13>1 - host app - - [a x="1" msg`,
+			wantErr: true,
+		},
+		{
+			name:    "rfc5424 invalid timestamp",
+			raw:     "<13>1 yesterday host app - - - msg",
+			wantErr: true,
+		},
+		{
+			name:    "priority over 191",
+			raw:     "<192>1 - host app - - - msg",
+			wantErr: true,
+		},
+		{
+			name: "bsd with hostname and tag",
+			raw:  "<30>Mar  9 10:00:00 panel beward[123]: Opening door by code 1234",
+			want: &SyslogMessage{
+				Format:    FormatBSD,
+				Priority:  30,
+				Timestamp: time.Date(2024, time.March, 9, 10, 0, 0, 0, time.Local),
+				HostName:  "panel",
+				AppName:   "beward",
+				ProcID:    "123",
+				Message:   "Opening door by code 1234",
+			},
+		},
+		{
+			name: "bsd tag without hostname",
+			raw:  "<30>Mar 10 10:00:00.512 sipd: SIP call 1 is DISCONNECTED",
+			want: &SyslogMessage{
+				Format:    FormatBSD,
+				Priority:  30,
+				Timestamp: time.Date(2024, time.March, 10, 10, 0, 0, 0, time.Local),
+				AppName:   "sipd",
+				Message:   "SIP call 1 is DISCONNECTED",
+			},
+		},
+		{
+			name: "bsd hostname without tag",
+			raw:  "<30>Mar 10 10:00:00 panel Main door opened",
+			want: &SyslogMessage{
+				Format:    FormatBSD,
+				Priority:  30,
+				Timestamp: time.Date(2024, time.March, 10, 10, 0, 0, 0, time.Local),
+				HostName:  "panel",
+				Message:   "Main door opened",
+			},
+		},
+		{
+			name: "bsd timestamp only",
+			raw:  "<30>Mar 10 10:00:00",
+			want: &SyslogMessage{
+				Format:    FormatBSD,
+				Priority:  30,
+				Timestamp: time.Date(2024, time.March, 10, 10, 0, 0, 0, time.Local),
+			},
+		},
+		{
+			name:    "bsd invalid date",
+			raw:     "<30>Feb 31 10:00:00 panel msg",
+			wantErr: true,
+		},
+		{
+			name: "rubetek",
+			raw:  "<14>2024-03-10T11:59:59.5+03:00 rubetek-panel intercom Door opened by key 0600C9F5C9",
+			want: &SyslogMessage{
+				Format:    FormatRubetek,
+				Priority:  14,
+				Timestamp: time.Date(2024, time.March, 10, 8, 59, 59, 500000000, time.UTC),
+				HostName:  "rubetek-panel",
+				AppName:   "intercom",
+				Message:   "Door opened by key 0600C9F5C9",
+			},
+		},
+		{
+			name: "rubetek without message",
+			raw:  "<14>2024-03-10T11:59:59Z rubetek-panel intercom",
+			want: &SyslogMessage{
+				Format:    FormatRubetek,
+				Priority:  14,
+				Timestamp: time.Date(2024, time.March, 10, 11, 59, 59, 0, time.UTC),
+				HostName:  "rubetek-panel",
+				AppName:   "intercom",
+			},
+		},
+		{
+			name: "ufanet without priority",
+			raw:  "door: key key=0600C9F5C9 relay=1",
+			unit: "Ufanet",
+			want: &SyslogMessage{
+				Format:    FormatUfanet,
+				Priority:  13,
+				Timestamp: received,
+				AppName:   "door",
+				Message:   "key key=0600C9F5C9 relay=1",
+			},
+		},
+		{
+			name: "ufanet with priority and prefix",
+			raw:  "<11>uptime 1234 call: finished",
+			unit: "ufanet",
+			want: &SyslogMessage{
+				Format:    FormatUfanet,
+				Priority:  11,
+				Timestamp: received,
+				AppName:   "call",
+				Message:   "finished",
+			},
+		},
+		{
+			name:    "ufanet format for other units",
+			raw:     "door: key key=0600C9F5C9 relay=1",
+			unit:    "beward",
+			wantErr: true,
+		},
+		{
+			name:    "empty",
+			raw:     "",
+			wantErr: true,
+		},
+		{
+			name:    "whitespace",
+			raw:     " \r\n",
+			wantErr: true,
+		},
+		{
+			name:    "no priority",
+			raw:     "1 2024-03-10T11:59:58Z host app - - - msg",
+			wantErr: true,
+		},
+		{
+			name:    "unterminated priority",
+			raw:     "<13 Mar 10 10:00:00 msg",
+			wantErr: true,
+		},
+		{
+			name:    "priority too long",
+			raw:     "<1234>Mar 10 10:00:00 msg",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseMessage(tt.raw, tt.unit, received)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseMessage() = %+v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseMessage() error = %v", err)
+			}
+			if !got.Timestamp.Equal(tt.want.Timestamp) {
+				t.Errorf("Timestamp = %v, want %v", got.Timestamp, tt.want.Timestamp)
+			}
+			got.Timestamp, tt.want.Timestamp = time.Time{}, time.Time{}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseMessage() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMessageLength(t *testing.T) {
+	received := time.Date(2024, time.March, 10, 12, 0, 0, 0, time.UTC)
+
+	long := strings.Repeat("x", maxMessageSize)
+	got, err := ParseMessage("<13>1 - host app - - - "+long, "", received)
+	if err != nil {
+		t.Fatalf("ParseMessage() error = %v", err)
+	}
+	if got.Message != long {
+		t.Errorf("Message length = %d, want %d", len(got.Message), len(long))
+	}
+
+	// APP-NAME is limited to 48 characters
+	if _, err := ParseMessage("<13>1 - host "+strings.Repeat("a", 49)+" - - - msg", "", received); err == nil {
+		t.Error("ParseMessage() with 49 characters APP-NAME, want error")
+	}
+	if _, err := ParseMessage("<13>1 - host "+strings.Repeat("a", 48)+" - - - msg", "", received); err != nil {
+		t.Errorf("ParseMessage() with 48 characters APP-NAME error = %v", err)
+	}
+}
+
+func TestParseBSDTimestamp(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		received time.Time
+		want     time.Time
+		wantErr  bool
+	}{
+		{
+			name:     "same year",
+			value:    "Mar 10 10:00:00",
+			received: time.Date(2024, time.March, 10, 10, 0, 5, 0, time.Local),
+			want:     time.Date(2024, time.March, 10, 10, 0, 0, 0, time.Local),
+		},
+		{
+			name:     "sent on Dec 31, received on Jan 1",
+			value:    "Dec 31 23:59:58",
+			received: time.Date(2025, time.January, 1, 0, 0, 1, 0, time.Local),
+			want:     time.Date(2024, time.December, 31, 23, 59, 58, 0, time.Local),
+		},
+		{
+			name:     "panel clock ahead within tolerance",
+			value:    "Mar 10 20:00:00",
+			received: time.Date(2024, time.March, 10, 10, 0, 0, 0, time.Local),
+			want:     time.Date(2024, time.March, 10, 20, 0, 0, 0, time.Local),
+		},
+		{
+			name:     "leap day",
+			value:    "Feb 29 10:00:00",
+			received: time.Date(2024, time.March, 1, 0, 0, 0, 0, time.Local),
+			want:     time.Date(2024, time.February, 29, 10, 0, 0, 0, time.Local),
+		},
+		{
+			name:     "single digit day",
+			value:    "Jan  2 03:04:05",
+			received: time.Date(2024, time.January, 2, 3, 4, 5, 0, time.Local),
+			want:     time.Date(2024, time.January, 2, 3, 4, 5, 0, time.Local),
+		},
+		{
+			name:     "unknown month",
+			value:    "Foo 10 10:00:00",
+			received: time.Date(2024, time.March, 10, 10, 0, 0, 0, time.Local),
+			wantErr:  true,
+		},
+		{
+			name:     "hour out of range",
+			value:    "Mar 10 25:00:00",
+			received: time.Date(2024, time.March, 10, 10, 0, 0, 0, time.Local),
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseBSDTimestamp(tt.value, tt.received)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseBSDTimestamp() = %v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseBSDTimestamp() error = %v", err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("parseBSDTimestamp() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package syslog_custom
+
+import (
+	"context"
+	"expvar"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/capture"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultWorkers   = 8
+	defaultQueueSize = 4096
+
+	// shedWatermark queue fill ratio after which spam-filtered messages are dropped before queueing
+	shedWatermark = 0.5
+
+	// statsInterval queue metrics log interval
+	statsInterval = time.Minute
+)
+
+// queuedMessage raw message waiting for a worker
+type queuedMessage struct {
+	srcIP    string
+	raw      string
+	received time.Time
+}
+
+// QueueStats syslog server queue metrics
+type QueueStats struct {
+	Unit         string  `json:"unit"`
+	Workers      int     `json:"workers"`
+	QueueSize    int     `json:"queue_size"`
+	QueueDepth   int     `json:"queue_depth"`
+	Received     uint64  `json:"received"`
+	Processed    uint64  `json:"processed"`
+	DroppedSpam  uint64  `json:"dropped_spam"` // shed under load
+	DroppedFull  uint64  `json:"dropped_full"` // queue overflow
+	HandlerAvgMs float64 `json:"handler_avg_ms"`
+	HandlerMaxMs float64 `json:"handler_max_ms"` // since the last stats log
+}
+
+// messageQueue bounded queues between listeners and handler workers.
+// Messages from one source IP always go to the same worker, so they are handled in order.
+type messageQueue struct {
+	shards []chan queuedMessage
+
+	received    atomic.Uint64
+	processed   atomic.Uint64
+	droppedSpam atomic.Uint64
+	droppedFull atomic.Uint64
+	handlerNs   atomic.Int64
+	handlerMax  atomic.Int64
+}
+
+func newMessageQueue(workers, queueSize int) *messageQueue {
+	if workers <= 0 {
+		workers = defaultWorkers
+	}
+	if queueSize <= 0 {
+		queueSize = defaultQueueSize
+	}
+
+	shardSize := queueSize / workers
+	if shardSize < 1 {
+		shardSize = 1
+	}
+
+	q := &messageQueue{shards: make([]chan queuedMessage, workers)}
+	for i := range q.shards {
+		q.shards[i] = make(chan queuedMessage, shardSize)
+	}
+	return q
+}
+
+func (q *messageQueue) shard(srcIP string) chan queuedMessage {
+	h := fnv.New32a()
+	h.Write([]byte(srcIP))
+	return q.shards[h.Sum32()%uint32(len(q.shards))]
+}
+
+func (q *messageQueue) depth() int {
+	depth := 0
+	for _, shard := range q.shards {
+		depth += len(shard)
+	}
+	return depth
+}
+
+// enqueueMessage queue raw message without blocking the listener
+func (s *SyslogServer) enqueueMessage(srcIP, message, transport string) {
+	q := s.queue
+	q.received.Add(1)
+
+	received := time.Now()
+	if s.capture != nil {
+		s.capture.Write(capture.Record{
+			Time:      received,
+			SrcIP:     srcIP,
+			Unit:      s.unit,
+			Port:      s.port,
+			Transport: transport,
+			Message:   message,
+		})
+	}
+
+	shard := q.shard(srcIP)
+
+	// under load shed spam first, the handler skips it anyway
+	if float64(len(shard)) >= float64(cap(shard))*shedWatermark && s.handler.FilterMessage(message) {
+		q.droppedSpam.Add(1)
+		return
+	}
+
+	select {
+	case shard <- queuedMessage{srcIP: srcIP, raw: message, received: received}:
+	default:
+		if q.droppedFull.Add(1)%100 == 1 {
+			s.logger.Warn("Syslog queue is full, dropping messages", "unit", s.unit, "ip", srcIP, "dropped", q.droppedFull.Load())
+		}
+	}
+}
+
+// startWorkers run one worker per queue shard
+func (s *SyslogServer) startWorkers(wg *sync.WaitGroup) {
+	for _, shard := range s.queue.shards {
+		wg.Add(1)
+		go func(shard chan queuedMessage) {
+			defer wg.Done()
+			for msg := range shard {
+				s.processMessage(msg)
+			}
+		}(shard)
+	}
+}
+
+// stopWorkers close queues, workers finish already queued messages
+func (s *SyslogServer) stopWorkers() {
+	for _, shard := range s.queue.shards {
+		close(shard)
+	}
+}
+
+func (s *SyslogServer) processMessage(msg queuedMessage) {
+	startTime := time.Now()
+
+	parsedMessage, err := ParseMessage(msg.raw, s.unit, msg.received)
+	if err != nil {
+		s.logger.Warn("Error parsing message", "error", err)
+	} else {
+		s.handler.HandleMessage(msg.srcIP, parsedMessage)
+	}
+
+	duration := time.Since(startTime).Nanoseconds()
+	q := s.queue
+	q.processed.Add(1)
+	q.handlerNs.Add(duration)
+	for {
+		current := q.handlerMax.Load()
+		if duration <= current || q.handlerMax.CompareAndSwap(current, duration) {
+			break
+		}
+	}
+}
+
+// Stats current queue metrics
+func (s *SyslogServer) Stats() QueueStats {
+	q := s.queue
+	processed := q.processed.Load()
+
+	stats := QueueStats{
+		Unit:         s.unit,
+		Workers:      len(q.shards),
+		QueueSize:    len(q.shards) * cap(q.shards[0]),
+		QueueDepth:   q.depth(),
+		Received:     q.received.Load(),
+		Processed:    processed,
+		DroppedSpam:  q.droppedSpam.Load(),
+		DroppedFull:  q.droppedFull.Load(),
+		HandlerMaxMs: float64(q.handlerMax.Load()) / float64(time.Millisecond),
+	}
+	if processed > 0 {
+		stats.HandlerAvgMs = float64(q.handlerNs.Load()) / float64(processed) / float64(time.Millisecond)
+	}
+
+	return stats
+}
+
+// publishStats expose queue metrics in expvar, served on the API /debug/vars, and log them periodically
+func (s *SyslogServer) publishStats(ctx context.Context) {
+	name := "syslog_" + s.unit
+	if expvar.Get(name) == nil {
+		expvar.Publish(name, expvar.Func(func() any { return s.Stats() }))
+	}
+
+	ticker := time.NewTicker(statsInterval)
+	defer ticker.Stop()
+
+	var lastReceived uint64
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			stats := s.Stats()
+			s.queue.handlerMax.Store(0)
+
+			if stats.Received == lastReceived {
+				continue
+			}
+			lastReceived = stats.Received
+
+			s.logger.Info("Syslog queue stats",
+				"unit", stats.Unit,
+				"queueDepth", stats.QueueDepth,
+				"queueSize", stats.QueueSize,
+				"received", stats.Received,
+				"processed", stats.Processed,
+				"droppedSpam", stats.DroppedSpam,
+				"droppedFull", stats.DroppedFull,
+				"handlerAvgMs", stats.HandlerAvgMs,
+				"handlerMaxMs", stats.HandlerMaxMs)
+		}
+	}
+}
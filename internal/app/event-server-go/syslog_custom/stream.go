@@ -0,0 +1,229 @@
+package syslog_custom
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/config"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// streamIdleTimeout close a TCP/TLS session without messages
+	streamIdleTimeout = 10 * time.Minute
+
+	// maxFrameLenDigits MSG-LEN digits limit for octet-counting framing
+	maxFrameLenDigits = 6
+)
+
+// listenStream bind TCP (RFC 6587) or TLS (RFC 5425) syslog listener
+func (s *SyslogServer) listenStream(transport string) (*listener, error) {
+	port := s.port
+	if transport == TransportTLS {
+		if s.tls == nil {
+			return nil, fmt.Errorf("tls transport enabled without tls config")
+		}
+		if s.tls.Port != 0 {
+			port = s.tls.Port
+		}
+
+		tlsConfig, err := newTLSConfig(s.tls)
+		if err != nil {
+			return nil, err
+		}
+		stream, err := tls.Listen("tcp", fmt.Sprintf(":%d", port), tlsConfig)
+		if err != nil {
+			return nil, err
+		}
+		return &listener{transport: transport, port: port, stream: stream}, nil
+	}
+
+	stream, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return nil, err
+	}
+	return &listener{transport: transport, port: port, stream: stream}, nil
+}
+
+// acceptStream serve TCP/TLS sessions until ctx is canceled
+func (s *SyslogServer) acceptStream(ctx context.Context, listener net.Listener, transport string) error {
+	// unblock Accept on shutdown
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	var sessions sync.WaitGroup
+	defer sessions.Wait()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				s.logger.Info("🛑 Shutting down syslog server", "unit", s.unit, "transport", transport)
+				return nil
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return err
+			}
+
+			s.logger.Warn("Error accepting connection", "unit", s.unit, "transport", transport, "error", err)
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+
+		sessions.Add(1)
+		go func() {
+			defer sessions.Done()
+			s.serveStream(ctx, conn, transport)
+		}()
+	}
+}
+
+// serveStream read framed messages from one TCP/TLS session
+func (s *SyslogServer) serveStream(ctx context.Context, conn net.Conn, transport string) {
+	done := make(chan struct{})
+	defer close(done)
+	defer conn.Close()
+
+	// close session on shutdown
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	srcIP := conn.RemoteAddr().String()
+	if host, _, err := net.SplitHostPort(srcIP); err == nil {
+		srcIP = host
+	}
+
+	s.logger.Debug("Syslog session opened", "unit", s.unit, "transport", transport, "ip", srcIP)
+
+	reader := bufio.NewReader(conn)
+	for {
+		if err := conn.SetReadDeadline(time.Now().Add(streamIdleTimeout)); err != nil {
+			return
+		}
+
+		frame, err := readFrame(reader)
+		if len(frame) > 0 {
+			s.enqueueMessage(srcIP, string(frame), transport)
+		}
+		if err != nil {
+			if ctx.Err() == nil && !errors.Is(err, io.EOF) {
+				s.logger.Warn("Error reading syslog session", "unit", s.unit, "transport", transport, "ip", srcIP, "error", err)
+			}
+			s.logger.Debug("Syslog session closed", "unit", s.unit, "transport", transport, "ip", srcIP)
+			return
+		}
+	}
+}
+
+// readFrame read one message using RFC 6587 framing:
+// octet-counting "MSG-LEN SP SYSLOG-MSG" if the frame starts with a digit,
+// non-transparent framing otherwise
+func readFrame(reader *bufio.Reader) ([]byte, error) {
+	first, err := reader.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+
+	if first[0] >= '1' && first[0] <= '9' {
+		return readOctetCountingFrame(reader)
+	}
+
+	return readNonTransparentFrame(reader)
+}
+
+func readOctetCountingFrame(reader *bufio.Reader) ([]byte, error) {
+	var lenDigits []byte
+	for {
+		b, err := reader.ReadByte()
+		if errors.Is(err, io.EOF) {
+			// closed inside the frame header
+			return nil, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, err
+		}
+		if b == ' ' {
+			break
+		}
+		if b < '0' || b > '9' || len(lenDigits) == maxFrameLenDigits {
+			return nil, fmt.Errorf("invalid octet-counting frame length")
+		}
+		lenDigits = append(lenDigits, b)
+	}
+
+	msgLen, err := strconv.Atoi(string(lenDigits))
+	if err != nil || msgLen > maxMessageSize {
+		return nil, fmt.Errorf("invalid octet-counting frame length: %s", lenDigits)
+	}
+
+	frame := make([]byte, msgLen)
+	if _, err := io.ReadFull(reader, frame); err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimRight(frame, "\r\n\x00"), nil
+}
+
+func readNonTransparentFrame(reader *bufio.Reader) ([]byte, error) {
+	var frame []byte
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return bytes.TrimRight(frame, "\r"), err
+		}
+		// LF or NUL trailer
+		if b == '\n' || b == 0 {
+			return bytes.TrimRight(frame, "\r"), nil
+		}
+		if len(frame) == maxMessageSize {
+			return nil, fmt.Errorf("message exceeds %d bytes", maxMessageSize)
+		}
+		frame = append(frame, b)
+	}
+}
+
+// newTLSConfig server certificate and optional client CA
+func newTLSConfig(tlsConfig *config.TLSConfig) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(tlsConfig.CertFile, tlsConfig.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS key pair: %w", err)
+	}
+
+	serverConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if tlsConfig.ClientCA != "" {
+		caPEM, err := os.ReadFile(tlsConfig.ClientCA)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates found in client CA %s", tlsConfig.ClientCA)
+		}
+
+		serverConfig.ClientCAs = pool
+		serverConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return serverConfig, nil
+}
@@ -0,0 +1,138 @@
+package syslog_custom
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestReadFrame(t *testing.T) {
+	long := strings.Repeat("x", maxMessageSize)
+
+	tests := []struct {
+		name    string
+		input   string
+		want    []string
+		wantErr bool // error other than io.EOF after the frames
+	}{
+		{
+			name:  "octet counting",
+			input: "5 hello11 hello world",
+			want:  []string{"hello", "hello world"},
+		},
+		{
+			name:  "octet counting with trailer",
+			input: "7 hello\r\n3 abc",
+			want:  []string{"hello", "abc"},
+		},
+		{
+			name:  "octet counting max length",
+			input: strconv.Itoa(maxMessageSize) + " " + long,
+			want:  []string{long},
+		},
+		{
+			name:    "octet counting over max length",
+			input:   strconv.Itoa(maxMessageSize+1) + " " + long + "x",
+			wantErr: true,
+		},
+		{
+			name:    "octet counting too many digits",
+			input:   "1234567 x",
+			wantErr: true,
+		},
+		{
+			name:    "octet counting invalid length",
+			input:   "12a hello",
+			wantErr: true,
+		},
+		{
+			name:    "octet counting truncated",
+			input:   "10 short",
+			wantErr: true,
+		},
+		{
+			name:    "octet counting without space",
+			input:   "10",
+			wantErr: true,
+		},
+		{
+			name:  "non-transparent LF",
+			input: "<13>hello\n<13>world\n",
+			want:  []string{"<13>hello", "<13>world"},
+		},
+		{
+			name:  "non-transparent CRLF and NUL",
+			input: "<13>hello\r\n<13>world\x00",
+			want:  []string{"<13>hello", "<13>world"},
+		},
+		{
+			name:  "non-transparent last frame without trailer",
+			input: "<13>hello\n<13>world",
+			want:  []string{"<13>hello", "<13>world"},
+		},
+		{
+			name:  "non-transparent empty lines",
+			input: "\n\n<13>hello\n",
+			want:  []string{"", "", "<13>hello"},
+		},
+		{
+			name:  "non-transparent starting with zero",
+			input: "0 hello\n",
+			want:  []string{"0 hello"},
+		},
+		{
+			name:  "non-transparent max length",
+			input: long + "\n",
+			want:  []string{long},
+		},
+		{
+			name:    "non-transparent over max length",
+			input:   long + "x\n",
+			wantErr: true,
+		},
+		{
+			name:  "mixed framing",
+			input: "5 hello<13>world\n3 abc",
+			want:  []string{"hello", "<13>world", "abc"},
+		},
+		{
+			name:  "empty",
+			input: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := bufio.NewReader(strings.NewReader(tt.input))
+
+			var got []string
+			var err error
+			for {
+				var frame []byte
+				frame, err = readFrame(reader)
+				if err != nil {
+					if len(frame) > 0 {
+						got = append(got, string(frame))
+					}
+					break
+				}
+				got = append(got, string(frame))
+			}
+
+			if tt.wantErr {
+				if err == nil || errors.Is(err, io.EOF) {
+					t.Fatalf("readFrame() error = %v, want framing error", err)
+				}
+			} else if !errors.Is(err, io.EOF) {
+				t.Fatalf("readFrame() error = %v, want io.EOF", err)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readFrame() frames = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,33 +2,48 @@ package syslog_custom
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/capture"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/config"
 	"log/slog"
 	"net"
-	"regexp"
-	"strings"
+	"sync"
 	"time"
 )
 
+const (
+	TransportUDP = "udp"
+	TransportTCP = "tcp"
+	TransportTLS = "tls"
+
+	// maxMessageSize max syslog message size for every transport
+	maxMessageSize = 64 * 1024
+)
+
 type SyslogServer struct {
-	port    int
-	unit    string // panel type: beward, qtech, ...
-	logger  *slog.Logger
-	handler MessageHandler
+	port       int
+	unit       string // panel type: beward, qtech, ...
+	logger     *slog.Logger
+	handler    MessageHandler
+	transports []string          // udp, tcp, tls
+	tls        *config.TLSConfig // syslog over TLS settings
+	queue      *messageQueue     // listeners -> handler workers
+	capture    *capture.Writer   // optional raw messages capture
 }
 
-// TODO: add DBD syslog message format
-
 type SyslogMessage struct {
-	Format         string    `json:"format"` // custom field "rfc5424" or "BSD" format
-	Priority       int       `json:"priority"`
-	Version        int       `json:"version"`
-	Timestamp      time.Time `json:"timestamp"`
-	HostName       string    `json:"hostname"`
-	AppName        string    `json:"appName"`
-	ProcID         string    `json:"procId"`
-	StructuredData string    `json:"structuredData"`
-	Message        string    `json:"message"`
+	Format         string                       `json:"format"` // custom field: rfc5424, BSD, rubetek or ufanet format
+	Priority       int                          `json:"priority"`
+	Version        int                          `json:"version"`
+	Timestamp      time.Time                    `json:"timestamp"`
+	HostName       string                       `json:"hostname"`
+	AppName        string                       `json:"appName"`
+	ProcID         string                       `json:"procId"`
+	MsgID          string                       `json:"msgId"`
+	StructuredData string                       `json:"structuredData"` // raw structured data
+	SDParams       map[string]map[string]string `json:"sdParams,omitempty"`
+	Message        string                       `json:"message"`
 }
 
 type MessageHandler interface {
@@ -36,31 +51,112 @@ type MessageHandler interface {
 	HandleMessage(srcIP string, message *SyslogMessage)
 }
 
+// Start binds a listener per configured transport and blocks until ctx is canceled,
+// a failed bind closes the other listeners and is returned at once
 func (s *SyslogServer) Start(ctx context.Context) error {
-	// syslog_custom port
-	addr := fmt.Sprintf(":%d", s.port)
-	udpAddr, err := net.ResolveUDPAddr("udp", addr)
-	if err != nil {
-		s.logger.Error("Error resolving UDP address", "error", err)
-		return err
+	transports := s.transports
+	if len(transports) == 0 {
+		transports = []string{TransportUDP}
 	}
 
-	conn, err := net.ListenUDP("udp", udpAddr)
-	if err != nil {
-		s.logger.Error("Error starting UDP listener", "error", err)
-		return err
+	var listeners []*listener
+	for _, transport := range transports {
+		l, err := s.listen(transport)
+		if err != nil {
+			s.logger.Error("Error starting syslog listener", "unit", s.unit, "transport", transport, "error", err)
+			for _, l := range listeners {
+				l.Close()
+			}
+			return fmt.Errorf("syslog %s %s listener: %w", s.unit, transport, err)
+		}
+		listeners = append(listeners, l)
+		s.logger.Info("Syslog server running", "unit", s.unit, "port", l.port, "transport", transport)
 	}
-	defer conn.Close()
 
-	s.logger.Info("Syslog server running", "unit", s.unit, "port", s.port)
+	// handler workers
+	var workers sync.WaitGroup
+	s.startWorkers(&workers)
+	go s.publishStats(ctx)
+
+	var wg sync.WaitGroup
+	errCh := make(chan error, len(listeners))
+
+	for _, l := range listeners {
+		wg.Add(1)
+		go func(l *listener) {
+			defer wg.Done()
+
+			var err error
+			if l.udp != nil {
+				err = s.serveUDP(ctx, l.udp)
+			} else {
+				err = s.acceptStream(ctx, l.stream, l.transport)
+			}
+			if err != nil {
+				errCh <- err
+			}
+		}(l)
+	}
+
+	wg.Wait()
+	close(errCh)
+
+	// all listeners stopped, drain queued messages
+	s.stopWorkers()
+	workers.Wait()
+
+	var errs []error
+	for err := range errCh {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// listener bound UDP socket or TCP/TLS listener
+type listener struct {
+	transport string
+	port      int
+	udp       *net.UDPConn
+	stream    net.Listener
+}
 
-	buffer := make([]byte, 1024)
+func (l *listener) Close() error {
+	if l.udp != nil {
+		return l.udp.Close()
+	}
+	return l.stream.Close()
+}
+
+// listen bind the transport port
+func (s *SyslogServer) listen(transport string) (*listener, error) {
+	switch transport {
+	case TransportUDP:
+		udpAddr, err := net.ResolveUDPAddr("udp", fmt.Sprintf(":%d", s.port))
+		if err != nil {
+			return nil, err
+		}
+		conn, err := net.ListenUDP("udp", udpAddr)
+		if err != nil {
+			return nil, err
+		}
+		return &listener{transport: transport, port: s.port, udp: conn}, nil
+	case TransportTCP, TransportTLS:
+		return s.listenStream(transport)
+	default:
+		return nil, fmt.Errorf("unsupported syslog transport: %s", transport)
+	}
+}
+
+func (s *SyslogServer) serveUDP(ctx context.Context, conn *net.UDPConn) error {
+	defer conn.Close()
+
+	buffer := make([]byte, maxMessageSize)
 
 	for {
 		select {
 		case <-ctx.Done():
 			// Контекст отменен - graceful shutdown
-			s.logger.Info("🛑 Shutting down syslog server", "unit", s.unit)
+			s.logger.Info("🛑 Shutting down syslog server", "unit", s.unit, "transport", TransportUDP)
 			return nil
 		default:
 			// Устанавливаем таймаут для чтения, чтобы периодически проверять контекст
@@ -87,55 +183,24 @@ func (s *SyslogServer) Start(ctx context.Context) error {
 				continue
 			}
 
-			message := string(buffer[:n])
-
-			parsedMessage, err := s.ParseMessage(message)
-			if err != nil {
-				s.logger.Warn("Error parsing message", "error", err)
-				continue
-			}
-
-			if parsedMessage != nil {
-				s.handler.HandleMessage(srcAddr.IP.String(), parsedMessage)
-			}
+			s.enqueueMessage(srcAddr.IP.String(), string(buffer[:n]), TransportUDP)
 		}
 	}
 }
 
-func New(port int, unit string, logger *slog.Logger, handler MessageHandler) *SyslogServer {
+func New(panel config.PanelConfig, unit string, logger *slog.Logger, handler MessageHandler) *SyslogServer {
 	return &SyslogServer{
-		port:    port,
-		unit:    unit,
-		logger:  logger,
-		handler: handler,
+		port:       panel.Port,
+		unit:       unit,
+		logger:     logger,
+		handler:    handler,
+		transports: panel.Transports,
+		tls:        panel.TLS,
+		queue:      newMessageQueue(panel.Workers, panel.QueueSize),
 	}
 }
 
-func (s *SyslogServer) ParseMessage(rawMessage string) (*SyslogMessage, error) {
-	rfc5424Regex := regexp.MustCompile(`^<(?P<priority>\d|\d{2}|1[1-8]\d|19[01])>(?P<version>\d{1,2})\s(?P<timestamp>-|(?P<fullyear>[12]\d{3})-(?P<month>0\d|[1][012])-(?P<mday>[012]\d|3[01])T(?P<hour>[01]\d|2[0-4]):(?P<minute>[0-5]\d):(?P<second>[0-5]\d|60)(?:\.(?P<secfrac>\d{1,6}))?(?P<numoffset>Z|[+-]\d{2}:\d{2}))\s(?P<hostname>[\S]{1,255})\s(?P<appname>[\S]{1,48})\s(?P<procid>[\S]{1,128})\s(?P<msgid>[\S]{1,32})\s(?P<structureddata>-|(?:\[.+?\]))(?:\s(?P<message>.+))?$`)
-
-	rawMessage = strings.TrimSpace(rawMessage)
-
-	if matches := rfc5424Regex.FindStringSubmatch(rawMessage); matches != nil {
-		regexpGroupNames := rfc5424Regex.SubexpNames()
-
-		// TODO : refactor map. test memory usage
-		matchMap := make(map[string]string)
-		for i, match := range matches {
-			if regexpGroupNames[i] != "" {
-				matchMap[regexpGroupNames[i]] = match
-			}
-		}
-
-		hostname := matchMap["hostname"]
-		message := matchMap["message"]
-
-		return &SyslogMessage{
-			HostName: hostname,
-			Message:  message,
-		}, nil
-
-	}
-
-	return nil, fmt.Errorf("ParseMessage, unsupported message format: %s", rawMessage)
+// SetCapture write every received message to the capture files
+func (s *SyslogServer) SetCapture(writer *capture.Writer) {
+	s.capture = writer
 }
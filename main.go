@@ -2,8 +2,12 @@ package main
 
 import (
 	"context"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/api"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/capture"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/events"
 	"github.com/kulakoff/event-server-go/internal/app/event-server-go/feature"
 	handlers2 "github.com/kulakoff/event-server-go/internal/app/event-server-go/handlers"
+	"github.com/kulakoff/event-server-go/internal/app/event-server-go/httpserver"
 	"github.com/kulakoff/event-server-go/internal/app/event-server-go/repository"
 	storage2 "github.com/kulakoff/event-server-go/internal/app/event-server-go/storage"
 	"github.com/kulakoff/event-server-go/internal/app/event-server-go/syslog_custom"
@@ -22,9 +26,35 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		migrate()
+		return
+	}
 	startServer()
 }
 
+// migrate apply Clickhouse schema migrations and exit
+func migrate() {
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	cfg, err := config.New("config.json")
+	if err != nil {
+		logger.Error("Error loading config file", "error", err)
+		os.Exit(1)
+	}
+
+	ch, err := storage2.NewEventStore(logger, cfg.Clickhouse)
+	if err != nil {
+		logger.Error("Error init Clickhouse", "error", err)
+		os.Exit(1)
+	}
+
+	if err := ch.Migrate(context.Background()); err != nil {
+		logger.Error("Error migrating Clickhouse", "error", err)
+		os.Exit(1)
+	}
+}
+
 // test implementation
 func startServer() {
 
@@ -44,12 +74,27 @@ func startServer() {
 	}
 
 	// clickhouse init
-	ch, err := storage2.NewClickhouseHttpClient(logger, cfg.Clickhouse)
+	ch, err := storage2.NewEventStore(logger, cfg.Clickhouse)
 	if err != nil {
 		logger.Error("Error init Clickhouse", "error", err)
 		os.Exit(1)
 	}
 
+	if cfg.Clickhouse.Migrate {
+		if err := ch.Migrate(ctx); err != nil {
+			logger.Error("Error migrating Clickhouse", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	// clickhouse batches, stopped after all writers to flush the rest
+	chCtx, chCancel := context.WithCancel(context.Background())
+	chDone := make(chan struct{})
+	go func() {
+		defer close(chDone)
+		ch.Start(chCtx)
+	}()
+
 	// mongodb init
 	mongo, err := storage2.NewMongoDb(logger, cfg.MongoDb)
 	if err != nil {
@@ -57,6 +102,13 @@ func startServer() {
 		os.Exit(1)
 	}
 
+	// camshots storage: GridFS, local directory or S3
+	images, err := storage2.NewImageStore(logger, cfg.Images, mongo)
+	if err != nil {
+		logger.Error("Error init images storage", "error", err)
+		os.Exit(1)
+	}
+
 	// postgres init
 	psqlStorage, err := storage2.NewPSQLStorage(logger, cfg.Postgres)
 	if err != nil {
@@ -81,12 +133,69 @@ func startServer() {
 	}
 	redis.Ping(ctx)
 
-	// ----- Beward syslog_custom server
-	bewardHandler := handlers2.NewBewardHandler(logger, spamFilers.Beward, ch, mongo, repo, cfg.RbtApi, cfg.FrsApi, redis.Client)
-	bewardServer := syslog_custom.New(cfg.Hw.Beward.Port, "Beward", logger, bewardHandler)
+	// key and flat usage updates, flushed in batches
+	usage := handlers2.NewUsageUpdater(logger, repo, cfg.RbtApi)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		usage.Start(ctx)
+	}()
+
+	// expired camshots cleanup
+	if cfg.MongoDb.Reaper != nil && cfg.MongoDb.Reaper.Enabled {
+		reaper := storage2.NewGridFSReaper(logger, mongo, images.FS(), cfg.MongoDb.Reaper)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reaper.Start(ctx)
+		}()
+	}
+
+	// plog events writer shared by panel handlers and stream processor
+	writer := events.NewEventWriter(logger, ch, images, repo, redis.Client, cfg.RbtApi, cfg.FrsApi)
+
+	// ----- panel servers registered from config
+	deps := &handlers2.Deps{
+		Logger:      logger,
+		Clickhouse:  ch,
+		Mongo:       mongo,
+		Repo:        repo,
+		Redis:       redis.Client,
+		RbtApi:      cfg.RbtApi,
+		FrsApi:      cfg.FrsApi,
+		SpamFilters: spamFilers,
+		Usage:       usage,
+		Writer:      writer,
+	}
+	servers, syslogServers := newPanelServers(logger, cfg.Hw, deps)
+
+	// raw syslog capture for replay
+	if cfg.Capture != nil && cfg.Capture.Enabled {
+		captureWriter, err := capture.NewWriter(logger, cfg.Capture)
+		if err != nil {
+			logger.Warn("Error init syslog capture", "error", err)
+		} else {
+			defer captureWriter.Close()
+			for _, syslogServer := range syslogServers {
+				syslogServer.SetCapture(captureWriter)
+			}
+		}
+	}
+
+	// support API: plog and syslog history with Clickhouse HTTP interface, security events and camshots with any protocol
+	if cfg.Api != nil && cfg.Api.Port != 0 {
+		store, ok := ch.(api.Store)
+		if !ok {
+			logger.Warn("API plog and syslog history needs Clickhouse http protocol, only security events and camshots are served", "protocol", cfg.Clickhouse.Protocol)
+		}
+		apiHandler := api.NewHandler(logger, store, ch, images, cfg.Api)
+		servers = append(servers, httpserver.New(config.PanelConfig{Port: cfg.Api.Port}, "api", logger, apiHandler))
+	}
 
 	// start servers
-	go startServerWithWG(bewardServer, ctx, &wg)
+	for _, server := range servers {
+		startServerWithWG(server, ctx, &wg)
+	}
 
 	// TODO: refactor config
 	streamProcessConfig := feature.StreamProcessorConfig{
@@ -97,7 +206,7 @@ func startServer() {
 		BlockTime:      5 * time.Second,
 		PendingMinIdle: 30 * time.Second,
 	}
-	streamProcess := feature.NewStreamProcessor(logger, redis, mongo, ch, streamProcessConfig, repo, cfg.FrsApi)
+	streamProcess := feature.NewStreamProcessor(logger, redis, mongo, ch, streamProcessConfig, repo, cfg.FrsApi, writer, usage)
 
 	wg.Add(1)
 	go func() {
@@ -119,10 +228,67 @@ func startServer() {
 	logger.Info("🛑 Shutting down ...")
 	cancel()  // cancel context -  all services receive signal
 	wg.Wait() // waiting for all servers to complete
+
+	chCancel() // flush or spool clickhouse batches
+	<-chDone
+}
+
+// server syslog or HTTP panel events listener
+type server interface {
+	Start(ctx context.Context) error
+}
+
+// newPanelServers one server per configured panel with registered handler,
+// HTTP panels on the same port share one server
+func newPanelServers(logger *slog.Logger, hw *config.HwConfig, deps *handlers2.Deps) ([]server, []*syslog_custom.SyslogServer) {
+	var servers []server
+	var syslogServers []*syslog_custom.SyslogServer
+	httpServers := make(map[int]*httpserver.Server)
+
+	for _, panel := range hw.Panels() {
+		if panel.Port == 0 {
+			continue
+		}
+
+		vendor, ok := handlers2.LookupVendor(panel.Name)
+		if !ok {
+			logger.Warn("No handler registered for panel", "panel", panel.Name, "port", panel.Port)
+			continue
+		}
+
+		if err := deps.Check(vendor.Needs); err != nil {
+			logger.Error("Panel handler not started", "panel", panel.Name, "error", err)
+			continue
+		}
+
+		if vendor.Syslog != nil {
+			handler := vendor.Syslog(deps, panel.PanelConfig)
+			syslogServer := syslog_custom.New(panel.PanelConfig, vendor.Unit, logger, handler)
+			syslogServers = append(syslogServers, syslogServer)
+			servers = append(servers, syslogServer)
+
+			// handler background work: call sweeper
+			if runner, ok := handler.(server); ok {
+				servers = append(servers, runner)
+			}
+			continue
+		}
+
+		handler := vendor.HTTP(deps, panel.PanelConfig)
+		if httpServer, exists := httpServers[panel.Port]; exists {
+			httpServer.Handle(panel.APIEndpoint, handler)
+			continue
+		}
+		httpServer := httpserver.New(panel.PanelConfig, vendor.Unit, logger, handler)
+		httpServers[panel.Port] = httpServer
+		servers = append(servers, httpServer)
+	}
+
+	return servers, syslogServers
 }
 
 // wrapper for usage wg sync
-func startServerWithWG(server *syslog_custom.SyslogServer, ctx context.Context, wg *sync.WaitGroup) {
+func startServerWithWG(server server, ctx context.Context, wg *sync.WaitGroup) {
 	wg.Add(1)
 	go func() {
 		defer wg.Done()